@@ -27,7 +27,7 @@ func initDS() (*DataSources, error) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	db.AutoMigrate(&domain.Player{}, &domain.Wallet{})
+	db.AutoMigrate(&domain.Player{}, &domain.Wallet{}, &domain.Transaction{}, &domain.Transfer{}, &domain.ExchangeRate{}, &domain.Hold{}, &domain.Bonus{}, &domain.JournalLine{}, &domain.BalanceSnapshot{}, &domain.WalletStatusChange{}, &domain.Limit{}, &domain.Schedule{}, &domain.ScheduleExecution{}, &domain.Round{}, &domain.RoundAction{}, &domain.JackpotPool{})
 
 	//Initalize RedisDB connection
 	rdb := redis.NewClient(&redis.Options{
@@ -1,34 +1,72 @@
 package main
 
 import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"quik/domain"
 	"quik/internal/middleware"
+	_mysqlJackpotRepo "quik/jackpot/repository/mysql"
+	_mysqlJournalRepo "quik/journal/repository/mysql"
 	_mysqlPlayerRepo "quik/player/repository/mysql"
+	_mysqlRateRepo "quik/rate/repository/mysql"
+	_mysqlRoundRepo "quik/round/repository/mysql"
+	_mysqlScheduleRepo "quik/schedule/repository/mysql"
 	_mysqlWalletRepo "quik/wallet/repository/mysql"
 	_redisWalletRepo "quik/wallet/repository/redis"
 
+	_jackpotService "quik/jackpot/service"
+	_journalService "quik/journal/service"
 	_playerService "quik/player/service"
+	_roundService "quik/round/service"
+	_scheduleService "quik/schedule/service"
 	_walletService "quik/wallet/service"
 
+	_jackpotHandler "quik/jackpot/handler/http"
+	_journalHandler "quik/journal/handler/http"
 	_playerHandler "quik/player/handler/http"
+	_roundHandler "quik/round/handler/http"
+	_scheduleHandler "quik/schedule/handler/http"
 	_walletHandler "quik/wallet/handler/http"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func inject(d *DataSources) *gin.Engine {
+func inject(d *DataSources) (*gin.Engine, []job) {
 	/*
 	 * repository layer
 	 */
 	mysqlPlayerRepo := _mysqlPlayerRepo.NewMySqlPlayerRepository(d.MySQLDB)
 	mysqlWalletRepo := _mysqlWalletRepo.NewMySqlWalletRepository(d.MySQLDB)
+	mysqlJournalRepo := _mysqlJournalRepo.NewMySqlJournalRepository(d.MySQLDB)
+	mysqlScheduleRepo := _mysqlScheduleRepo.NewMySqlScheduleRepository(d.MySQLDB)
+	mysqlRoundRepo := _mysqlRoundRepo.NewMySqlRoundRepository(d.MySQLDB)
+	mysqlJackpotRepo := _mysqlJackpotRepo.NewMySqlJackpotRepository(d.MySQLDB)
 	redisWalletRepo := _redisWalletRepo.NewRedisInMemoryDB(d.RedisInMemoryDB)
+	redisIdempotencyStore := _redisWalletRepo.NewRedisIdempotencyStore(d.RedisInMemoryDB)
+	mysqlRateProvider := _mysqlRateRepo.NewMySqlRateProvider(d.MySQLDB)
+	if err := loadExchangeRates(mysqlRateProvider, os.Getenv("EXCHANGE_RATES_FILE")); err != nil {
+		log.Fatalf("Unable to load exchange rates: %v\n", err)
+	}
 
 	/*
 	 * service layer
 	 */
 	playerService := _playerService.NewPlayerService(mysqlPlayerRepo)
-	walletService := _walletService.NewWalletService(mysqlWalletRepo, redisWalletRepo)
+	journalService := _journalService.NewJournalService(mysqlJournalRepo)
+	walletService := _walletService.NewWalletService(mysqlWalletRepo, redisWalletRepo,
+		_walletService.WithRateProvider(mysqlRateProvider, conversionConfig()),
+		_walletService.WithHoldTTL(envDuration("HOLD_TTL", 15*time.Minute)),
+		_walletService.WithBonusSpendOrder(domain.BonusSpendOrder(os.Getenv("BONUS_SPEND_ORDER"))),
+		_walletService.WithJackpots(mysqlJackpotRepo),
+		_walletService.WithMaxWalletsPerPlayer(envInt("MAX_WALLETS_PER_PLAYER", 10)),
+	)
+	scheduleService := _scheduleService.NewScheduleService(mysqlScheduleRepo, walletService, instanceID())
+	roundService := _roundService.NewRoundService(mysqlRoundRepo, walletService, playerService)
+	jackpotService := _jackpotService.NewJackpotService(mysqlJackpotRepo, walletService)
 
 	router := gin.Default()
 
@@ -38,7 +76,55 @@ func inject(d *DataSources) *gin.Engine {
 	 * handler layer
 	 */
 	_playerHandler.NewPlayerHandler(router, playerService, walletService)
-	_walletHandler.NewWalletHandler(router, walletService)
+	_walletHandler.NewWalletHandler(router, walletService, playerService, redisIdempotencyStore)
+	_journalHandler.NewJournalHandler(router, journalService)
+	_scheduleHandler.NewScheduleHandler(router, scheduleService)
+	_roundHandler.NewRoundHandler(router, roundService)
+	_jackpotHandler.NewJackpotHandler(router, jackpotService)
+
+	/*
+	 * background jobs
+	 */
+	jobs := []job{
+		{
+			name:     "expire holds",
+			interval: 30 * time.Second,
+			run: func(ctx context.Context) error {
+				_, err := walletService.ExpireHolds(ctx)
+				return err
+			},
+		},
+		{
+			name:     "run scheduled credits",
+			interval: envDuration("SCHEDULE_INTERVAL", time.Minute),
+			run: func(ctx context.Context) error {
+				_, err := scheduleService.RunDue(ctx)
+				return err
+			},
+		},
+		{
+			name:       "snapshot balances",
+			interval:   envDuration("SNAPSHOT_INTERVAL", 24*time.Hour),
+			leaderOnly: true,
+			run: func(ctx context.Context) error {
+				_, err := walletService.TakeSnapshots(ctx)
+				return err
+			},
+		},
+		{
+			name:       "reconcile balances",
+			interval:   envDuration("RECONCILE_INTERVAL", time.Hour),
+			leaderOnly: true,
+			run: func(ctx context.Context) error {
+				report, err := walletService.Reconcile(ctx, os.Getenv("RECONCILE_REPAIR_CACHE") == "true")
+				if err != nil {
+					return err
+				}
+				logReconciliation(report)
+				return nil
+			},
+		},
+	}
 
-	return router
+	return router, jobs
 }
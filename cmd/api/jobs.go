@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// job is a task the API process runs in the background on a fixed interval.
+// Jobs marked leaderOnly run on a single instance among those sharing the
+// database.
+type job struct {
+	name       string
+	interval   time.Duration
+	leaderOnly bool
+	run        func(ctx context.Context) error
+}
+
+// leaderLock elects the instance that runs the leader-only jobs. The leader
+// holds a MySQL named lock on a connection of its own for as long as that
+// connection lives; the other instances try to take it on every tick.
+type leaderLock struct {
+	db   *gorm.DB
+	name string
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func newLeaderLock(db *gorm.DB, name string) *leaderLock {
+	return &leaderLock{db: db, name: name}
+}
+
+// held reports whether this instance is the leader, trying to become it when
+// it is not.
+func (l *leaderLock) held(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn != nil {
+		var mine sql.NullBool
+		err := l.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", l.name).Scan(&mine)
+		if err == nil && mine.Bool {
+			return true
+		}
+		l.conn.Close()
+		l.conn = nil
+	}
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return false
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return false
+	}
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", l.name).Scan(&acquired)
+	if err != nil || acquired.Int64 != 1 {
+		conn.Close()
+		return false
+	}
+	l.conn = conn
+	return true
+}
+
+// startJobs runs every job on its interval until ctx is cancelled.
+func startJobs(ctx context.Context, jobs []job, leader *leaderLock) {
+	for _, j := range jobs {
+		go func(j job) {
+			ticker := time.NewTicker(j.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if j.leaderOnly && !leader.held(ctx) {
+						continue
+					}
+					if err := j.run(ctx); err != nil {
+						log.Printf("Job %s failed: %v\n", j.name, err)
+					}
+				}
+			}
+		}(j)
+	}
+}
+
+// envDuration reads a duration such as "15m" from the environment, falling
+// back to def when the variable is unset.
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v\n", key, err)
+	}
+	return d
+}
+
+// envInt reads a whole number from the environment, falling back to def
+// when the variable is unset.
+func envInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Fatalf("Invalid %s: %q\n", key, value)
+	}
+	return n
+}
+
+// instanceID names this process in the leases it takes on shared work, such
+// as scheduled credits, so that instances sharing a database tell their
+// leases apart.
+func instanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}
@@ -18,7 +18,6 @@ func main() {
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
-	log.Println("Starting server...")
 
 	// initialize data sources
 	ds, err := initDS()
@@ -27,7 +26,17 @@ func main() {
 		log.Fatalf("Unable to initialize data sources: %v\n", err)
 	}
 
-	router := inject(ds)
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		reconcile(ds, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "open-balances" {
+		openBalances(ds)
+		return
+	}
+	log.Println("Starting server...")
+
+	router, jobs := inject(ds)
 
 	if err != nil {
 		log.Fatalf("Failure to inject data sources: %v\n", err)
@@ -46,6 +55,10 @@ func main() {
 
 	log.Printf("Listening on port %v\n", srv.Addr)
 
+	// Background jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	startJobs(jobsCtx, jobs, newLeaderLock(ds.MySQLDB, "quik:jobs"))
+
 	// Wait for kill signal of channel
 	quit := make(chan os.Signal, 1)
 
@@ -61,6 +74,7 @@ func main() {
 
 	// Shutdown server
 	log.Println("Shutting down server...")
+	stopJobs()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v\n", err)
 	}
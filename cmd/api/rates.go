@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"quik/domain"
+
+	"github.com/shopspring/decimal"
+)
+
+// conversionConfig reads the cross-currency transfer pricing from FX_SPREAD
+// (a fraction such as 0.015) and FX_ROUNDING (half_even, half_up or down).
+func conversionConfig() domain.ConversionConfig {
+	config := domain.ConversionConfig{
+		Rounding: domain.RoundingMode(os.Getenv("FX_ROUNDING")),
+	}
+	if spread := os.Getenv("FX_SPREAD"); spread != "" {
+		value, err := decimal.NewFromString(spread)
+		if err != nil {
+			log.Fatalf("Invalid FX_SPREAD: %v\n", err)
+		}
+		config.Spread = value
+	}
+	return config
+}
+
+// loadExchangeRates upserts the rates listed in the JSON file at path, for
+// example [{"base": "EUR", "quote": "SEK", "rate": "11.4567"}]. Editing the
+// file and restarting is the way to update rates locally.
+func loadExchangeRates(p domain.RateProvider, path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var rates []domain.ExchangeRate
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return err
+	}
+	for i := range rates {
+		if err := p.SetRate(context.TODO(), &rates[i]); err != nil {
+			return err
+		}
+	}
+	log.Printf("Loaded %d exchange rates from %s\n", len(rates), path)
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"quik/domain"
+	_mysqlWalletRepo "quik/wallet/repository/mysql"
+	_redisWalletRepo "quik/wallet/repository/redis"
+	_walletService "quik/wallet/service"
+)
+
+// reconcile runs a single reconciliation from the command line, e.g.
+// `api reconcile -repair-cache`, and prints the report as JSON. It exits with
+// status 1 when any wallet is left inconsistent.
+func reconcile(d *DataSources, args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	repairCache := flags.Bool("repair-cache", false, "delete stale cached wallet balances")
+	flags.Parse(args)
+
+	walletService := _walletService.NewWalletService(
+		_mysqlWalletRepo.NewMySqlWalletRepository(d.MySQLDB),
+		_redisWalletRepo.NewRedisInMemoryDB(d.RedisInMemoryDB),
+	)
+	report, err := walletService.Reconcile(context.Background(), *repairCache)
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v\n", err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatalf("Unable to write reconciliation report: %v\n", err)
+	}
+	if !report.Consistent() {
+		os.Exit(1)
+	}
+}
+
+// openBalances runs the one-off migration that brings balances held before
+// the ledger existed into it, e.g. `api open-balances`. Running it again
+// books nothing for wallets that were already opened.
+func openBalances(d *DataSources) {
+	walletService := _walletService.NewWalletService(
+		_mysqlWalletRepo.NewMySqlWalletRepository(d.MySQLDB),
+		_redisWalletRepo.NewRedisInMemoryDB(d.RedisInMemoryDB),
+	)
+	opened, err := walletService.OpenBalances(context.Background())
+	if err != nil {
+		log.Fatalf("Opening balances failed: %v\n", err)
+	}
+	log.Printf("Booked %d opening balances\n", opened)
+}
+
+// logReconciliation writes every mismatch of a scheduled run to the log.
+func logReconciliation(report domain.ReconciliationReport) {
+	for _, m := range report.Mismatches {
+		cached := "none"
+		if m.CachedBalance != nil {
+			cached = m.CachedBalance.String()
+		}
+		log.Printf("Wallet %d out of balance: stored %s, ledger %s, cached %s, cache repaired %t\n",
+			m.WalletID, m.StoredBalance, m.LedgerBalance, cached, m.CacheRepaired)
+	}
+	log.Printf("Reconciled %d wallets, %d mismatches\n", report.WalletsChecked, len(report.Mismatches))
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEmptyBatch       = errors.New("batch has no items")
+	ErrBatchTooLarge    = errors.New("batch has too many items")
+	ErrInvalidBatchMode = errors.New("invalid batch mode")
+	ErrInvalidBatchItem = errors.New("invalid batch item type")
+)
+
+// MaxBatchItems bounds how many instructions a single batch may carry.
+const MaxBatchItems = 5000
+
+// BatchMode decides what happens to a batch when one of its items fails.
+type BatchMode string
+
+const (
+	// BatchModeAllOrNothing applies every item in one database transaction,
+	// so a single failure leaves every wallet untouched.
+	BatchModeAllOrNothing BatchMode = "all_or_nothing"
+	// BatchModeBestEffort applies the items one by one and reports the
+	// outcome of each.
+	BatchModeBestEffort BatchMode = "best_effort"
+)
+
+func (m BatchMode) Valid() bool {
+	return m == BatchModeAllOrNothing || m == BatchModeBestEffort
+}
+
+// BatchItem is a single credit or debit instruction of a batch.
+type BatchItem struct {
+	WalletID string          `json:"walletId"`
+	Type     TransactionType `json:"type"`
+	Amount   string          `json:"amount"`
+	TransactionDetails
+}
+
+// BatchResult is the outcome of one batch item: its ledger entry, or the
+// error that stopped it.
+type BatchResult struct {
+	Index       int
+	Transaction *Transaction
+	Err         error
+}
+
+// BatchItemError reports which item made an all-or-nothing batch fail.
+type BatchItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
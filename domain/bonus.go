@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type BonusStatus string
+
+const (
+	// BonusStatusActive bonuses still hold funds and collect wagering.
+	BonusStatusActive BonusStatus = "active"
+	// BonusStatusCompleted bonuses met their wagering requirement and had
+	// their remaining funds converted into cash.
+	BonusStatusCompleted BonusStatus = "completed"
+	// BonusStatusSpent bonuses had all their funds wagered away first.
+	BonusStatusSpent BonusStatus = "spent"
+)
+
+type BonusSpendOrder string
+
+const (
+	BonusSpendCashFirst  BonusSpendOrder = "cash_first"
+	BonusSpendBonusFirst BonusSpendOrder = "bonus_first"
+)
+
+// Bonus is a promotional credit locked behind a wagering requirement of
+// Amount times WageringMultiplier. Debits count towards Wagered and, once
+// the requirement is met, whatever is left of the bonus becomes cash and is
+// recorded as Converted.
+type Bonus struct {
+	ID                 int             `json:"id"`
+	WalletID           int             `json:"walletId" gorm:"index"`
+	PlayerID           int             `json:"playerId"`
+	Amount             decimal.Decimal `json:"amount" gorm:"type:decimal(20,8)"`
+	Remaining          decimal.Decimal `json:"remaining" gorm:"type:decimal(20,8)"`
+	WageringMultiplier decimal.Decimal `json:"wagering_multiplier" gorm:"type:decimal(10,2)"`
+	WageringRequired   decimal.Decimal `json:"wagering_required" gorm:"type:decimal(20,8)"`
+	Wagered            decimal.Decimal `json:"wagered" gorm:"type:decimal(20,8)"`
+	Converted          decimal.Decimal `json:"converted" gorm:"type:decimal(20,8);not null;default:0"`
+	Status             BonusStatus     `json:"status" gorm:"type:varchar(16)"`
+	UpdatedAt          time.Time       `json:"updated_at"`
+	CreatedAt          time.Time       `json:"created_at"`
+}
+
+func (Bonus) TableName() string {
+	return "wallet_bonuses"
+}
+
+// WageringProgress is the fraction of the requirement wagered so far,
+// between 0 and 1.
+func (b Bonus) WageringProgress() decimal.Decimal {
+	if !b.WageringRequired.IsPositive() {
+		return decimal.NewFromInt(1)
+	}
+	return decimal.Min(b.Wagered.DivRound(b.WageringRequired, 4), decimal.NewFromInt(1))
+}
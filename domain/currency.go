@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrAmountPrecision     = errors.New("amount has more decimal places than the currency allows")
+	ErrCurrencyMismatch    = errors.New("wallets hold different currencies")
+)
+
+const DefaultCurrency = "EUR"
+
+// Currency describes an ISO 4217 currency and the number of decimal places
+// its amounts may carry.
+type Currency struct {
+	Code       string `json:"code"`
+	MinorUnits int32  `json:"minor_units"`
+}
+
+var currencies = map[string]Currency{
+	"EUR": {Code: "EUR", MinorUnits: 2},
+	"GBP": {Code: "GBP", MinorUnits: 2},
+	"SEK": {Code: "SEK", MinorUnits: 2},
+	"NOK": {Code: "NOK", MinorUnits: 2},
+	"DKK": {Code: "DKK", MinorUnits: 2},
+	"USD": {Code: "USD", MinorUnits: 2},
+	"CHF": {Code: "CHF", MinorUnits: 2},
+	"JPY": {Code: "JPY", MinorUnits: 0},
+	"KWD": {Code: "KWD", MinorUnits: 3},
+}
+
+// LookupCurrency returns the registered currency for an ISO 4217 code,
+// ignoring case.
+func LookupCurrency(code string) (Currency, error) {
+	currency, ok := currencies[strings.ToUpper(code)]
+	if !ok {
+		return Currency{}, ErrUnsupportedCurrency
+	}
+	return currency, nil
+}
+
+// ValidateAmount rejects amounts with more decimal places than the currency
+// allows.
+func (c Currency) ValidateAmount(amount decimal.Decimal) error {
+	if !amount.Equal(amount.Truncate(c.MinorUnits)) {
+		return ErrAmountPrecision
+	}
+	return nil
+}
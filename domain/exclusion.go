@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrPlayerExcluded     = errors.New("player is self-excluded")
+	ErrInvalidExclusion   = errors.New("an exclusion needs an end date in the future or must be indefinite")
+	ErrExclusionShortened = errors.New("an active exclusion cannot be shortened or lifted")
+)
+
+// Exclusion is a player's request to be kept from gambling from From until
+// Until, or indefinitely. A zero From starts it immediately.
+type Exclusion struct {
+	From       time.Time
+	Until      *time.Time
+	Indefinite bool
+}
+
+// ExcludedAt reports whether the player's exclusion covers the given moment.
+func (p Player) ExcludedAt(now time.Time) bool {
+	if p.ExcludedFrom == nil || now.Before(*p.ExcludedFrom) {
+		return false
+	}
+	return p.ExcludedIndefinitely || (p.ExcludedUntil != nil && now.Before(*p.ExcludedUntil))
+}
+
+// HasExclusion reports whether the player has an exclusion that is in force
+// or has yet to start.
+func (p Player) HasExclusion(now time.Time) bool {
+	if p.ExcludedFrom == nil {
+		return false
+	}
+	return p.ExcludedIndefinitely || (p.ExcludedUntil != nil && now.Before(*p.ExcludedUntil))
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrHoldNotActive = errors.New("hold is no longer active")
+	ErrHoldExpired   = errors.New("hold has expired")
+)
+
+type HoldStatus string
+
+const (
+	HoldStatusActive   HoldStatus = "active"
+	HoldStatusCaptured HoldStatus = "captured"
+	HoldStatusVoided   HoldStatus = "voided"
+	HoldStatusExpired  HoldStatus = "expired"
+)
+
+// Hold reserves part of a wallet's balance, for example a stake while a game
+// round resolves. Active holds count towards Wallet.HeldBalance until they
+// are captured, voided or expire.
+type Hold struct {
+	ID             int             `json:"id"`
+	WalletID       int             `json:"walletId" gorm:"index"`
+	PlayerID       int             `json:"playerId"`
+	Amount         decimal.Decimal `json:"amount" gorm:"type:decimal(20,8)"`
+	CapturedAmount decimal.Decimal `json:"captured_amount" gorm:"type:decimal(20,8)"`
+	Status         HoldStatus      `json:"status" gorm:"type:varchar(16);index:idx_hold_status_expiry"`
+	ExpiresAt      time.Time       `json:"expires_at" gorm:"index:idx_hold_status_expiry"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+func (Hold) TableName() string {
+	return "wallet_holds"
+}
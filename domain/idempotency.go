@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key reused with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is in progress")
+)
+
+// IdempotencyRecord stores the outcome of a request made with an
+// Idempotency-Key header. A zero StatusCode marks a request still in flight.
+type IdempotencyRecord struct {
+	Fingerprint string    `json:"fingerprint"`
+	StatusCode  int       `json:"status_code"`
+	Body        []byte    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type IdempotencyStore interface {
+	// Reserve stores the record only if the key is unused and reports
+	// whether it did.
+	Reserve(ctx context.Context, key string, record *IdempotencyRecord) (bool, error)
+	Get(ctx context.Context, key string) (IdempotencyRecord, error)
+	Save(ctx context.Context, key string, record *IdempotencyRecord) error
+	Delete(ctx context.Context, key string) error
+}
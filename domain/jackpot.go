@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidJackpotName      = errors.New("jackpot name must be 1 to 64 characters")
+	ErrInvalidContributionRate = errors.New("contribution rate must be above 0 and below 1")
+	ErrJackpotExists           = errors.New("jackpot with this name already exists")
+	ErrJackpotEmpty            = errors.New("jackpot pool is empty")
+)
+
+const maxJackpotNameWidth = 64
+
+// JackpotPool is a progressive jackpot. Its funds sit in a wallet owned by
+// no player, which receives ContributionRate of every qualifying debit in
+// the pool's currency and is reseeded to SeedAmount after each payout.
+type JackpotPool struct {
+	ID               int             `json:"id"`
+	Name             string          `json:"name" gorm:"type:varchar(64);uniqueIndex"`
+	WalletID         int             `json:"walletId" gorm:"uniqueIndex"`
+	Currency         string          `json:"currency" gorm:"type:char(3);index"`
+	ContributionRate decimal.Decimal `json:"contribution_rate" gorm:"type:decimal(10,8)"`
+	SeedAmount       decimal.Decimal `json:"seed_amount" gorm:"type:decimal(20,8)"`
+	// Balance is read from the pool's wallet and not stored with the pool.
+	Balance   decimal.Decimal `json:"balance" gorm:"-"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func (JackpotPool) TableName() string {
+	return "jackpot_pools"
+}
+
+// ValidJackpotName reports whether name can name a pool.
+func ValidJackpotName(name string) bool {
+	return name != "" && len(name) <= maxJackpotNameWidth
+}
+
+// Contribution is what the debit t, on a wallet holding currency, pays into
+// the pool, rounded down to the currency's minor units. Only bets, and
+// debits without a category, qualify; withdrawals never do.
+func (p JackpotPool) Contribution(t Transaction, currency Currency) decimal.Decimal {
+	if t.Type != TransactionTypeDebit || t.Withdrawal || t.WalletID == p.WalletID || currency.Code != p.Currency {
+		return decimal.Zero
+	}
+	if t.Category != "" && t.Category != CategoryBet {
+		return decimal.Zero
+	}
+	return t.Amount.Mul(p.ContributionRate).Truncate(currency.MinorUnits)
+}
+
+// JackpotPayout is the ledger entries of one payout: the debit emptying the
+// pool, the credit to the winner and, if the pool has a seed, the reseed.
+type JackpotPayout struct {
+	Pool   JackpotPool  `json:"pool"`
+	Debit  Transaction  `json:"debit"`
+	Credit Transaction  `json:"credit"`
+	Reseed *Transaction `json:"reseed,omitempty"`
+}
+
+type JackpotService interface {
+	// Create opens a pool in currency with a new wallet, seeded with
+	// seedAmount.
+	Create(ctx context.Context, name, currency, contributionRate, seedAmount string, actorID int) (JackpotPool, error)
+	Get(ctx context.Context, id string) (JackpotPool, error)
+	List(ctx context.Context) ([]JackpotPool, error)
+	// Payout pays the whole pool to the wallet and reseeds it.
+	Payout(ctx context.Context, id, walletID string, actorID int) (JackpotPayout, error)
+}
+
+type JackpotRepository interface {
+	Get(ctx context.Context, id string) (JackpotPool, error)
+	List(ctx context.Context) ([]JackpotPool, error)
+	ListByCurrency(ctx context.Context, currency string) ([]JackpotPool, error)
+}
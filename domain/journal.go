@@ -0,0 +1,147 @@
+package domain
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// System accounts that wallet movements are booked against. Player wallets
+// are liabilities of the operator, so crediting a wallet credits its account
+// and debits the counter-account, and the other way round for debits.
+const (
+	AccountHouse            = "house"
+	AccountPSPClearing      = "psp_clearing"
+	AccountBonusLiability   = "bonus_liability"
+	AccountTransferClearing = "transfer_clearing"
+)
+
+// WalletAccount names the journal account of a wallet.
+func WalletAccount(walletID int) string {
+	return "wallet:" + strconv.Itoa(walletID)
+}
+
+// JournalLine is one side of a double-entry booking. Every ledger
+// transaction produces a balanced set of lines.
+type JournalLine struct {
+	ID            int             `json:"id"`
+	TransactionID int             `json:"transactionId" gorm:"index"`
+	Account       string          `json:"account" gorm:"type:varchar(64);index:idx_journal_account_currency"`
+	Currency      string          `json:"currency" gorm:"type:char(3);index:idx_journal_account_currency"`
+	Debit         decimal.Decimal `json:"debit" gorm:"type:decimal(20,8)"`
+	Credit        decimal.Decimal `json:"credit" gorm:"type:decimal(20,8)"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+func (JournalLine) TableName() string {
+	return "journal_lines"
+}
+
+// CounterAccount picks the system account the cash part of a ledger
+// transaction is booked against: the PSP clearing account for player
+// deposits and withdrawals, the transfer clearing account for transfer legs
+// and the house for everything else. The bonus part is always booked against
+// AccountBonusLiability.
+func CounterAccount(t Transaction) string {
+	switch {
+	case t.TransferID != nil:
+		return AccountTransferClearing
+	case t.Deposit || t.Withdrawal:
+		return AccountPSPClearing
+	default:
+		return AccountHouse
+	}
+}
+
+// JournalLines builds the balanced lines for a ledger transaction on a wallet
+// holding the given currency. The wallet's line is matched by the
+// counter-accounts of its cash and bonus parts, and bonus funds the
+// transaction converted into cash move from the bonus liability to the house.
+func JournalLines(t Transaction, currency string) []JournalLine {
+	line := func(account string, debit, credit decimal.Decimal) JournalLine {
+		return JournalLine{TransactionID: t.ID, Account: account, Currency: currency, Debit: debit, Credit: credit}
+	}
+	// counter books amount on the opposite side to the wallet.
+	counter := func(account string, amount decimal.Decimal) JournalLine {
+		if t.Type == TransactionTypeCredit {
+			return line(account, amount, decimal.Zero)
+		}
+		return line(account, decimal.Zero, amount)
+	}
+	var lines []JournalLine
+	cash := t.Amount.Sub(t.BonusAmount)
+	if cash.IsPositive() || !t.BonusAmount.IsPositive() {
+		lines = append(lines, counter(CounterAccount(t), cash))
+	}
+	if t.BonusAmount.IsPositive() {
+		lines = append(lines, counter(AccountBonusLiability, t.BonusAmount))
+	}
+	if t.Type == TransactionTypeCredit {
+		lines = append(lines, line(WalletAccount(t.WalletID), decimal.Zero, t.Amount))
+	} else {
+		lines = append(lines, line(WalletAccount(t.WalletID), t.Amount, decimal.Zero))
+	}
+	switch {
+	case t.BonusConverted.IsPositive():
+		lines = append(lines,
+			line(AccountHouse, t.BonusConverted, decimal.Zero),
+			line(AccountBonusLiability, decimal.Zero, t.BonusConverted))
+	case t.BonusConverted.IsNegative():
+		relocked := t.BonusConverted.Neg()
+		lines = append(lines,
+			line(AccountBonusLiability, relocked, decimal.Zero),
+			line(AccountHouse, decimal.Zero, relocked))
+	}
+	return lines
+}
+
+// AccountBalance totals the journal lines of one account in one currency.
+type AccountBalance struct {
+	Account  string          `json:"account"`
+	Currency string          `json:"currency"`
+	Debit    decimal.Decimal `json:"debit"`
+	Credit   decimal.Decimal `json:"credit"`
+}
+
+// StoredBalance is what a wallet itself records as its balance and bonus
+// funds.
+type StoredBalance struct {
+	WalletID     int
+	Currency     string
+	Balance      decimal.Decimal
+	BonusBalance decimal.Decimal
+}
+
+// AccountMismatch is an account whose journal balance differs from the
+// balances stored on the wallets.
+type AccountMismatch struct {
+	Account  string          `json:"account"`
+	Currency string          `json:"currency"`
+	Journal  decimal.Decimal `json:"journal"`
+	Stored   decimal.Decimal `json:"stored"`
+}
+
+// TrialBalance lists every account's totals along with the overall totals
+// per currency, which must match for the books to balance. The books are
+// only balanced when, in addition, every wallet account agrees with its
+// wallet's balance and the bonus liability with the bonus funds the wallets
+// hold.
+type TrialBalance struct {
+	Accounts     []AccountBalance           `json:"accounts"`
+	TotalDebits  map[string]decimal.Decimal `json:"total_debits"`
+	TotalCredits map[string]decimal.Decimal `json:"total_credits"`
+	Mismatches   []AccountMismatch          `json:"mismatches"`
+	Balanced     bool                       `json:"balanced"`
+}
+
+type JournalRepository interface {
+	AccountBalances(ctx context.Context) ([]AccountBalance, error)
+	// StoredBalances returns the balances of every wallet.
+	StoredBalances(ctx context.Context) ([]StoredBalance, error)
+}
+
+type JournalService interface {
+	TrialBalance(ctx context.Context) (TrialBalance, error)
+}
@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrDepositLimitExceeded = errors.New("deposit limit exceeded")
+	ErrLossLimitExceeded    = errors.New("loss limit exceeded")
+	ErrWagerLimitExceeded   = errors.New("wager limit exceeded")
+	ErrInvalidLimitType     = errors.New("invalid limit type")
+	ErrInvalidLimitPeriod   = errors.New("invalid limit period")
+)
+
+// LimitType names what a responsible-gambling limit caps.
+type LimitType string
+
+const (
+	// LimitTypeDeposit caps the total deposited.
+	LimitTypeDeposit LimitType = "deposit"
+	// LimitTypeLoss caps debits less winnings.
+	LimitTypeLoss LimitType = "loss"
+	// LimitTypeWager caps the total debited.
+	LimitTypeWager LimitType = "wager"
+)
+
+// ExceededError is the error returned when a movement would go over a limit
+// of this type.
+func (t LimitType) ExceededError() error {
+	switch t {
+	case LimitTypeDeposit:
+		return ErrDepositLimitExceeded
+	case LimitTypeLoss:
+		return ErrLossLimitExceeded
+	case LimitTypeWager:
+		return ErrWagerLimitExceeded
+	}
+	return nil
+}
+
+func (t LimitType) Valid() bool {
+	return t.ExceededError() != nil
+}
+
+// LimitCoolingPeriod is how long a player waits before a looser limit takes
+// effect. Tighter limits apply immediately.
+const LimitCoolingPeriod = 24 * time.Hour
+
+type LimitPeriod string
+
+const (
+	LimitPeriodDaily   LimitPeriod = "daily"
+	LimitPeriodWeekly  LimitPeriod = "weekly"
+	LimitPeriodMonthly LimitPeriod = "monthly"
+)
+
+var LimitPeriods = []LimitPeriod{LimitPeriodDaily, LimitPeriodWeekly, LimitPeriodMonthly}
+
+// Window is the length of the rolling window a limit of this period covers.
+func (p LimitPeriod) Window() time.Duration {
+	switch p {
+	case LimitPeriodDaily:
+		return 24 * time.Hour
+	case LimitPeriodWeekly:
+		return 7 * 24 * time.Hour
+	case LimitPeriodMonthly:
+		return 30 * 24 * time.Hour
+	}
+	return 0
+}
+
+func (p LimitPeriod) Valid() bool {
+	return p.Window() > 0
+}
+
+// Limit caps what a player may deposit, wager or lose in one currency over
+// a rolling period, across all of their wallets in that currency, so that
+// opening another wallet does not escape it. A null Amount means no limit. A
+// pending change is waiting out the cooling period and takes over from
+// Amount once PendingFrom has passed.
+type Limit struct {
+	ID            int                 `json:"id"`
+	PlayerID      int                 `json:"playerId" gorm:"not null;default:0;uniqueIndex:idx_limit_player_currency_type_period"`
+	Currency      string              `json:"currency" gorm:"type:varchar(3);not null;default:'';uniqueIndex:idx_limit_player_currency_type_period"`
+	Type          LimitType           `json:"type" gorm:"type:varchar(16);uniqueIndex:idx_limit_player_currency_type_period"`
+	Period        LimitPeriod         `json:"period" gorm:"type:varchar(16);uniqueIndex:idx_limit_player_currency_type_period"`
+	Amount        decimal.NullDecimal `json:"amount" gorm:"type:decimal(20,8)"`
+	PendingAmount decimal.NullDecimal `json:"pending_amount" gorm:"type:decimal(20,8)"`
+	PendingFrom   *time.Time          `json:"pending_from"`
+	// Used and Remaining describe the current window when the limit is
+	// reported; they are not stored.
+	Used      decimal.Decimal     `json:"used" gorm:"-"`
+	Remaining decimal.NullDecimal `json:"remaining" gorm:"-"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+func (Limit) TableName() string {
+	return "wallet_limits"
+}
+
+// Settle applies a pending change whose cooling period has passed.
+func (l *Limit) Settle(now time.Time) {
+	if l.PendingFrom != nil && !now.Before(*l.PendingFrom) {
+		l.Amount = l.PendingAmount
+		l.PendingAmount = decimal.NullDecimal{}
+		l.PendingFrom = nil
+	}
+}
+
+// Change sets a new limit, where a null amount removes it. Tightening the
+// limit applies immediately and drops any pending change; loosening it is
+// queued until the cooling period has passed.
+func (l *Limit) Change(amount decimal.NullDecimal, now time.Time) {
+	l.Settle(now)
+	tighter := amount.Valid && (!l.Amount.Valid || amount.Decimal.LessThan(l.Amount.Decimal))
+	unchanged := amount.Valid == l.Amount.Valid && amount.Decimal.Equal(l.Amount.Decimal)
+	if tighter || unchanged {
+		l.Amount = amount
+		l.PendingAmount = decimal.NullDecimal{}
+		l.PendingFrom = nil
+		return
+	}
+	pendingFrom := now.Add(LimitCoolingPeriod)
+	l.PendingAmount = amount
+	l.PendingFrom = &pendingFrom
+}
+
+// WalletActivity totals a player's movements in one currency over a window.
+// Wagered counts debits other than withdrawals, transfers and reversals, plus
+// the stakes still reserved by active holds; Won counts credits other than
+// deposits, transfers and bonus grants, including cash credited in the bonus
+// category such as scheduled credits.
+type WalletActivity struct {
+	Deposited decimal.Decimal
+	Wagered   decimal.Decimal
+	Won       decimal.Decimal
+}
+
+// Used is how much of a limit of the given type the activity consumed. Net
+// winnings leave a loss limit untouched rather than extending it.
+func (a WalletActivity) Used(t LimitType) decimal.Decimal {
+	switch t {
+	case LimitTypeDeposit:
+		return a.Deposited
+	case LimitTypeWager:
+		return a.Wagered
+	case LimitTypeLoss:
+		return decimal.Max(a.Wagered.Sub(a.Won), decimal.Zero)
+	}
+	return decimal.Zero
+}
@@ -0,0 +1,36 @@
+package inmemorydb
+
+import (
+	"context"
+	"quik/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type IdempotencyStoreMock struct {
+	mock.Mock
+}
+
+func (i *IdempotencyStoreMock) Reserve(ctx context.Context, key string, record *domain.IdempotencyRecord) (bool, error) {
+	output := i.Mock.Called(ctx, key, record)
+	return output.Bool(0), output.Error(1)
+}
+
+func (i *IdempotencyStoreMock) Get(ctx context.Context, key string) (domain.IdempotencyRecord, error) {
+	output := i.Mock.Called(ctx, key)
+	record := output.Get(0)
+	err := output.Error(1)
+	return record.(domain.IdempotencyRecord), err
+}
+
+func (i *IdempotencyStoreMock) Save(ctx context.Context, key string, record *domain.IdempotencyRecord) error {
+	output := i.Mock.Called(ctx, key, record)
+	err := output.Error(0)
+	return err
+}
+
+func (i *IdempotencyStoreMock) Delete(ctx context.Context, key string) error {
+	output := i.Mock.Called(ctx, key)
+	err := output.Error(0)
+	return err
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"quik/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type JackpotRepositoryMock struct {
+	mock.Mock
+}
+
+func (j *JackpotRepositoryMock) Get(ctx context.Context, id string) (domain.JackpotPool, error) {
+	output := j.Mock.Called(ctx, id)
+	pool := output.Get(0)
+	err := output.Error(1)
+	return pool.(domain.JackpotPool), err
+}
+
+func (j *JackpotRepositoryMock) List(ctx context.Context) ([]domain.JackpotPool, error) {
+	output := j.Mock.Called(ctx)
+	pools := output.Get(0)
+	err := output.Error(1)
+	return pools.([]domain.JackpotPool), err
+}
+
+func (j *JackpotRepositoryMock) ListByCurrency(ctx context.Context, currency string) ([]domain.JackpotPool, error) {
+	output := j.Mock.Called(ctx, currency)
+	pools := output.Get(0)
+	err := output.Error(1)
+	return pools.([]domain.JackpotPool), err
+}
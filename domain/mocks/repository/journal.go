@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"quik/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type JournalRepositoryMock struct {
+	mock.Mock
+}
+
+func (j *JournalRepositoryMock) AccountBalances(ctx context.Context) ([]domain.AccountBalance, error) {
+	output := j.Mock.Called(ctx)
+	balances := output.Get(0)
+	err := output.Error(1)
+	return balances.([]domain.AccountBalance), err
+}
+
+func (j *JournalRepositoryMock) StoredBalances(ctx context.Context) ([]domain.StoredBalance, error) {
+	output := j.Mock.Called(ctx)
+	balances := output.Get(0)
+	err := output.Error(1)
+	return balances.([]domain.StoredBalance), err
+}
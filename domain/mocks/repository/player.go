@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"quik/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type PlayerRepositoryMock struct {
+	mock.Mock
+}
+
+func (p *PlayerRepositoryMock) Create(ctx context.Context, player *domain.Player) error {
+	output := p.Mock.Called(ctx, player)
+	err := output.Error(0)
+	return err
+}
+
+func (p *PlayerRepositoryMock) Update(ctx context.Context, player *domain.Player, updatedPlayer domain.Player) error {
+	output := p.Mock.Called(ctx, player, updatedPlayer)
+	err := output.Error(0)
+	return err
+}
+
+func (p *PlayerRepositoryMock) Get(ctx context.Context, id string) (domain.Player, error) {
+	output := p.Mock.Called(ctx, id)
+	player := output.Get(0)
+	err := output.Error(1)
+	return player.(domain.Player), err
+}
+
+func (p *PlayerRepositoryMock) Delete(ctx context.Context, id string, player *domain.Player) error {
+	output := p.Mock.Called(ctx, id, player)
+	err := output.Error(0)
+	return err
+}
+
+func (p *PlayerRepositoryMock) FindByEmail(ctx context.Context, email string, player *domain.Player) error {
+	output := p.Mock.Called(ctx, email, player)
+	err := output.Error(0)
+	return err
+}
+
+func (p *PlayerRepositoryMock) UpdateExclusion(ctx context.Context, player *domain.Player) error {
+	output := p.Mock.Called(ctx, player)
+	err := output.Error(0)
+	return err
+}
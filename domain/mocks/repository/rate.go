@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"context"
+	"quik/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type RateProviderMock struct {
+	mock.Mock
+}
+
+func (r *RateProviderMock) Rate(ctx context.Context, base, quote string) (domain.ExchangeRate, error) {
+	output := r.Mock.Called(ctx, base, quote)
+	rate := output.Get(0)
+	err := output.Error(1)
+	return rate.(domain.ExchangeRate), err
+}
+
+func (r *RateProviderMock) SetRate(ctx context.Context, rate *domain.ExchangeRate) error {
+	output := r.Mock.Called(ctx, rate)
+	err := output.Error(0)
+	return err
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"quik/domain"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type RoundRepositoryMock struct {
+	mock.Mock
+}
+
+func (r *RoundRepositoryMock) GetRound(ctx context.Context, provider, roundID string) (domain.Round, error) {
+	output := r.Mock.Called(ctx, provider, roundID)
+	round := output.Get(0)
+	err := output.Error(1)
+	return round.(domain.Round), err
+}
+
+func (r *RoundRepositoryMock) FindOrCreateRound(ctx context.Context, round *domain.Round) error {
+	output := r.Mock.Called(ctx, round)
+	return output.Error(0)
+}
+
+func (r *RoundRepositoryMock) CloseRound(ctx context.Context, round *domain.Round) error {
+	output := r.Mock.Called(ctx, round)
+	return output.Error(0)
+}
+
+func (r *RoundRepositoryMock) GetAction(ctx context.Context, provider, transactionID string) (domain.RoundAction, error) {
+	output := r.Mock.Called(ctx, provider, transactionID)
+	action := output.Get(0)
+	err := output.Error(1)
+	return action.(domain.RoundAction), err
+}
+
+func (r *RoundRepositoryMock) StartAction(ctx context.Context, action *domain.RoundAction) error {
+	output := r.Mock.Called(ctx, action)
+	return output.Error(0)
+}
+
+func (r *RoundRepositoryMock) CompleteAction(ctx context.Context, round *domain.Round, action *domain.RoundAction) error {
+	output := r.Mock.Called(ctx, round, action)
+	return output.Error(0)
+}
+
+func (r *RoundRepositoryMock) DeleteAction(ctx context.Context, action *domain.RoundAction) error {
+	output := r.Mock.Called(ctx, action)
+	return output.Error(0)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"quik/domain"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type ScheduleRepositoryMock struct {
+	mock.Mock
+}
+
+func (s *ScheduleRepositoryMock) Create(ctx context.Context, schedule *domain.Schedule) error {
+	output := s.Mock.Called(ctx, schedule)
+	return output.Error(0)
+}
+
+func (s *ScheduleRepositoryMock) Get(ctx context.Context, id string) (domain.Schedule, error) {
+	output := s.Mock.Called(ctx, id)
+	schedule := output.Get(0)
+	err := output.Error(1)
+	return schedule.(domain.Schedule), err
+}
+
+func (s *ScheduleRepositoryMock) ListByWallet(ctx context.Context, walletID string) ([]domain.Schedule, error) {
+	output := s.Mock.Called(ctx, walletID)
+	schedules := output.Get(0)
+	err := output.Error(1)
+	return schedules.([]domain.Schedule), err
+}
+
+func (s *ScheduleRepositoryMock) Cancel(ctx context.Context, schedule *domain.Schedule) error {
+	output := s.Mock.Called(ctx, schedule)
+	return output.Error(0)
+}
+
+func (s *ScheduleRepositoryMock) ClaimDue(ctx context.Context, owner string, now, leaseUntil time.Time, limit int) ([]domain.Schedule, error) {
+	output := s.Mock.Called(ctx, owner, now, leaseUntil, limit)
+	schedules := output.Get(0)
+	err := output.Error(1)
+	return schedules.([]domain.Schedule), err
+}
+
+func (s *ScheduleRepositoryMock) StartExecution(ctx context.Context, execution *domain.ScheduleExecution) error {
+	output := s.Mock.Called(ctx, execution)
+	return output.Error(0)
+}
+
+func (s *ScheduleRepositoryMock) GetExecution(ctx context.Context, scheduleID int, scheduledFor time.Time) (domain.ScheduleExecution, error) {
+	output := s.Mock.Called(ctx, scheduleID, scheduledFor)
+	execution := output.Get(0)
+	err := output.Error(1)
+	return execution.(domain.ScheduleExecution), err
+}
+
+func (s *ScheduleRepositoryMock) FinishExecution(ctx context.Context, owner string, schedule *domain.Schedule, execution *domain.ScheduleExecution) error {
+	output := s.Mock.Called(ctx, owner, schedule, execution)
+	return output.Error(0)
+}
+
+func (s *ScheduleRepositoryMock) ListExecutions(ctx context.Context, scheduleID int) ([]domain.ScheduleExecution, error) {
+	output := s.Mock.Called(ctx, scheduleID)
+	executions := output.Get(0)
+	err := output.Error(1)
+	return executions.([]domain.ScheduleExecution), err
+}
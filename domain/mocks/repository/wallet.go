@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"quik/domain"
+	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -24,14 +26,234 @@ func (w *WalletRepositoryMock) Get(ctx context.Context, id string) (domain.Walle
 	return wallet.(domain.Wallet), err
 }
 
-func (w *WalletRepositoryMock) Credit(ctx context.Context, wallet *domain.Wallet) error {
-	output := w.Mock.Called(ctx, wallet)
+func (w *WalletRepositoryMock) Credit(ctx context.Context, wallet *domain.Wallet, transaction *domain.Transaction) error {
+	output := w.Mock.Called(ctx, wallet, transaction)
+	err := output.Error(0)
+	return err
+}
+
+func (w *WalletRepositoryMock) Debit(ctx context.Context, wallet *domain.Wallet, transaction *domain.Transaction) error {
+	output := w.Mock.Called(ctx, wallet, transaction)
+	err := output.Error(0)
+	return err
+}
+
+func (w *WalletRepositoryMock) ListTransactions(ctx context.Context, id string, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	output := w.Mock.Called(ctx, id, filter)
+	transactions := output.Get(0)
+	err := output.Error(1)
+	return transactions.([]domain.Transaction), err
+}
+
+func (w *WalletRepositoryMock) CreateTransfer(ctx context.Context, transfer *domain.Transfer) error {
+	output := w.Mock.Called(ctx, transfer)
 	err := output.Error(0)
 	return err
 }
 
-func (w *WalletRepositoryMock) Debit(ctx context.Context, wallet *domain.Wallet) error {
+func (w *WalletRepositoryMock) GetTransaction(ctx context.Context, id string) (domain.Transaction, error) {
+	output := w.Mock.Called(ctx, id)
+	transaction := output.Get(0)
+	err := output.Error(1)
+	return transaction.(domain.Transaction), err
+}
+
+func (w *WalletRepositoryMock) ReversedAmount(ctx context.Context, transactionID int) (decimal.Decimal, error) {
+	output := w.Mock.Called(ctx, transactionID)
+	amount := output.Get(0)
+	err := output.Error(1)
+	return amount.(decimal.Decimal), err
+}
+
+func (w *WalletRepositoryMock) ListBonuses(ctx context.Context, walletID int, status domain.BonusStatus) ([]domain.Bonus, error) {
+	output := w.Mock.Called(ctx, walletID, status)
+	bonuses := output.Get(0)
+	err := output.Error(1)
+	return bonuses.([]domain.Bonus), err
+}
+
+func (w *WalletRepositoryMock) SaveBonus(ctx context.Context, bonus *domain.Bonus) error {
+	output := w.Mock.Called(ctx, bonus)
+	err := output.Error(0)
+	return err
+}
+
+func (w *WalletRepositoryMock) CreateJackpot(ctx context.Context, pool *domain.JackpotPool) error {
+	output := w.Mock.Called(ctx, pool)
+	err := output.Error(0)
+	return err
+}
+
+func (w *WalletRepositoryMock) GetHold(ctx context.Context, id string) (domain.Hold, error) {
+	output := w.Mock.Called(ctx, id)
+	hold := output.Get(0)
+	err := output.Error(1)
+	return hold.(domain.Hold), err
+}
+
+func (w *WalletRepositoryMock) ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]domain.Hold, error) {
+	output := w.Mock.Called(ctx, now, limit)
+	holds := output.Get(0)
+	err := output.Error(1)
+	return holds.([]domain.Hold), err
+}
+
+func (w *WalletRepositoryMock) LockWallets(ctx context.Context, ids []int) error {
+	output := w.Mock.Called(ctx, ids)
+	return output.Error(0)
+}
+
+func (w *WalletRepositoryMock) LockPlayer(ctx context.Context, playerID int) error {
+	output := w.Mock.Called(ctx, playerID)
+	return output.Error(0)
+}
+
+func (w *WalletRepositoryMock) CountActiveHolds(ctx context.Context, walletID int) (int, error) {
+	output := w.Mock.Called(ctx, walletID)
+	return output.Int(0), output.Error(1)
+}
+
+func (w *WalletRepositoryMock) CreateHold(ctx context.Context, wallet *domain.Wallet, hold *domain.Hold) error {
+	output := w.Mock.Called(ctx, wallet, hold)
+	err := output.Error(0)
+	return err
+}
+
+func (w *WalletRepositoryMock) SettleHold(ctx context.Context, wallet *domain.Wallet, hold *domain.Hold, transaction *domain.Transaction) error {
+	output := w.Mock.Called(ctx, wallet, hold, transaction)
+	err := output.Error(0)
+	return err
+}
+
+func (w *WalletRepositoryMock) ListLimits(ctx context.Context, playerID int, currency string) ([]domain.Limit, error) {
+	output := w.Mock.Called(ctx, playerID, currency)
+	limits := output.Get(0)
+	err := output.Error(1)
+	return limits.([]domain.Limit), err
+}
+
+func (w *WalletRepositoryMock) SaveLimit(ctx context.Context, limit *domain.Limit) error {
+	output := w.Mock.Called(ctx, limit)
+	err := output.Error(0)
+	return err
+}
+
+func (w *WalletRepositoryMock) SumActivity(ctx context.Context, playerID int, currency string, since time.Time) (domain.WalletActivity, error) {
+	output := w.Mock.Called(ctx, playerID, currency, since)
+	activity := output.Get(0)
+	err := output.Error(1)
+	return activity.(domain.WalletActivity), err
+}
+
+func (w *WalletRepositoryMock) Update(ctx context.Context, wallet *domain.Wallet) error {
 	output := w.Mock.Called(ctx, wallet)
 	err := output.Error(0)
 	return err
 }
+
+func (w *WalletRepositoryMock) UpdateStatus(ctx context.Context, wallet *domain.Wallet, change *domain.WalletStatusChange) error {
+	output := w.Mock.Called(ctx, wallet, change)
+	err := output.Error(0)
+	return err
+}
+
+func (w *WalletRepositoryMock) ListWallets(ctx context.Context, afterID, limit int) ([]domain.Wallet, error) {
+	output := w.Mock.Called(ctx, afterID, limit)
+	wallets := output.Get(0)
+	err := output.Error(1)
+	return wallets.([]domain.Wallet), err
+}
+
+func (w *WalletRepositoryMock) LedgerBalances(ctx context.Context, walletIDs []int) (map[int]decimal.Decimal, error) {
+	output := w.Mock.Called(ctx, walletIDs)
+	balances := output.Get(0)
+	err := output.Error(1)
+	return balances.(map[int]decimal.Decimal), err
+}
+
+func (w *WalletRepositoryMock) LatestSnapshot(ctx context.Context, walletID int, at time.Time) (domain.BalanceSnapshot, error) {
+	output := w.Mock.Called(ctx, walletID, at)
+	snapshot := output.Get(0)
+	err := output.Error(1)
+	return snapshot.(domain.BalanceSnapshot), err
+}
+
+func (w *WalletRepositoryMock) CreateSnapshot(ctx context.Context, snapshot *domain.BalanceSnapshot) error {
+	output := w.Mock.Called(ctx, snapshot)
+	err := output.Error(0)
+	return err
+}
+
+func (w *WalletRepositoryMock) SumTransactions(ctx context.Context, walletID int, from, to time.Time) (decimal.Decimal, int, error) {
+	output := w.Mock.Called(ctx, walletID, from, to)
+	sum := output.Get(0)
+	err := output.Error(2)
+	return sum.(decimal.Decimal), output.Int(1), err
+}
+
+// Transaction runs fn against the mock itself unless the expectation
+// returns an error, which simulates failing to open the transaction.
+func (w *WalletRepositoryMock) Transaction(ctx context.Context, fn func(r domain.WalletRepository) error) error {
+	output := w.Mock.Called(ctx)
+	if err := output.Error(0); err != nil {
+		return err
+	}
+	return fn(w)
+}
+
+func (w *WalletRepositoryMock) StreamTransactions(ctx context.Context, walletID int, from, to time.Time, fn func(t domain.Transaction) error) error {
+	output := w.Mock.Called(ctx, walletID, from, to)
+	transactions := output.Get(0)
+	for _, transaction := range transactions.([]domain.Transaction) {
+		if err := fn(transaction); err != nil {
+			return err
+		}
+	}
+	return output.Error(1)
+}
+
+func (w *WalletRepositoryMock) Contribute(ctx context.Context, transaction *domain.Transaction) error {
+	output := w.Mock.Called(ctx, transaction)
+	return output.Error(0)
+}
+
+func (w *WalletRepositoryMock) ListContributions(ctx context.Context, debitID int) ([]domain.Transaction, error) {
+	output := w.Mock.Called(ctx, debitID)
+	transactions := output.Get(0)
+	err := output.Error(1)
+	return transactions.([]domain.Transaction), err
+}
+
+func (w *WalletRepositoryMock) BookOpeningBalance(ctx context.Context, walletID int) (*domain.Transaction, error) {
+	output := w.Mock.Called(ctx, walletID)
+	transaction := output.Get(0)
+	err := output.Error(1)
+	return transaction.(*domain.Transaction), err
+}
+
+func (w *WalletRepositoryMock) JournalMissing(ctx context.Context, limit int) (int, error) {
+	output := w.Mock.Called(ctx, limit)
+	return output.Int(0), output.Error(1)
+}
+
+func (w *WalletRepositoryMock) CreateForPlayer(ctx context.Context, wallet *domain.Wallet, maxWallets int) error {
+	output := w.Mock.Called(ctx, wallet, maxWallets)
+	return output.Error(0)
+}
+
+func (w *WalletRepositoryMock) ListByPlayer(ctx context.Context, playerID int) ([]domain.Wallet, error) {
+	output := w.Mock.Called(ctx, playerID)
+	wallets := output.Get(0)
+	err := output.Error(1)
+	return wallets.([]domain.Wallet), err
+}
+
+func (w *WalletRepositoryMock) SetDefault(ctx context.Context, wallet *domain.Wallet) error {
+	output := w.Mock.Called(ctx, wallet)
+	return output.Error(0)
+}
+
+func (w *WalletRepositoryMock) SetLabel(ctx context.Context, wallet *domain.Wallet) error {
+	output := w.Mock.Called(ctx, wallet)
+	return output.Error(0)
+}
@@ -12,12 +12,15 @@ var (
 )
 
 type Player struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	UpdatedAt time.Time `json:"updated_at"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                   int        `json:"id"`
+	Name                 string     `json:"name"`
+	Email                string     `json:"email"`
+	Password             string     `json:"password"`
+	ExcludedFrom         *time.Time `json:"excluded_from"`
+	ExcludedUntil        *time.Time `json:"excluded_until"`
+	ExcludedIndefinitely bool       `json:"excluded_indefinitely" gorm:"not null;default:false"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+	CreatedAt            time.Time  `json:"created_at"`
 }
 
 type PlayerService interface {
@@ -26,6 +29,9 @@ type PlayerService interface {
 	Update(ctx context.Context, id string, player *Player, updatedPlayer Player) error
 	Delete(ctx context.Context, id string, player *Player) error
 	FindByEmail(ctx context.Context, email string, player *Player) error
+	// SelfExclude starts or extends the player's exclusion. An active
+	// exclusion can never be shortened or lifted.
+	SelfExclude(ctx context.Context, id string, exclusion Exclusion) (Player, error)
 }
 
 type PlayerRepository interface {
@@ -34,4 +40,6 @@ type PlayerRepository interface {
 	Get(ctx context.Context, id string) (Player, error)
 	Delete(ctx context.Context, id string, player *Player) error
 	FindByEmail(ctx context.Context, email string, player *Player) error
+	// UpdateExclusion writes only the player's exclusion fields.
+	UpdateExclusion(ctx context.Context, player *Player) error
 }
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrRateNotFound = errors.New("exchange rate not found")
+
+// ExchangeRate states how many units of Quote one unit of Base buys.
+type ExchangeRate struct {
+	ID        int             `json:"id"`
+	Base      string          `json:"base" gorm:"type:char(3);uniqueIndex:idx_exchange_rate_pair"`
+	Quote     string          `json:"quote" gorm:"type:char(3);uniqueIndex:idx_exchange_rate_pair"`
+	Rate      decimal.Decimal `json:"rate" gorm:"type:decimal(20,10)"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type RoundingMode string
+
+const (
+	RoundingHalfEven RoundingMode = "half_even"
+	RoundingHalfUp   RoundingMode = "half_up"
+	RoundingDown     RoundingMode = "down"
+)
+
+// ConversionConfig controls how cross-currency transfers are priced. Spread
+// is the fraction taken off the mid rate, e.g. 0.015 for 1.5%.
+type ConversionConfig struct {
+	Spread   decimal.Decimal
+	Rounding RoundingMode
+}
+
+// Round rounds an amount to the given number of decimal places using the
+// configured mode, defaulting to banker's rounding.
+func (c ConversionConfig) Round(amount decimal.Decimal, places int32) decimal.Decimal {
+	switch c.Rounding {
+	case RoundingHalfUp:
+		return amount.Round(places)
+	case RoundingDown:
+		return amount.RoundDown(places)
+	default:
+		return amount.RoundBank(places)
+	}
+}
+
+type RateProvider interface {
+	// Rate returns the rate converting base into quote, deriving it from the
+	// inverse pair when only that one is stored.
+	Rate(ctx context.Context, base, quote string) (ExchangeRate, error)
+	SetRate(ctx context.Context, rate *ExchangeRate) error
+}
@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BalanceMismatch describes a wallet whose stored balance disagrees with its
+// ledger, or whose cached copy disagrees with the stored balance.
+type BalanceMismatch struct {
+	WalletID      int              `json:"walletId"`
+	StoredBalance decimal.Decimal  `json:"stored_balance"`
+	LedgerBalance decimal.Decimal  `json:"ledger_balance"`
+	CachedBalance *decimal.Decimal `json:"cached_balance,omitempty"`
+	LedgerDrift   bool             `json:"ledger_drift"`
+	CacheDrift    bool             `json:"cache_drift"`
+	CacheRepaired bool             `json:"cache_repaired"`
+}
+
+// ReconciliationReport is the outcome of checking every wallet against its
+// ledger and cache.
+type ReconciliationReport struct {
+	StartedAt      time.Time         `json:"started_at"`
+	FinishedAt     time.Time         `json:"finished_at"`
+	WalletsChecked int               `json:"wallets_checked"`
+	Mismatches     []BalanceMismatch `json:"mismatches"`
+}
+
+// Consistent reports whether no wallet drifted from its ledger. Stale cache
+// entries that were repaired do not count against it.
+func (r ReconciliationReport) Consistent() bool {
+	for _, m := range r.Mismatches {
+		if m.LedgerDrift || (m.CacheDrift && !m.CacheRepaired) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidRoundRequest = errors.New("provider, round and transaction IDs are required")
+	ErrRoundClosed         = errors.New("round is closed")
+	ErrRoundWalletMismatch = errors.New("round belongs to another wallet")
+	// ErrRoundActionConflict is returned when a provider transaction ID is
+	// reused for a different operation.
+	ErrRoundActionConflict = errors.New("provider transaction ID already used for another operation")
+	ErrRoundActionPending  = errors.New("provider transaction is still being processed")
+	ErrBetRolledBack       = errors.New("bet has already been rolled back")
+	ErrRollbackNotBet      = errors.New("only bets can be rolled back")
+	ErrProviderMismatch    = errors.New("request names another provider than its key")
+	// ErrActionExists is returned by the repository when an action with the
+	// same provider transaction ID, or a rollback of the same bet, exists.
+	ErrActionExists = errors.New("round action already recorded")
+)
+
+type RoundStatus string
+
+const (
+	RoundStatusOpen   RoundStatus = "open"
+	RoundStatusClosed RoundStatus = "closed"
+)
+
+// Round is a game round reported by a provider, identified by the
+// provider's own round ID. Bets and wins of the round move funds on a single
+// wallet.
+type Round struct {
+	ID              int             `json:"id"`
+	Provider        string          `json:"provider" gorm:"type:varchar(64);uniqueIndex:idx_round_provider_round"`
+	ProviderRoundID string          `json:"provider_round_id" gorm:"type:varchar(64);uniqueIndex:idx_round_provider_round"`
+	WalletID        int             `json:"walletId" gorm:"index"`
+	PlayerID        int             `json:"playerId"`
+	Status          RoundStatus     `json:"status" gorm:"type:varchar(16)"`
+	TotalBet        decimal.Decimal `json:"total_bet" gorm:"type:decimal(20,8);not null;default:0"`
+	TotalWin        decimal.Decimal `json:"total_win" gorm:"type:decimal(20,8);not null;default:0"`
+	ClosedAt        *time.Time      `json:"closed_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+func (Round) TableName() string {
+	return "game_rounds"
+}
+
+type RoundActionType string
+
+const (
+	RoundActionBet      RoundActionType = "bet"
+	RoundActionWin      RoundActionType = "win"
+	RoundActionRollback RoundActionType = "rollback"
+	// RoundActionTombstone claims the provider transaction ID of a bet
+	// rolled back before it arrived, so that the bet is refused when it does.
+	RoundActionTombstone RoundActionType = "tombstone"
+)
+
+type RoundActionStatus string
+
+const (
+	// RoundActionPending actions have claimed their provider transaction ID
+	// but their wallet movement has not completed yet.
+	RoundActionPending   RoundActionStatus = "pending"
+	RoundActionCompleted RoundActionStatus = "completed"
+)
+
+// RoundAction is one provider operation on a round, unique per provider
+// transaction ID. Rollbacks name the bet they undo in RollbackOf; a rollback
+// of a bet never seen is kept without TransactionID and leaves a tombstone on
+// the bet's provider transaction ID, so that the bet is refused should it
+// arrive later.
+type RoundAction struct {
+	ID                    int               `json:"id"`
+	RoundID               int               `json:"roundId" gorm:"index"`
+	Provider              string            `json:"provider" gorm:"type:varchar(64);uniqueIndex:idx_round_action_transaction;uniqueIndex:idx_round_action_rollback_of"`
+	ProviderTransactionID string            `json:"provider_transaction_id" gorm:"type:varchar(64);uniqueIndex:idx_round_action_transaction"`
+	Type                  RoundActionType   `json:"type" gorm:"type:varchar(16)"`
+	Amount                decimal.Decimal   `json:"amount" gorm:"type:decimal(20,8)"`
+	RollbackOf            *string           `json:"rollback_of,omitempty" gorm:"type:varchar(64);uniqueIndex:idx_round_action_rollback_of"`
+	Status                RoundActionStatus `json:"status" gorm:"type:varchar(16)"`
+	TransactionID         *int              `json:"transactionId,omitempty"`
+	UpdatedAt             time.Time         `json:"updated_at"`
+	CreatedAt             time.Time         `json:"created_at"`
+}
+
+func (RoundAction) TableName() string {
+	return "game_round_actions"
+}
+
+// RoundRequest is a provider operation. WalletID is needed to open a round
+// and RollbackTransactionID names the bet a rollback undoes.
+type RoundRequest struct {
+	Provider              string `json:"provider"`
+	RoundID               string `json:"round_id"`
+	TransactionID         string `json:"transaction_id"`
+	WalletID              string `json:"wallet_id"`
+	Amount                string `json:"amount"`
+	RollbackTransactionID string `json:"rollback_transaction_id"`
+}
+
+const maxProviderIDWidth = 64
+
+// Valid reports whether the request names its provider, round and
+// transaction with IDs that fit their columns.
+func (r RoundRequest) Valid() bool {
+	for _, id := range []string{r.Provider, r.RoundID, r.TransactionID} {
+		if id == "" || len(id) > maxProviderIDWidth {
+			return false
+		}
+	}
+	return len(r.RollbackTransactionID) <= maxProviderIDWidth
+}
+
+// RoundResult is what a provider operation did, with the wallet's balance
+// after it.
+type RoundResult struct {
+	Round   Round           `json:"round"`
+	Action  RoundAction     `json:"action"`
+	Balance decimal.Decimal `json:"balance"`
+}
+
+type RoundService interface {
+	// Bet debits the stake, opening the round on its first bet. It is
+	// refused if the bet was rolled back before it arrived.
+	Bet(ctx context.Context, req RoundRequest, actorID int) (RoundResult, error)
+	Win(ctx context.Context, req RoundRequest, actorID int) (RoundResult, error)
+	// Rollback refunds a bet. Rolling back a bet never seen moves no funds
+	// but succeeds, and makes the bet be refused should it arrive later.
+	Rollback(ctx context.Context, req RoundRequest, actorID int) (RoundResult, error)
+	Close(ctx context.Context, provider, roundID string) (Round, error)
+}
+
+type RoundRepository interface {
+	GetRound(ctx context.Context, provider, roundID string) (Round, error)
+	// FindOrCreateRound loads the round with r's provider and round ID into
+	// r, creating it from r if there is none yet.
+	FindOrCreateRound(ctx context.Context, r *Round) error
+	CloseRound(ctx context.Context, r *Round) error
+	GetAction(ctx context.Context, provider, transactionID string) (RoundAction, error)
+	// StartAction records the action, pending unless it is a tombstone,
+	// failing with ErrActionExists if its provider transaction ID, or the bet
+	// it rolls back, is taken.
+	StartAction(ctx context.Context, a *RoundAction) error
+	// CompleteAction marks the pending action completed and adds its
+	// amount to the round's totals, failing with ErrRoundActionPending if
+	// the action is no longer pending.
+	CompleteAction(ctx context.Context, r *Round, a *RoundAction) error
+	// DeleteAction drops a pending action whose wallet movement failed, or
+	// never happened, so the provider can retry it.
+	DeleteAction(ctx context.Context, a *RoundAction) error
+}
@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidRecurrence = errors.New("invalid recurrence")
+	ErrScheduleInPast    = errors.New("schedule must start in the future")
+	ErrScheduleNotActive = errors.New("schedule is no longer active")
+	ErrLeaseLost         = errors.New("schedule lease lost")
+	// ErrExecutionExists is returned when an occurrence of a schedule has
+	// already been executed, or is being executed, by another instance.
+	ErrExecutionExists = errors.New("schedule execution already recorded")
+)
+
+// Recurrence is how often a schedule repeats after its first run.
+type Recurrence string
+
+const (
+	RecurrenceOnce    Recurrence = "once"
+	RecurrenceDaily   Recurrence = "daily"
+	RecurrenceWeekly  Recurrence = "weekly"
+	RecurrenceMonthly Recurrence = "monthly"
+)
+
+func (r Recurrence) Valid() bool {
+	switch r {
+	case RecurrenceOnce, RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly:
+		return true
+	}
+	return false
+}
+
+// Next returns the first occurrence after both the occurrence at and now, so
+// that runs missed while no instance was up are skipped rather than
+// replayed. Occurrences are counted from start, the first run, so a monthly
+// schedule starting on the 31st runs on the last day of shorter months and
+// on the 31st again after them. It returns nil for one-off schedules.
+func (r Recurrence) Next(start, at, now time.Time) *time.Time {
+	if !r.Valid() || r == RecurrenceOnce {
+		return nil
+	}
+	for n := 1; ; n++ {
+		next := r.occurrence(start, n)
+		if next.After(at) && next.After(now) {
+			return &next
+		}
+	}
+}
+
+// occurrence is the nth run after start.
+func (r Recurrence) occurrence(start time.Time, n int) time.Time {
+	switch r {
+	case RecurrenceDaily:
+		return start.AddDate(0, 0, n)
+	case RecurrenceWeekly:
+		return start.AddDate(0, 0, 7*n)
+	}
+	// AddDate would normalise Jan 31 plus a month to Mar 3; stay on the
+	// last day of the month instead.
+	year, month, day := start.Date()
+	first := time.Date(year, month+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+type ScheduleStatus string
+
+const (
+	ScheduleStatusActive    ScheduleStatus = "active"
+	ScheduleStatusCompleted ScheduleStatus = "completed"
+	ScheduleStatusCancelled ScheduleStatus = "cancelled"
+)
+
+// Schedule credits a wallet at NextRunAt and then, unless it runs once,
+// again on every recurrence counted from StartsAt. An instance running it
+// holds a lease on it until LeaseUntil so that no other instance picks it up
+// meanwhile.
+type Schedule struct {
+	ID          int             `json:"id"`
+	WalletID    int             `json:"walletId" gorm:"index"`
+	PlayerID    int             `json:"playerId"`
+	Amount      decimal.Decimal `json:"amount" gorm:"type:decimal(20,8)"`
+	Recurrence  Recurrence      `json:"recurrence" gorm:"type:varchar(16)"`
+	Status      ScheduleStatus  `json:"status" gorm:"type:varchar(16);index:idx_schedule_status_next_run"`
+	StartsAt    *time.Time      `json:"starts_at"`
+	NextRunAt   *time.Time      `json:"next_run_at" gorm:"index:idx_schedule_status_next_run"`
+	LeaseOwner  string          `json:"-" gorm:"type:varchar(128)"`
+	LeaseUntil  *time.Time      `json:"-"`
+	Description string          `json:"description" gorm:"type:varchar(255)"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func (Schedule) TableName() string {
+	return "wallet_schedules"
+}
+
+type ExecutionStatus string
+
+const (
+	ExecutionStatusRunning   ExecutionStatus = "running"
+	ExecutionStatusSucceeded ExecutionStatus = "succeeded"
+	ExecutionStatusFailed    ExecutionStatus = "failed"
+)
+
+// ScheduleExecution records one occurrence of a schedule. It is written
+// before the wallet is credited and is unique per occurrence, so a credit is
+// never repeated. An execution left running means its instance stopped
+// mid-credit or could not record the outcome; the next instance to claim the
+// schedule finishes it, looking for the credit by its reference before
+// crediting again.
+type ScheduleExecution struct {
+	ID            int             `json:"id"`
+	ScheduleID    int             `json:"scheduleId" gorm:"uniqueIndex:idx_execution_occurrence"`
+	ScheduledFor  time.Time       `json:"scheduled_for" gorm:"uniqueIndex:idx_execution_occurrence"`
+	WalletID      int             `json:"walletId"`
+	Amount        decimal.Decimal `json:"amount" gorm:"type:decimal(20,8)"`
+	Status        ExecutionStatus `json:"status" gorm:"type:varchar(16)"`
+	TransactionID *int            `json:"transactionId,omitempty"`
+	Error         string          `json:"error,omitempty" gorm:"type:varchar(255)"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+func (ScheduleExecution) TableName() string {
+	return "wallet_schedule_executions"
+}
+
+type ScheduleService interface {
+	// Create schedules credits of amount to the wallet, first at runAt.
+	Create(ctx context.Context, walletID, amount string, runAt time.Time, recurrence Recurrence, description string) (Schedule, error)
+	ListByWallet(ctx context.Context, walletID string) ([]Schedule, error)
+	Cancel(ctx context.Context, id string) (Schedule, error)
+	ListExecutions(ctx context.Context, id string) ([]ScheduleExecution, error)
+	// RunDue credits every schedule that has come due and is not leased by
+	// another instance, and returns how many it ran.
+	RunDue(ctx context.Context) (int, error)
+}
+
+type ScheduleRepository interface {
+	Create(ctx context.Context, s *Schedule) error
+	Get(ctx context.Context, id string) (Schedule, error)
+	ListByWallet(ctx context.Context, walletID string) ([]Schedule, error)
+	// Cancel stops an active schedule, failing with ErrScheduleNotActive
+	// otherwise.
+	Cancel(ctx context.Context, s *Schedule) error
+	// ClaimDue leases up to limit active schedules due at now to owner until
+	// leaseUntil, skipping those another owner still holds, and returns them.
+	ClaimDue(ctx context.Context, owner string, now, leaseUntil time.Time, limit int) ([]Schedule, error)
+	// StartExecution records an occurrence about to run, failing with
+	// ErrExecutionExists if it was recorded before.
+	StartExecution(ctx context.Context, e *ScheduleExecution) error
+	// GetExecution loads the execution of the schedule's occurrence at
+	// scheduledFor.
+	GetExecution(ctx context.Context, scheduleID int, scheduledFor time.Time) (ScheduleExecution, error)
+	// FinishExecution stores the outcome of the execution, when e is not
+	// nil, and then the schedule's next run, releasing owner's lease. It
+	// fails with ErrLeaseLost if owner no longer holds the lease, in which
+	// case only the execution is stored.
+	FinishExecution(ctx context.Context, owner string, s *Schedule, e *ScheduleExecution) error
+	ListExecutions(ctx context.Context, scheduleID int) ([]ScheduleExecution, error)
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrBalanceTimeInFuture = errors.New("balance time must not be in the future")
+
+// BalanceSnapshot records a wallet's ledger balance as of a moment: the sum of
+// every transaction created at or before AsOf. Point-in-time balances start
+// from the latest snapshot and only sum the transactions after it.
+type BalanceSnapshot struct {
+	ID        int             `json:"id"`
+	WalletID  int             `json:"walletId" gorm:"index:idx_snapshot_wallet_as_of"`
+	Balance   decimal.Decimal `json:"balance" gorm:"type:decimal(20,8)"`
+	AsOf      time.Time       `json:"as_of" gorm:"index:idx_snapshot_wallet_as_of"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func (BalanceSnapshot) TableName() string {
+	return "wallet_balance_snapshots"
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidStatementPeriod = errors.New("statement period must end after it starts")
+
+// Statement summarises a wallet over the period after From up to and
+// including To. ClosingBalance is OpeningBalance plus every transaction of
+// the period.
+type Statement struct {
+	WalletID       int             `json:"walletId"`
+	Currency       string          `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+	ClosingBalance decimal.Decimal `json:"closing_balance"`
+	Transactions   int             `json:"transactions"`
+}
+
+// StatementWriter receives a statement as it is produced: the opening
+// balance, each transaction of the period in order, then the closing
+// balance. Returning an error stops the statement.
+type StatementWriter interface {
+	Opening(s Statement) error
+	Transaction(t Transaction) error
+	Closing(s Statement) error
+}
@@ -0,0 +1,195 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrAlreadyReversed          = errors.New("transaction has already been fully reversed")
+	ErrTransactionNotReversible = errors.New("transaction cannot be reversed")
+	ErrInvalidCategory          = errors.New("invalid transaction category")
+	ErrInvalidMetadata          = errors.New("invalid transaction metadata")
+	ErrDetailsTooLong           = errors.New("transaction reference or description is too long")
+)
+
+type TransactionType string
+
+const (
+	TransactionTypeCredit TransactionType = "credit"
+	TransactionTypeDebit  TransactionType = "debit"
+)
+
+// TransactionCategory tells what a ledger entry was for, so that reporting
+// can, for example, separate deposits from winnings.
+type TransactionCategory string
+
+const (
+	CategoryDeposit    TransactionCategory = "deposit"
+	CategoryWithdrawal TransactionCategory = "withdrawal"
+	CategoryBet        TransactionCategory = "bet"
+	CategoryWin        TransactionCategory = "win"
+	CategoryBonus      TransactionCategory = "bonus"
+	CategoryAdjustment TransactionCategory = "adjustment"
+	// CategoryPromotion marks promotional cash, such as scheduled credits.
+	// Unlike bonus funds it carries no wagering requirement and can be
+	// withdrawn.
+	CategoryPromotion TransactionCategory = "promotion"
+	// CategoryJackpot marks the movements of jackpot pools: contributions,
+	// payouts out of a pool and reseeds. Only the wallet service books it, so
+	// it is not valid on credit or debit requests.
+	CategoryJackpot TransactionCategory = "jackpot"
+	// CategoryOpeningBalance marks the entry that brings into the ledger a
+	// balance the wallet held before it had one. Only the wallet service
+	// books it.
+	CategoryOpeningBalance TransactionCategory = "opening_balance"
+)
+
+// Valid reports whether the category fits a transaction of type t. Empty
+// categories are valid for both.
+func (c TransactionCategory) Valid(t TransactionType) bool {
+	switch c {
+	case "", CategoryAdjustment:
+		return true
+	case CategoryDeposit, CategoryWin, CategoryBonus, CategoryPromotion:
+		return t == TransactionTypeCredit
+	case CategoryWithdrawal, CategoryBet:
+		return t == TransactionTypeDebit
+	}
+	return false
+}
+
+const (
+	maxMetadataEntries    = 20
+	maxMetadataValueWidth = 255
+	maxReferenceWidth     = 128
+	maxDescriptionWidth   = 255
+)
+
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// Metadata holds caller-defined key/value pairs of a transaction, stored as
+// a JSON object.
+type Metadata map[string]string
+
+func (m Metadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func (m *Metadata) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, m)
+	case string:
+		return json.Unmarshal([]byte(v), m)
+	}
+	return fmt.Errorf("cannot scan %T into Metadata", value)
+}
+
+// ValidMetadataKey reports whether key may be used in transaction metadata.
+func ValidMetadataKey(key string) bool {
+	return metadataKeyPattern.MatchString(key)
+}
+
+// TransactionDetails are what a caller may attach to a credit or debit to
+// describe it.
+type TransactionDetails struct {
+	Reference   string              `json:"reference"`
+	Description string              `json:"description"`
+	Category    TransactionCategory `json:"category"`
+	Metadata    Metadata            `json:"metadata"`
+}
+
+// Validate checks the details of a transaction of type t.
+func (d TransactionDetails) Validate(t TransactionType) error {
+	if !d.Category.Valid(t) {
+		return ErrInvalidCategory
+	}
+	if len(d.Reference) > maxReferenceWidth || len(d.Description) > maxDescriptionWidth {
+		return ErrDetailsTooLong
+	}
+	if len(d.Metadata) > maxMetadataEntries {
+		return ErrInvalidMetadata
+	}
+	for key, value := range d.Metadata {
+		if !ValidMetadataKey(key) || len(value) > maxMetadataValueWidth {
+			return ErrInvalidMetadata
+		}
+	}
+	return nil
+}
+
+// Apply copies the details onto the transaction.
+func (d TransactionDetails) Apply(t *Transaction) {
+	t.Reference = d.Reference
+	t.Description = d.Description
+	t.Category = d.Category
+	t.Metadata = d.Metadata
+}
+
+// Transaction is an immutable ledger entry recording a single balance
+// mutation on a wallet. Rows are only ever appended, never updated.
+// BonusAmount is the part of Amount paid from or into bonus funds and
+// BonusConverted what the entry's wagering turned from bonus funds into cash
+// or, when negative, what its reversal locked back up. Deposit
+// and Withdrawal mark player deposits and withdrawals. Reference,
+// Description, Category and Metadata are supplied by the caller.
+type Transaction struct {
+	ID             int                 `json:"id"`
+	WalletID       int                 `json:"walletId" gorm:"index;index:idx_transaction_wallet_created"`
+	PlayerID       int                 `json:"playerId"`
+	Type           TransactionType     `json:"type" gorm:"type:varchar(16)"`
+	Amount         decimal.Decimal     `json:"amount" gorm:"type:decimal(20,8)"`
+	BalanceBefore  decimal.Decimal     `json:"balance_before" gorm:"type:decimal(20,8)"`
+	BalanceAfter   decimal.Decimal     `json:"balance_after" gorm:"type:decimal(20,8)"`
+	TransferID     *int                `json:"transferId,omitempty" gorm:"index"`
+	HoldID         *int                `json:"holdId,omitempty" gorm:"index"`
+	ReversalOfID   *int                `json:"reversalOfId,omitempty" gorm:"index"`
+	BonusID        *int                `json:"bonusId,omitempty" gorm:"index"`
+	BonusAmount    decimal.Decimal     `json:"bonus_amount" gorm:"type:decimal(20,8);not null;default:0"`
+	BonusConverted decimal.Decimal     `json:"bonus_converted" gorm:"type:decimal(20,8);not null;default:0"`
+	ExchangeRate   *decimal.Decimal    `json:"exchange_rate,omitempty" gorm:"type:decimal(20,10)"`
+	Deposit        bool                `json:"deposit" gorm:"not null;default:false"`
+	Withdrawal     bool                `json:"withdrawal" gorm:"not null;default:false"`
+	Reference      string              `json:"reference,omitempty" gorm:"type:varchar(128);index"`
+	Description    string              `json:"description,omitempty" gorm:"type:varchar(255)"`
+	Category       TransactionCategory `json:"category,omitempty" gorm:"type:varchar(16);index"`
+	Metadata       Metadata            `json:"metadata,omitempty" gorm:"type:json"`
+	CreatedAt      time.Time           `json:"created_at" gorm:"index;index:idx_transaction_wallet_created"`
+}
+
+func (Transaction) TableName() string {
+	return "wallet_transactions"
+}
+
+// TransactionFilter narrows a wallet's transaction history. Zero values
+// disable the corresponding filter. Like statements and point-in-time
+// balances, the period covers transactions created after From up to and
+// including To. Results are ordered newest first and Cursor is the ID of the
+// last transaction of the previous page.
+type TransactionFilter struct {
+	Type      TransactionType
+	MinAmount *decimal.Decimal
+	MaxAmount *decimal.Decimal
+	From      time.Time
+	To        time.Time
+	Category  TransactionCategory
+	Reference string
+	// Metadata keeps transactions carrying every one of its key/value pairs.
+	Metadata Metadata
+	Cursor   int
+	Limit    int
+}
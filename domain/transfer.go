@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrSameWallet = errors.New("source and destination wallets must differ")
+
+// Transfer moves funds between two wallets. Both ledger legs reference it
+// through Transaction.TransferID. Amount is in the source wallet currency and
+// ToAmount in the destination one; they only differ when ExchangeRate is set.
+type Transfer struct {
+	ID           int              `json:"id"`
+	FromWalletID int              `json:"fromWalletId" gorm:"index"`
+	ToWalletID   int              `json:"toWalletId" gorm:"index"`
+	PlayerID     int              `json:"playerId"`
+	Amount       decimal.Decimal  `json:"amount" gorm:"type:decimal(20,8)"`
+	ToAmount     decimal.Decimal  `json:"to_amount" gorm:"type:decimal(20,8)"`
+	ExchangeRate *decimal.Decimal `json:"exchange_rate,omitempty" gorm:"type:decimal(20,10)"`
+	Debit        Transaction      `json:"debit" gorm:"-"`
+	Credit       Transaction      `json:"credit" gorm:"-"`
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+func (Transfer) TableName() string {
+	return "wallet_transfers"
+}
@@ -12,28 +12,252 @@ var (
 	ErrKeyNotFound       = errors.New("key not found")
 	ErrInsufficientFunds = errors.New("insufficient fund")
 	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrEditConflict      = errors.New("edit conflict")
+	// ErrWalletLimitReached is returned when a player already holds as many
+	// wallets as they may.
+	ErrWalletLimitReached = errors.New("player holds the maximum number of wallets")
+	ErrInvalidWalletLabel = errors.New("wallet label must be at most 64 characters")
 )
 
+const maxWalletLabelWidth = 64
+
+// ValidWalletLabel reports whether label fits a wallet. Labels are optional.
+func ValidWalletLabel(label string) bool {
+	return len(label) <= maxWalletLabelWidth
+}
+
 type Wallet struct {
-	ID        int             `json:"id"`
-	PlayerID  int             `json:"playerId"`
-	Balance   decimal.Decimal `json:"balance"`
-	UpdatedAt time.Time       `json:"updated_at"`
-	CreatedAt time.Time       `json:"created_at"`
+	ID        int    `json:"id"`
+	PlayerID  int    `json:"playerId" gorm:"index"`
+	Label     string `json:"label" gorm:"type:varchar(64)"`
+	IsDefault bool   `json:"is_default" gorm:"not null;default:false"`
+	// Pool marks the wallet of a jackpot pool. Only payouts may take funds
+	// out of it.
+	Pool         bool            `json:"pool" gorm:"not null;default:false"`
+	Balance      decimal.Decimal `json:"balance"`
+	HeldBalance  decimal.Decimal `json:"held_balance" gorm:"type:decimal(20,8);not null;default:0"`
+	BonusBalance decimal.Decimal `json:"bonus_balance" gorm:"type:decimal(20,8);not null;default:0"`
+	CreditLimit  decimal.Decimal `json:"credit_limit" gorm:"type:decimal(20,8);not null;default:0"`
+	Currency     string          `json:"currency" gorm:"type:char(3);not null;default:EUR"`
+	Status       WalletStatus    `json:"status" gorm:"type:varchar(16);not null;default:active"`
+	StatusReason string          `json:"status_reason" gorm:"type:varchar(255)"`
+	Version      int             `json:"version" gorm:"not null;default:1"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// AvailableBalance is the part of the balance not reserved by active holds.
+func (w Wallet) AvailableBalance() decimal.Decimal {
+	return w.Balance.Sub(w.HeldBalance)
+}
+
+// SpendableBalance is what can be debited: the available balance plus the
+// wallet's credit line.
+func (w Wallet) SpendableBalance() decimal.Decimal {
+	return w.AvailableBalance().Add(w.CreditLimit)
+}
+
+// UsedCredit is how far the balance has gone below zero.
+func (w Wallet) UsedCredit() decimal.Decimal {
+	return decimal.Max(w.Balance.Neg(), decimal.Zero)
+}
+
+// Empty reports whether closing the wallet would strand nothing: no
+// balance, no bonus funds, nothing held and no credit in use.
+func (w Wallet) Empty() bool {
+	return w.Balance.IsZero() && w.BonusBalance.IsZero() && w.HeldBalance.IsZero() && w.UsedCredit().IsZero()
+}
+
+// CashBalance is the part of the balance not locked in bonuses.
+func (w Wallet) CashBalance() decimal.Decimal {
+	return w.Balance.Sub(w.BonusBalance)
 }
 
 type WalletService interface {
+	// Create opens the wallet. A player's first wallet becomes their
+	// default, as does one created while they have none, their default
+	// having closed, or with IsDefault set.
 	Create(ctx context.Context, w *Wallet) error
 	Get(ctx context.Context, id string) (Wallet, error)
-	Credit(ctx context.Context, id, amount string) error
-	Debit(ctx context.Context, id, amount string) error
+	// GetForPlayer loads the player's wallet, reporting wallets of other
+	// players as ErrRecordNotFound.
+	GetForPlayer(ctx context.Context, playerID int, id string) (Wallet, error)
+	// ListByPlayer returns the player's wallets, oldest first.
+	ListByPlayer(ctx context.Context, playerID int) ([]Wallet, error)
+	// SetDefault makes the player's wallet their default, in place of the
+	// previous one.
+	SetDefault(ctx context.Context, playerID int, id string) (Wallet, error)
+	SetLabel(ctx context.Context, playerID int, id, label string) (Wallet, error)
+	Credit(ctx context.Context, id, amount string, actorID int, details TransactionDetails) (Transaction, error)
+	// Debit takes amount from the wallet. Debits in the withdrawal category
+	// are handled as withdrawals.
+	Debit(ctx context.Context, id, amount string, actorID int, details TransactionDetails) (Transaction, error)
+	// Withdraw debits the wallet as a player withdrawal, which can only take
+	// cash and does not count as a wager.
+	Withdraw(ctx context.Context, id, amount string, actorID int, details TransactionDetails) (Transaction, error)
+	// Deposit credits the wallet as a player deposit, which counts towards
+	// and is refused over the player's deposit limits. Credits in the deposit
+	// category are handled as deposits, and deposits in any other category
+	// are refused with ErrInvalidCategory.
+	Deposit(ctx context.Context, id, amount string, actorID int, details TransactionDetails) (Transaction, error)
+	// SetLimit changes the limit of the given type and period of the wallet's
+	// owner in the wallet's currency; an empty amount removes it. Only tighter
+	// limits apply immediately.
+	SetLimit(ctx context.Context, id string, limitType LimitType, period LimitPeriod, amount string) (Limit, error)
+	// ListLimits reports the limits that apply to the wallet, those of its
+	// owner in its currency, with the allowance remaining in their current
+	// windows.
+	ListLimits(ctx context.Context, id string) ([]Limit, error)
+	ListTransactions(ctx context.Context, id string, filter TransactionFilter) ([]Transaction, int, error)
+	Transfer(ctx context.Context, fromID, toID, amount string, actorID int) (Transfer, error)
+	Hold(ctx context.Context, id, amount string, actorID int) (Hold, error)
+	// CaptureHold debits amount, or the whole hold when amount is empty, and
+	// releases any remainder.
+	CaptureHold(ctx context.Context, id, holdID, amount string, actorID int) (Transaction, error)
+	VoidHold(ctx context.Context, id, holdID string) (Hold, error)
+	ExpireHolds(ctx context.Context) (int, error)
+	// Reverse books a compensating entry for amount, or for whatever has
+	// not been reversed yet when amount is empty.
+	Reverse(ctx context.Context, transactionID, amount string, actorID int) (Transaction, error)
+	// Refund credits back what has not been reversed yet of a debit,
+	// returning any bonus funds it spent to the bonuses they came from.
+	Refund(ctx context.Context, transactionID string, actorID int, details TransactionDetails) (Transaction, error)
+	CreditBonus(ctx context.Context, id, amount, wageringMultiplier string, actorID int) (Bonus, error)
+	ListBonuses(ctx context.Context, id string) ([]Bonus, error)
+	// BalanceAt returns the wallet's balance as it stood at the given moment.
+	BalanceAt(ctx context.Context, walletID int, at time.Time) (decimal.Decimal, error)
+	// WriteStatement streams the wallet's statement for the period after from
+	// up to to into sw. A zero to, or one in the future, means now.
+	WriteStatement(ctx context.Context, id string, from, to time.Time, sw StatementWriter) error
+	// TakeSnapshots records a balance snapshot for every wallet that moved
+	// since its previous one and returns how many it recorded.
+	TakeSnapshots(ctx context.Context) (int, error)
+	// Batch applies credit and debit instructions either all in one database
+	// transaction or one by one, reporting the outcome of each.
+	Batch(ctx context.Context, items []BatchItem, mode BatchMode, actorID int) ([]BatchResult, error)
+	// SetCreditLimit lets the wallet be debited down to -amount. Lowering it
+	// below the credit already used only stops further debits.
+	SetCreditLimit(ctx context.Context, id, amount string) (Wallet, error)
+	// SetStatus moves the wallet to status, recording why. Closing requires
+	// a zero balance and closed wallets never reopen.
+	SetStatus(ctx context.Context, id string, status WalletStatus, reason string) (Wallet, error)
+	// Reconcile checks every wallet's stored balance against its ledger and
+	// cached copy, dropping stale cache entries when repairCache is set.
+	Reconcile(ctx context.Context, repairCache bool) (ReconciliationReport, error)
+	// OpenBalances books an opening entry for every wallet whose balance
+	// predates its ledger and journals the entries booked before the journal
+	// existed, returning how many opening entries it booked. It is a one-off
+	// migration that is safe to run again.
+	OpenBalances(ctx context.Context) (int, error)
+	// OpenJackpot creates the pool's wallet, stores the pool and seeds it
+	// from the house in a single database transaction, failing with
+	// ErrJackpotExists if its name is taken.
+	OpenJackpot(ctx context.Context, pool *JackpotPool, actorID int) error
+	// PayoutJackpot moves the pool's whole balance to the wallet and reseeds
+	// the pool in a single database transaction.
+	PayoutJackpot(ctx context.Context, pool JackpotPool, walletID string, actorID int) (JackpotPayout, error)
 }
 
 type WalletRepository interface {
 	Create(ctx context.Context, w *Wallet) error
+	// CreateForPlayer creates the player's wallet while holding a lock on the
+	// player, failing with ErrWalletLimitReached if they already hold
+	// maxWallets (0 for no cap). A wallet created while the player has no
+	// default, or with IsDefault set, becomes their only default.
+	CreateForPlayer(ctx context.Context, w *Wallet, maxWallets int) error
 	Get(ctx context.Context, id string) (Wallet, error)
-	Credit(ctx context.Context, w *Wallet) error
-	Debit(ctx context.Context, w *Wallet) error
+	ListByPlayer(ctx context.Context, playerID int) ([]Wallet, error)
+	// SetDefault makes the wallet its player's only default while holding a
+	// lock on the player, failing with ErrWalletClosed if the wallet has
+	// closed.
+	SetDefault(ctx context.Context, w *Wallet) error
+	SetLabel(ctx context.Context, w *Wallet) error
+	Credit(ctx context.Context, w *Wallet, t *Transaction) error
+	Debit(ctx context.Context, w *Wallet, t *Transaction) error
+	ListTransactions(ctx context.Context, id string, filter TransactionFilter) ([]Transaction, error)
+	CreateTransfer(ctx context.Context, t *Transfer) error
+	GetTransaction(ctx context.Context, id string) (Transaction, error)
+	ListBonuses(ctx context.Context, walletID int, status BonusStatus) ([]Bonus, error)
+	SaveBonus(ctx context.Context, b *Bonus) error
+	// CreateJackpot stores the pool, failing with ErrJackpotExists if its
+	// name is taken.
+	CreateJackpot(ctx context.Context, p *JackpotPool) error
+	// ReversedAmount sums the entries already reversing the transaction.
+	ReversedAmount(ctx context.Context, transactionID int) (decimal.Decimal, error)
+	GetHold(ctx context.Context, id string) (Hold, error)
+	ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]Hold, error)
+	// LockWallets locks the wallets for update, in ascending ID order, until
+	// the end of the surrounding transaction. Locking every wallet a
+	// transaction writes up front, always in the same order, keeps
+	// concurrent transactions over overlapping wallets from deadlocking.
+	// The wallets' owners are locked first, as LockPlayer would.
+	LockWallets(ctx context.Context, ids []int) error
+	// LockPlayer locks the player until the end of the surrounding
+	// transaction, before any of their wallets is written. Movements checked
+	// against the player's limits hold it from the check to the write.
+	LockPlayer(ctx context.Context, playerID int) error
+	// CountActiveHolds counts the holds of the wallet not yet captured,
+	// voided or expired.
+	CountActiveHolds(ctx context.Context, walletID int) (int, error)
+	// CreateHold stores the hold together with the wallet's new held balance.
+	CreateHold(ctx context.Context, w *Wallet, h *Hold) error
+	// SettleHold moves an active hold to its new status along with the
+	// wallet's new balances and, when t is not nil, the capture ledger entry.
+	// It returns ErrHoldNotActive if the hold was settled concurrently.
+	SettleHold(ctx context.Context, w *Wallet, h *Hold, t *Transaction) error
+	ListLimits(ctx context.Context, playerID int, currency string) ([]Limit, error)
+	// SaveLimit creates or updates the limit. Creating a limit that already
+	// exists for the player, currency, type and period returns
+	// ErrDuplicateRecord.
+	SaveLimit(ctx context.Context, l *Limit) error
+	// SumActivity totals the movements since the given time of the player's
+	// wallets in currency.
+	SumActivity(ctx context.Context, playerID int, currency string, since time.Time) (WalletActivity, error)
+	// Update writes the wallet's balances, status and credit limit, failing
+	// with ErrEditConflict if it changed since it was read.
+	Update(ctx context.Context, w *Wallet) error
+	// UpdateStatus writes the wallet's status along with its audit record.
+	// Closing a player's default wallet moves the default to their oldest
+	// wallet still open, if any, under the same lock as SetDefault.
+	UpdateStatus(ctx context.Context, w *Wallet, change *WalletStatusChange) error
+	// ListWallets pages through wallets in ID order, starting after afterID.
+	ListWallets(ctx context.Context, afterID, limit int) ([]Wallet, error)
+	// LedgerBalances recomputes the balance of each wallet from its
+	// transactions. Wallets without transactions are left out.
+	LedgerBalances(ctx context.Context, walletIDs []int) (map[int]decimal.Decimal, error)
+	// LatestSnapshot returns the wallet's most recent snapshot taken as of
+	// at or earlier, or ErrRecordNotFound if there is none.
+	LatestSnapshot(ctx context.Context, walletID int, at time.Time) (BalanceSnapshot, error)
+	CreateSnapshot(ctx context.Context, s *BalanceSnapshot) error
+	// SumTransactions nets the wallet's credits and debits created after
+	// from and at or before to, returning the sum and how many there were.
+	SumTransactions(ctx context.Context, walletID int, from, to time.Time) (decimal.Decimal, int, error)
+	// StreamTransactions calls fn for each of the wallet's transactions
+	// created after from and at or before to, oldest first, without loading
+	// them all at once. It stops at the first error fn returns.
+	StreamTransactions(ctx context.Context, walletID int, from, to time.Time, fn func(t Transaction) error) error
+	// BookOpeningBalance locks the wallet and, unless it already has an
+	// opening entry, appends one dated at the wallet's creation for the part
+	// of its balance the ledger does not account for. Snapshots of the wallet
+	// taken without it are dropped. It returns the entry, or nil when none
+	// was needed.
+	BookOpeningBalance(ctx context.Context, walletID int) (*Transaction, error)
+	// JournalMissing books the journal lines of up to limit transactions that
+	// have none, returning how many it journaled.
+	JournalMissing(ctx context.Context, limit int) (int, error)
+	// Contribute adds the credit t to its wallet's balance in place, without
+	// checking the wallet's version, and appends t with the resulting
+	// balances. It keeps busy pool wallets from failing every concurrent
+	// debit with ErrEditConflict. A debit t takes a contribution back out,
+	// cut down to what the wallet still holds; when it holds nothing, t is
+	// not booked and its amount is zeroed.
+	Contribute(ctx context.Context, t *Transaction) error
+	// ListContributions returns the jackpot entries booked for the debit:
+	// its contributions and what reversals of it took back out.
+	ListContributions(ctx context.Context, debitID int) ([]Transaction, error)
+	// Transaction runs fn against a repository bound to a single database
+	// transaction, committing only if fn returns nil.
+	Transaction(ctx context.Context, fn func(r WalletRepository) error) error
 }
 
 type WalletInMemoryDB interface {
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrWalletFrozen            = errors.New("wallet is frozen")
+	ErrWalletClosed            = errors.New("wallet is closed")
+	ErrPoolWallet              = errors.New("jackpot pool wallets can only be paid out")
+	ErrWalletNotEmpty          = errors.New("wallet must have no balance, holds or credit in use to close it")
+	ErrInvalidStatusTransition = errors.New("invalid wallet status transition")
+	ErrStatusReasonRequired    = errors.New("a reason is required")
+)
+
+type WalletStatus string
+
+const (
+	WalletStatusActive WalletStatus = "active"
+	// WalletStatusFrozen wallets still accept credits but refuse debits.
+	WalletStatusFrozen WalletStatus = "frozen"
+	// WalletStatusClosed wallets refuse every movement and cannot reopen.
+	WalletStatusClosed WalletStatus = "closed"
+)
+
+// CanCredit reports whether funds may be added to the wallet.
+func (w Wallet) CanCredit() error {
+	if w.Status == WalletStatusClosed {
+		return ErrWalletClosed
+	}
+	return nil
+}
+
+// CanDebit reports whether funds may leave or be reserved on the wallet.
+// Jackpot pool wallets never allow it; their funds leave by payout only.
+func (w Wallet) CanDebit() error {
+	if w.Pool {
+		return ErrPoolWallet
+	}
+	switch w.Status {
+	case WalletStatusFrozen:
+		return ErrWalletFrozen
+	case WalletStatusClosed:
+		return ErrWalletClosed
+	}
+	return nil
+}
+
+// WalletStatusChange is the audit record of a wallet moving between states.
+type WalletStatusChange struct {
+	ID        int          `json:"id"`
+	WalletID  int          `json:"walletId" gorm:"index"`
+	From      WalletStatus `json:"from" gorm:"type:varchar(16)"`
+	To        WalletStatus `json:"to" gorm:"type:varchar(16)"`
+	Reason    string       `json:"reason" gorm:"type:varchar(255)"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+func (WalletStatusChange) TableName() string {
+	return "wallet_status_changes"
+}
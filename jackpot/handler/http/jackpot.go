@@ -0,0 +1,144 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"quik/domain"
+	"quik/wallet/handler/middleware"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JackpotHandler struct {
+	JackpotService domain.JackpotService
+}
+
+func NewJackpotHandler(router *gin.Engine, js domain.JackpotService) {
+	handler := &JackpotHandler{
+		JackpotService: js,
+	}
+
+	api := router.Group("/api/v1")
+	api.POST("/jackpots", middleware.AuthAdmin(), handler.CreateJackpot)
+	api.GET("/jackpots", middleware.AuthAdmin(), handler.ListJackpots)
+	api.GET("/jackpots/:jackpot_id", middleware.AuthAdmin(), handler.GetJackpot)
+	api.POST("/jackpots/:jackpot_id/payout", middleware.AuthAdmin(), handler.PayoutJackpot)
+}
+
+func isValidInteger(value string) bool {
+	intValue, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || intValue < 1 {
+		return false
+	}
+	return true
+}
+
+func (j *JackpotHandler) CreateJackpot(c *gin.Context) {
+	var input struct {
+		Name             string `json:"name"`
+		Currency         string `json:"currency"`
+		ContributionRate string `json:"contribution_rate"`
+		SeedAmount       string `json:"seed_amount"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var ctx = context.TODO()
+	pool, err := j.JackpotService.Create(ctx, input.Name, input.Currency, input.ContributionRate, input.SeedAmount, 0)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrJackpotExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidJackpotName),
+			errors.Is(err, domain.ErrInvalidContributionRate),
+			errors.Is(err, domain.ErrUnsupportedCurrency):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidAmount),
+			errors.Is(err, domain.ErrAmountPrecision):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "jackpot created", "payload": pool})
+}
+
+func (j *JackpotHandler) ListJackpots(c *gin.Context) {
+	var ctx = context.TODO()
+	pools, err := j.JackpotService.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payload": pools})
+}
+
+func (j *JackpotHandler) GetJackpot(c *gin.Context) {
+	jackpotId := c.Param("jackpot_id")
+	if !isValidInteger(jackpotId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid jackpot id"})
+		return
+	}
+	var ctx = context.TODO()
+	pool, err := j.JackpotService.Get(ctx, jackpotId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"payload": pool})
+}
+
+func (j *JackpotHandler) PayoutJackpot(c *gin.Context) {
+	var input struct {
+		WalletID string `json:"wallet_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	jackpotId := c.Param("jackpot_id")
+	if !isValidInteger(jackpotId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid jackpot id"})
+		return
+	}
+	if !isValidInteger(input.WalletID) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	var ctx = context.TODO()
+	payout, err := j.JackpotService.Payout(ctx, jackpotId, input.WalletID, 0)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrJackpotEmpty):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrSameWallet),
+			errors.Is(err, domain.ErrCurrencyMismatch):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrWalletClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "jackpot paid out", "payload": payout})
+}
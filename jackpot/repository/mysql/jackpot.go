@@ -0,0 +1,43 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"quik/domain"
+
+	"gorm.io/gorm"
+)
+
+type mysqlJackpotRepository struct {
+	db *gorm.DB
+}
+
+func NewMySqlJackpotRepository(db *gorm.DB) domain.JackpotRepository {
+	return &mysqlJackpotRepository{db: db}
+}
+
+func (j *mysqlJackpotRepository) Get(ctx context.Context, id string) (domain.JackpotPool, error) {
+	var pool domain.JackpotPool
+	err := j.db.WithContext(ctx).Where("id = ?", id).First(&pool).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return domain.JackpotPool{}, domain.ErrRecordNotFound
+		default:
+			return domain.JackpotPool{}, err
+		}
+	}
+	return pool, nil
+}
+
+func (j *mysqlJackpotRepository) List(ctx context.Context) ([]domain.JackpotPool, error) {
+	var pools []domain.JackpotPool
+	err := j.db.WithContext(ctx).Order("id").Find(&pools).Error
+	return pools, err
+}
+
+func (j *mysqlJackpotRepository) ListByCurrency(ctx context.Context, currency string) ([]domain.JackpotPool, error) {
+	var pools []domain.JackpotPool
+	err := j.db.WithContext(ctx).Where("currency = ?", currency).Order("id").Find(&pools).Error
+	return pools, err
+}
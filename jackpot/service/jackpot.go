@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+)
+
+type jackpotService struct {
+	jackpotRepository domain.JackpotRepository
+	walletService     domain.WalletService
+}
+
+func NewJackpotService(r domain.JackpotRepository, ws domain.WalletService) domain.JackpotService {
+	return &jackpotService{
+		jackpotRepository: r,
+		walletService:     ws,
+	}
+}
+
+func (s *jackpotService) Create(ctx context.Context, name, currencyCode, contributionRate, seedAmount string, actorID int) (domain.JackpotPool, error) {
+	if !domain.ValidJackpotName(name) {
+		return domain.JackpotPool{}, domain.ErrInvalidJackpotName
+	}
+	rate, err := decimal.NewFromString(contributionRate)
+	if err != nil || !rate.IsPositive() || !rate.LessThan(decimal.NewFromInt(1)) {
+		return domain.JackpotPool{}, domain.ErrInvalidContributionRate
+	}
+	currency, err := domain.LookupCurrency(currencyCode)
+	if err != nil {
+		return domain.JackpotPool{}, err
+	}
+	seed := decimal.Zero
+	if seedAmount != "" {
+		seed, err = decimal.NewFromString(seedAmount)
+		if err != nil || seed.IsNegative() {
+			return domain.JackpotPool{}, domain.ErrInvalidAmount
+		}
+		if err := currency.ValidateAmount(seed); err != nil {
+			return domain.JackpotPool{}, err
+		}
+	}
+	pool := domain.JackpotPool{
+		Name:             name,
+		Currency:         currency.Code,
+		ContributionRate: rate,
+		SeedAmount:       seed,
+		Balance:          decimal.Zero,
+	}
+	if err := s.walletService.OpenJackpot(ctx, &pool, actorID); err != nil {
+		return domain.JackpotPool{}, err
+	}
+	return pool, nil
+}
+
+func (s *jackpotService) Get(ctx context.Context, id string) (domain.JackpotPool, error) {
+	pool, err := s.jackpotRepository.Get(ctx, id)
+	if err != nil {
+		return domain.JackpotPool{}, err
+	}
+	err = s.withBalance(ctx, &pool)
+	return pool, err
+}
+
+func (s *jackpotService) List(ctx context.Context) ([]domain.JackpotPool, error) {
+	pools, err := s.jackpotRepository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pools {
+		if err := s.withBalance(ctx, &pools[i]); err != nil {
+			return nil, err
+		}
+	}
+	return pools, nil
+}
+
+func (s *jackpotService) Payout(ctx context.Context, id, walletID string, actorID int) (domain.JackpotPayout, error) {
+	pool, err := s.jackpotRepository.Get(ctx, id)
+	if err != nil {
+		return domain.JackpotPayout{}, err
+	}
+	return s.walletService.PayoutJackpot(ctx, pool, walletID, actorID)
+}
+
+// withBalance fills in the pool's balance from its wallet.
+func (s *jackpotService) withBalance(ctx context.Context, pool *domain.JackpotPool) error {
+	wallet, err := s.walletService.Get(ctx, strconv.Itoa(pool.WalletID))
+	if err != nil {
+		return err
+	}
+	pool.Balance = wallet.Balance
+	return nil
+}
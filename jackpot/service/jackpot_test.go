@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+	"quik/domain/mocks/inmemorydb"
+	"quik/domain/mocks/repository"
+	_walletService "quik/wallet/service"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateJackpot(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("happy path: a pool gets its own wallet and is seeded", func(t *testing.T) {
+		jackpotRepo := &repository.JackpotRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("Create", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w != nil && w.PlayerID == 0 && w.Pool && w.Currency == "EUR"
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Wallet).ID = 50
+		}).Return(nil).Once()
+		walletRepo.On("CreateJackpot", context.Background(), mock.MatchedBy(func(p *domain.JackpotPool) bool {
+			return p != nil && p.WalletID == 50 && p.ContributionRate.Equal(decimal.RequireFromString("0.01")) && p.SeedAmount.Equal(decimal.NewFromInt(500))
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.JackpotPool).ID = 3
+		}).Return(nil).Once()
+		walletRepo.On("Get", context.Background(), "50").Return(domain.Wallet{ID: 50, Balance: decimal.Zero, Currency: "EUR", Status: domain.WalletStatusActive, Pool: true}, nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.Amount.Equal(decimal.NewFromInt(500)) && t.Category == domain.CategoryJackpot && t.Reference == "jackpot:3"
+		})).Return(nil).Once()
+		service := NewJackpotService(jackpotRepo, _walletService.NewWalletService(walletRepo, walletInMemoryDB))
+		pool, err := service.Create(context.Background(), "mega", "eur", "0.01", "500", 0)
+		as.NoError(err)
+		as.Equal(3, pool.ID)
+		as.True(pool.Balance.Equal(decimal.NewFromInt(500)))
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: a taken name fails before the pool's wallet is seeded", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("Create", context.Background(), mock.Anything).Return(nil).Once()
+		walletRepo.On("CreateJackpot", context.Background(), mock.Anything).Return(domain.ErrJackpotExists).Once()
+		service := NewJackpotService(&repository.JackpotRepositoryMock{}, _walletService.NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{}))
+		_, err := service.Create(context.Background(), "mega", "EUR", "0.01", "500", 0)
+		as.ErrorIs(err, domain.ErrJackpotExists)
+		walletRepo.AssertNotCalled(t, "Credit", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: the contribution rate must be a fraction", func(t *testing.T) {
+		service := NewJackpotService(&repository.JackpotRepositoryMock{}, nil)
+		for _, rate := range []string{"0", "1", "-0.1", "abc"} {
+			_, err := service.Create(context.Background(), "mega", "EUR", rate, "", 0)
+			as.ErrorIs(err, domain.ErrInvalidContributionRate)
+		}
+		_, err := service.Create(context.Background(), "", "EUR", "0.01", "", 0)
+		as.ErrorIs(err, domain.ErrInvalidJackpotName)
+		_, err = service.Create(context.Background(), "mega", "EUR", "0.01", "10.001", 0)
+		as.ErrorIs(err, domain.ErrAmountPrecision)
+	})
+}
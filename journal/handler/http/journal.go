@@ -0,0 +1,33 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"quik/domain"
+	"quik/wallet/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JournalHandler struct {
+	JournalService domain.JournalService
+}
+
+func NewJournalHandler(router *gin.Engine, js domain.JournalService) {
+	handler := &JournalHandler{
+		JournalService: js,
+	}
+
+	api := router.Group("/api/v1")
+	api.GET("/journal/trial-balance", middleware.AuthAdmin(), handler.GetTrialBalance)
+}
+
+func (j *JournalHandler) GetTrialBalance(c *gin.Context) {
+	var ctx = context.TODO()
+	trialBalance, err := j.JournalService.TrialBalance(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payload": trialBalance})
+}
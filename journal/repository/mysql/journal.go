@@ -0,0 +1,35 @@
+package mysql
+
+import (
+	"context"
+	"quik/domain"
+
+	"gorm.io/gorm"
+)
+
+type mysqlJournalRepository struct {
+	db *gorm.DB
+}
+
+func NewMySqlJournalRepository(db *gorm.DB) domain.JournalRepository {
+	return &mysqlJournalRepository{db: db}
+}
+
+func (j *mysqlJournalRepository) AccountBalances(ctx context.Context) ([]domain.AccountBalance, error) {
+	var balances []domain.AccountBalance
+	err := j.db.WithContext(ctx).Model(&domain.JournalLine{}).
+		Select("account, currency, SUM(debit) AS debit, SUM(credit) AS credit").
+		Group("account, currency").
+		Order("currency, account").
+		Scan(&balances).Error
+	return balances, err
+}
+
+func (j *mysqlJournalRepository) StoredBalances(ctx context.Context) ([]domain.StoredBalance, error) {
+	var balances []domain.StoredBalance
+	err := j.db.WithContext(ctx).Model(&domain.Wallet{}).
+		Select("id AS wallet_id, currency, balance, bonus_balance").
+		Order("id").
+		Scan(&balances).Error
+	return balances, err
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+
+	"github.com/shopspring/decimal"
+)
+
+type journalService struct {
+	journalRepository domain.JournalRepository
+}
+
+func NewJournalService(r domain.JournalRepository) domain.JournalService {
+	return &journalService{journalRepository: r}
+}
+
+// TrialBalance totals every account, checks that debits equal credits in
+// each currency and compares the wallet accounts and the bonus liability with
+// the balances stored on the wallets.
+func (j *journalService) TrialBalance(ctx context.Context) (domain.TrialBalance, error) {
+	accounts, err := j.journalRepository.AccountBalances(ctx)
+	if err != nil {
+		return domain.TrialBalance{}, err
+	}
+	stored, err := j.journalRepository.StoredBalances(ctx)
+	if err != nil {
+		return domain.TrialBalance{}, err
+	}
+	trialBalance := domain.TrialBalance{
+		Accounts:     accounts,
+		TotalDebits:  map[string]decimal.Decimal{},
+		TotalCredits: map[string]decimal.Decimal{},
+		Mismatches:   []domain.AccountMismatch{},
+		Balanced:     true,
+	}
+	type key struct{ account, currency string }
+	// Wallets are liabilities, so their accounts carry credit balances
+	// while the bonus liability carries a debit one.
+	credits := map[key]decimal.Decimal{}
+	for _, account := range accounts {
+		trialBalance.TotalDebits[account.Currency] = trialBalance.TotalDebits[account.Currency].Add(account.Debit)
+		trialBalance.TotalCredits[account.Currency] = trialBalance.TotalCredits[account.Currency].Add(account.Credit)
+		k := key{account.Account, account.Currency}
+		credits[k] = credits[k].Add(account.Credit).Sub(account.Debit)
+	}
+	for currency, debits := range trialBalance.TotalDebits {
+		if !debits.Equal(trialBalance.TotalCredits[currency]) {
+			trialBalance.Balanced = false
+		}
+	}
+	mismatch := func(account, currency string, journal, stored decimal.Decimal) {
+		if journal.Equal(stored) {
+			return
+		}
+		trialBalance.Mismatches = append(trialBalance.Mismatches, domain.AccountMismatch{
+			Account: account, Currency: currency, Journal: journal, Stored: stored,
+		})
+		trialBalance.Balanced = false
+	}
+	bonusFunds := map[string]decimal.Decimal{}
+	var currencies []string
+	for _, wallet := range stored {
+		account := domain.WalletAccount(wallet.WalletID)
+		mismatch(account, wallet.Currency, credits[key{account, wallet.Currency}], wallet.Balance)
+		if _, ok := bonusFunds[wallet.Currency]; !ok {
+			currencies = append(currencies, wallet.Currency)
+		}
+		bonusFunds[wallet.Currency] = bonusFunds[wallet.Currency].Add(wallet.BonusBalance)
+	}
+	for _, currency := range currencies {
+		liability := credits[key{domain.AccountBonusLiability, currency}].Neg()
+		mismatch(domain.AccountBonusLiability, currency, liability, bonusFunds[currency])
+	}
+	return trialBalance, nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"quik/domain"
+	"quik/domain/mocks/repository"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrialBalance(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("happy path: journal lines of credits and debits balance", func(t *testing.T) {
+		journalRepo := &repository.JournalRepositoryMock{}
+		var accounts []domain.AccountBalance
+		for _, transaction := range []domain.Transaction{
+			{ID: 1, WalletID: 6, Type: domain.TransactionTypeCredit, Amount: decimal.NewFromInt(100)},
+			{ID: 2, WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(40)},
+		} {
+			for _, line := range domain.JournalLines(transaction, "EUR") {
+				accounts = append(accounts, domain.AccountBalance{Account: line.Account, Currency: line.Currency, Debit: line.Debit, Credit: line.Credit})
+			}
+		}
+		journalRepo.On("AccountBalances", context.Background()).Return(accounts, nil).Once()
+		journalRepo.On("StoredBalances", context.Background()).Return([]domain.StoredBalance{
+			{WalletID: 6, Currency: "EUR", Balance: decimal.NewFromInt(60), BonusBalance: decimal.Zero},
+		}, nil).Once()
+		service := NewJournalService(journalRepo)
+		trialBalance, err := service.TrialBalance(context.Background())
+		as.NoError(err)
+		as.True(trialBalance.Balanced)
+		as.Empty(trialBalance.Mismatches)
+		as.True(trialBalance.TotalDebits["EUR"].Equal(decimal.NewFromInt(140)))
+		as.True(trialBalance.TotalCredits["EUR"].Equal(decimal.NewFromInt(140)))
+		journalRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: deposits, bonus spend and conversion use their own accounts", func(t *testing.T) {
+		bonusID := 3
+		totals := map[string]decimal.Decimal{}
+		var accounts []domain.AccountBalance
+		for _, transaction := range []domain.Transaction{
+			{ID: 1, WalletID: 6, Type: domain.TransactionTypeCredit, Amount: decimal.NewFromInt(100), Deposit: true},
+			{ID: 2, WalletID: 6, Type: domain.TransactionTypeCredit, Amount: decimal.NewFromInt(50), BonusAmount: decimal.NewFromInt(50), BonusID: &bonusID},
+			{ID: 3, WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(120), BonusAmount: decimal.NewFromInt(20), BonusConverted: decimal.NewFromInt(30)},
+			{ID: 4, WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(10), Withdrawal: true},
+		} {
+			for _, line := range domain.JournalLines(transaction, "EUR") {
+				totals[line.Account] = totals[line.Account].Add(line.Debit).Sub(line.Credit)
+				accounts = append(accounts, domain.AccountBalance{Account: line.Account, Currency: line.Currency, Debit: line.Debit, Credit: line.Credit})
+			}
+		}
+		as.True(totals[domain.AccountPSPClearing].Equal(decimal.NewFromInt(90)))
+		as.True(totals[domain.AccountBonusLiability].IsZero())
+		as.True(totals[domain.AccountHouse].Equal(decimal.NewFromInt(-70)))
+		journalRepo := &repository.JournalRepositoryMock{}
+		journalRepo.On("AccountBalances", context.Background()).Return(accounts, nil).Once()
+		journalRepo.On("StoredBalances", context.Background()).Return([]domain.StoredBalance{
+			{WalletID: 6, Currency: "EUR", Balance: decimal.NewFromInt(20), BonusBalance: decimal.Zero},
+		}, nil).Once()
+		service := NewJournalService(journalRepo)
+		trialBalance, err := service.TrialBalance(context.Background())
+		as.NoError(err)
+		as.True(trialBalance.Balanced)
+		journalRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: reports wallets out of line with their journal", func(t *testing.T) {
+		var accounts []domain.AccountBalance
+		for _, line := range domain.JournalLines(domain.Transaction{ID: 1, WalletID: 6, Type: domain.TransactionTypeCredit, Amount: decimal.NewFromInt(100)}, "EUR") {
+			accounts = append(accounts, domain.AccountBalance{Account: line.Account, Currency: line.Currency, Debit: line.Debit, Credit: line.Credit})
+		}
+		journalRepo := &repository.JournalRepositoryMock{}
+		journalRepo.On("AccountBalances", context.Background()).Return(accounts, nil).Once()
+		journalRepo.On("StoredBalances", context.Background()).Return([]domain.StoredBalance{
+			{WalletID: 6, Currency: "EUR", Balance: decimal.NewFromInt(90), BonusBalance: decimal.NewFromInt(10)},
+		}, nil).Once()
+		service := NewJournalService(journalRepo)
+		trialBalance, err := service.TrialBalance(context.Background())
+		as.NoError(err)
+		as.False(trialBalance.Balanced)
+		if as.Len(trialBalance.Mismatches, 2) {
+			as.Equal(domain.WalletAccount(6), trialBalance.Mismatches[0].Account)
+			as.True(trialBalance.Mismatches[0].Journal.Equal(decimal.NewFromInt(100)))
+			as.Equal(domain.AccountBonusLiability, trialBalance.Mismatches[1].Account)
+		}
+		journalRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: reports unbalanced books per currency", func(t *testing.T) {
+		journalRepo := &repository.JournalRepositoryMock{}
+		journalRepo.On("AccountBalances", context.Background()).Return([]domain.AccountBalance{
+			{Account: domain.AccountHouse, Currency: "EUR", Debit: decimal.NewFromInt(10), Credit: decimal.Zero},
+			{Account: domain.WalletAccount(6), Currency: "EUR", Debit: decimal.Zero, Credit: decimal.NewFromInt(10)},
+			{Account: domain.AccountHouse, Currency: "GBP", Debit: decimal.NewFromInt(5), Credit: decimal.Zero},
+		}, nil).Once()
+		journalRepo.On("StoredBalances", context.Background()).Return([]domain.StoredBalance{
+			{WalletID: 6, Currency: "EUR", Balance: decimal.NewFromInt(10), BonusBalance: decimal.Zero},
+		}, nil).Once()
+		service := NewJournalService(journalRepo)
+		trialBalance, err := service.TrialBalance(context.Background())
+		as.NoError(err)
+		as.False(trialBalance.Balanced)
+		journalRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: repository failure is returned", func(t *testing.T) {
+		journalRepo := &repository.JournalRepositoryMock{}
+		journalRepo.On("AccountBalances", context.Background()).Return([]domain.AccountBalance(nil), errors.New("connection refused")).Once()
+		service := NewJournalService(journalRepo)
+		_, err := service.TrialBalance(context.Background())
+		as.Error(err)
+	})
+}
@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"quik/domain"
 	"quik/internal/encryption"
+	"quik/wallet/handler/middleware"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
@@ -29,6 +31,8 @@ func NewPlayerHandler(router *gin.Engine, p domain.PlayerService, w domain.Walle
 	api.PUT("/players/:id", handler.UpdatePlayerByID)
 	api.DELETE("/players/:id", handler.DeletePlayerByID)
 	api.POST("players/login", handler.Login)
+	api.POST("/players/me/self-exclusion", middleware.AuthPlayer(), handler.SelfExclude)
+	api.GET("/players/me/wallets", middleware.AuthPlayer(), handler.ListWallets)
 }
 
 var validate *validator.Validate
@@ -238,3 +242,54 @@ func (p *PlayerHandler) Login(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"payload": payload})
 }
+
+func (p *PlayerHandler) SelfExclude(c *gin.Context) {
+	// A missing from starts the exclusion immediately; exactly one of until
+	// and indefinite must be given.
+	var input struct {
+		From       *time.Time `json:"from"`
+		Until      *time.Time `json:"until"`
+		Indefinite bool       `json:"indefinite"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	exclusion := domain.Exclusion{Until: input.Until, Indefinite: input.Indefinite}
+	if input.From != nil {
+		exclusion.From = *input.From
+	}
+	var ctx = context.TODO()
+	player, err := p.PlayerService.SelfExclude(ctx, strconv.Itoa(playerId), exclusion)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidExclusion):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrExclusionShortened):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "player self-excluded", "payload": player})
+}
+
+func (p *PlayerHandler) ListWallets(c *gin.Context) {
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	wallets, err := p.WalletService.ListByPlayer(ctx, playerId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"payload": wallets})
+}
@@ -36,7 +36,16 @@ func (m *mysqlPlayerRepository) Get(ctx context.Context, id string) (domain.Play
 }
 
 func (m *mysqlPlayerRepository) Update(ctx context.Context, player *domain.Player, updatedPlayer domain.Player) error {
-	err := m.db.WithContext(ctx).Model(player).Updates(updatedPlayer).Error
+	err := m.db.WithContext(ctx).Model(player).
+		Omit("ExcludedFrom", "ExcludedUntil", "ExcludedIndefinitely").
+		Updates(updatedPlayer).Error
+	return err
+}
+
+func (m *mysqlPlayerRepository) UpdateExclusion(ctx context.Context, player *domain.Player) error {
+	err := m.db.WithContext(ctx).Model(player).
+		Select("ExcludedFrom", "ExcludedUntil", "ExcludedIndefinitely").
+		Updates(player).Error
 	return err
 }
 
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"quik/domain"
 	"strings"
+	"time"
 )
 
 type playerService struct {
@@ -46,7 +47,10 @@ func (p *playerService) Get(ctx context.Context, id string) (domain.Player, erro
 }
 
 func (p *playerService) Update(ctx context.Context, id string, player *domain.Player, updatedPlayer domain.Player) error {
-
+	// Exclusions only ever change through SelfExclude.
+	updatedPlayer.ExcludedFrom = nil
+	updatedPlayer.ExcludedUntil = nil
+	updatedPlayer.ExcludedIndefinitely = false
 	err := p.playerRepository.Update(ctx, player, updatedPlayer)
 	return err
 }
@@ -55,3 +59,41 @@ func (p *playerService) Delete(ctx context.Context, id string, player *domain.Pl
 	err := p.playerRepository.Delete(ctx, id, player)
 	return err
 }
+
+func (p *playerService) SelfExclude(ctx context.Context, id string, exclusion domain.Exclusion) (domain.Player, error) {
+	now := time.Now()
+	if exclusion.From.Before(now) {
+		exclusion.From = now
+	}
+	if exclusion.Indefinite == (exclusion.Until != nil) {
+		return domain.Player{}, domain.ErrInvalidExclusion
+	}
+	if exclusion.Until != nil && !exclusion.Until.After(exclusion.From) {
+		return domain.Player{}, domain.ErrInvalidExclusion
+	}
+	player, err := p.playerRepository.Get(ctx, id)
+	if err != nil {
+		return domain.Player{}, err
+	}
+	if player.HasExclusion(now) {
+		switch {
+		case player.ExcludedIndefinitely:
+			// Only another indefinite exclusion is not shorter.
+			if !exclusion.Indefinite {
+				return domain.Player{}, domain.ErrExclusionShortened
+			}
+		case exclusion.Until != nil && player.ExcludedUntil != nil:
+			if exclusion.Until.Before(*player.ExcludedUntil) {
+				return domain.Player{}, domain.ErrExclusionShortened
+			}
+		}
+		if player.ExcludedFrom.Before(exclusion.From) {
+			exclusion.From = *player.ExcludedFrom
+		}
+	}
+	player.ExcludedFrom = &exclusion.From
+	player.ExcludedUntil = exclusion.Until
+	player.ExcludedIndefinitely = exclusion.Indefinite
+	err = p.playerRepository.UpdateExclusion(ctx, &player)
+	return player, err
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+	"quik/domain/mocks/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSelfExclude(t *testing.T) {
+	as := assert.New(t)
+	inAWeek := time.Now().Add(7 * 24 * time.Hour)
+	inADay := time.Now().Add(24 * time.Hour)
+
+	t.Run("happy path: a time-out starts immediately", func(t *testing.T) {
+		playerRepo := &repository.PlayerRepositoryMock{}
+		playerRepo.On("Get", context.Background(), "1").Return(domain.Player{ID: 1}, nil).Once()
+		playerRepo.On("UpdateExclusion", context.Background(), mock.MatchedBy(func(p *domain.Player) bool {
+			return p != nil && p.ExcludedFrom != nil && p.ExcludedUntil != nil && p.ExcludedUntil.Equal(inADay) && !p.ExcludedIndefinitely
+		})).Return(nil).Once()
+		service := NewPlayerService(playerRepo)
+		player, err := service.SelfExclude(context.Background(), "1", domain.Exclusion{Until: &inADay})
+		as.NoError(err)
+		as.True(player.ExcludedAt(time.Now().Add(time.Second)))
+		as.False(player.ExcludedAt(inADay))
+		playerRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: an active exclusion can be made indefinite", func(t *testing.T) {
+		playerRepo := &repository.PlayerRepositoryMock{}
+		from := time.Now().Add(-time.Hour)
+		playerRepo.On("Get", context.Background(), "1").Return(domain.Player{ID: 1, ExcludedFrom: &from, ExcludedUntil: &inADay}, nil).Once()
+		playerRepo.On("UpdateExclusion", context.Background(), mock.MatchedBy(func(p *domain.Player) bool {
+			return p != nil && p.ExcludedFrom.Equal(from) && p.ExcludedUntil == nil && p.ExcludedIndefinitely
+		})).Return(nil).Once()
+		service := NewPlayerService(playerRepo)
+		_, err := service.SelfExclude(context.Background(), "1", domain.Exclusion{Indefinite: true})
+		as.NoError(err)
+		playerRepo.AssertExpectations(t)
+	})
+
+	t.Run("rule violation: an active exclusion cannot be shortened", func(t *testing.T) {
+		playerRepo := &repository.PlayerRepositoryMock{}
+		from := time.Now().Add(-time.Hour)
+		playerRepo.On("Get", context.Background(), "1").Return(domain.Player{ID: 1, ExcludedFrom: &from, ExcludedUntil: &inAWeek}, nil).Once()
+		service := NewPlayerService(playerRepo)
+		_, err := service.SelfExclude(context.Background(), "1", domain.Exclusion{Until: &inADay})
+		as.ErrorIs(err, domain.ErrExclusionShortened)
+		playerRepo.AssertNotCalled(t, "UpdateExclusion", mock.Anything, mock.Anything)
+	})
+
+	t.Run("rule violation: an indefinite exclusion cannot be given an end", func(t *testing.T) {
+		playerRepo := &repository.PlayerRepositoryMock{}
+		from := time.Now().Add(-time.Hour)
+		playerRepo.On("Get", context.Background(), "1").Return(domain.Player{ID: 1, ExcludedFrom: &from, ExcludedIndefinitely: true}, nil).Once()
+		service := NewPlayerService(playerRepo)
+		_, err := service.SelfExclude(context.Background(), "1", domain.Exclusion{Until: &inAWeek})
+		as.ErrorIs(err, domain.ErrExclusionShortened)
+		playerRepo.AssertNotCalled(t, "UpdateExclusion", mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: an exclusion needs exactly one of an end date and the indefinite flag", func(t *testing.T) {
+		service := NewPlayerService(&repository.PlayerRepositoryMock{})
+		_, err := service.SelfExclude(context.Background(), "1", domain.Exclusion{})
+		as.ErrorIs(err, domain.ErrInvalidExclusion)
+		_, err = service.SelfExclude(context.Background(), "1", domain.Exclusion{Until: &inADay, Indefinite: true})
+		as.ErrorIs(err, domain.ErrInvalidExclusion)
+		past := time.Now().Add(-time.Hour)
+		_, err = service.SelfExclude(context.Background(), "1", domain.Exclusion{Until: &past})
+		as.ErrorIs(err, domain.ErrInvalidExclusion)
+	})
+
+	t.Run("happy path: updating a player never touches the exclusion", func(t *testing.T) {
+		playerRepo := &repository.PlayerRepositoryMock{}
+		player := domain.Player{ID: 1}
+		playerRepo.On("Update", context.Background(), &player, mock.MatchedBy(func(p domain.Player) bool {
+			return p.Name == "Ada" && p.ExcludedFrom == nil && p.ExcludedUntil == nil && !p.ExcludedIndefinitely
+		})).Return(nil).Once()
+		service := NewPlayerService(playerRepo)
+		err := service.Update(context.Background(), "1", &player, domain.Player{Name: "Ada", ExcludedIndefinitely: true, ExcludedUntil: &inADay})
+		as.NoError(err)
+		playerRepo.AssertExpectations(t)
+	})
+}
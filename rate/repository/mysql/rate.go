@@ -0,0 +1,60 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"quik/domain"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// inverseRatePrecision is the number of decimal places kept when a rate is
+// derived from its inverse pair.
+const inverseRatePrecision = 10
+
+type mysqlRateProvider struct {
+	db *gorm.DB
+}
+
+func NewMySqlRateProvider(db *gorm.DB) domain.RateProvider {
+	return &mysqlRateProvider{db: db}
+}
+
+func (m *mysqlRateProvider) Rate(ctx context.Context, base, quote string) (domain.ExchangeRate, error) {
+	base, quote = strings.ToUpper(base), strings.ToUpper(quote)
+	if base == quote {
+		return domain.ExchangeRate{Base: base, Quote: quote, Rate: decimal.NewFromInt(1)}, nil
+	}
+	var rate domain.ExchangeRate
+	err := m.db.WithContext(ctx).Where("base = ? AND quote = ?", base, quote).First(&rate).Error
+	if err == nil {
+		return rate, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.ExchangeRate{}, err
+	}
+	err = m.db.WithContext(ctx).Where("base = ? AND quote = ?", quote, base).First(&rate).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return domain.ExchangeRate{}, domain.ErrRateNotFound
+		default:
+			return domain.ExchangeRate{}, err
+		}
+	}
+	rate.Base, rate.Quote = base, quote
+	rate.Rate = decimal.NewFromInt(1).DivRound(rate.Rate, inverseRatePrecision)
+	return rate, nil
+}
+
+// SetRate inserts the pair or replaces its current rate.
+func (m *mysqlRateProvider) SetRate(ctx context.Context, rate *domain.ExchangeRate) error {
+	rate.Base, rate.Quote = strings.ToUpper(rate.Base), strings.ToUpper(rate.Quote)
+	err := m.db.WithContext(ctx).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(rate).Error
+	return err
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"quik/domain"
+	"quik/wallet/handler/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoundHandler struct {
+	RoundService domain.RoundService
+}
+
+func NewRoundHandler(router *gin.Engine, rs domain.RoundService) {
+	handler := &RoundHandler{
+		RoundService: rs,
+	}
+
+	api := router.Group("/api/v1")
+	api.POST("/rounds/bet", middleware.AuthProvider(), handler.Bet)
+	api.POST("/rounds/win", middleware.AuthProvider(), handler.Win)
+	api.POST("/rounds/rollback", middleware.AuthProvider(), handler.Rollback)
+	api.POST("/rounds/close", middleware.AuthProvider(), handler.Close)
+}
+
+// respondError maps round and wallet errors to their status codes.
+func respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrRoundActionConflict),
+		errors.Is(err, domain.ErrRoundActionPending),
+		errors.Is(err, domain.ErrBetRolledBack),
+		errors.Is(err, domain.ErrRoundClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidRoundRequest),
+		errors.Is(err, domain.ErrRollbackNotBet),
+		errors.Is(err, domain.ErrRoundWalletMismatch),
+		errors.Is(err, domain.ErrLossLimitExceeded),
+		errors.Is(err, domain.ErrWagerLimitExceeded):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrInsufficientFunds),
+		errors.Is(err, domain.ErrInvalidAmount),
+		errors.Is(err, domain.ErrAmountPrecision):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrWalletFrozen),
+		errors.Is(err, domain.ErrWalletClosed),
+		errors.Is(err, domain.ErrPlayerExcluded):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// refuseProvider responds and returns true when the request names another
+// provider than the one whose key authenticated it.
+func refuseProvider(c *gin.Context, provider string) bool {
+	if c.GetString("provider") != provider {
+		c.JSON(http.StatusForbidden, gin.H{"error": domain.ErrProviderMismatch.Error()})
+		return true
+	}
+	return false
+}
+
+func (r *RoundHandler) Bet(c *gin.Context) {
+	var input domain.RoundRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if refuseProvider(c, input.Provider) {
+		return
+	}
+	var ctx = context.TODO()
+	result, err := r.RoundService.Bet(ctx, input, 0)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "bet placed", "payload": result})
+}
+
+func (r *RoundHandler) Win(c *gin.Context) {
+	var input domain.RoundRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if refuseProvider(c, input.Provider) {
+		return
+	}
+	var ctx = context.TODO()
+	result, err := r.RoundService.Win(ctx, input, 0)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "win settled", "payload": result})
+}
+
+func (r *RoundHandler) Rollback(c *gin.Context) {
+	var input domain.RoundRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if refuseProvider(c, input.Provider) {
+		return
+	}
+	var ctx = context.TODO()
+	result, err := r.RoundService.Rollback(ctx, input, 0)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "bet rolled back", "payload": result})
+}
+
+func (r *RoundHandler) Close(c *gin.Context) {
+	var input struct {
+		Provider string `json:"provider"`
+		RoundID  string `json:"round_id"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Provider == "" || input.RoundID == "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": domain.ErrInvalidRoundRequest.Error()})
+		return
+	}
+	if refuseProvider(c, input.Provider) {
+		return
+	}
+	var ctx = context.TODO()
+	round, err := r.RoundService.Close(ctx, input.Provider, input.RoundID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "round closed", "payload": round})
+}
@@ -0,0 +1,137 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"quik/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type mysqlRoundRepository struct {
+	db *gorm.DB
+}
+
+func NewMySqlRoundRepository(db *gorm.DB) domain.RoundRepository {
+	return &mysqlRoundRepository{db: db}
+}
+
+func (r *mysqlRoundRepository) GetRound(ctx context.Context, provider, roundID string) (domain.Round, error) {
+	var round domain.Round
+	err := r.db.WithContext(ctx).Where("provider = ? AND provider_round_id = ?", provider, roundID).First(&round).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return domain.Round{}, domain.ErrRecordNotFound
+		default:
+			return domain.Round{}, err
+		}
+	}
+	return round, nil
+}
+
+// FindOrCreateRound relies on the unique provider round ID, so concurrent
+// first bets of a round end up on the same row.
+func (r *mysqlRoundRepository) FindOrCreateRound(ctx context.Context, round *domain.Round) error {
+	if err := r.db.WithContext(ctx).Clauses(clause.Insert{Modifier: "IGNORE"}).Create(round).Error; err != nil {
+		return err
+	}
+	found, err := r.GetRound(ctx, round.Provider, round.ProviderRoundID)
+	if err != nil {
+		return err
+	}
+	*round = found
+	return nil
+}
+
+func (r *mysqlRoundRepository) CloseRound(ctx context.Context, round *domain.Round) error {
+	now := time.Now()
+	err := r.db.WithContext(ctx).Model(&domain.Round{}).
+		Where("id = ? AND status = ?", round.ID, domain.RoundStatusOpen).
+		Updates(map[string]interface{}{
+			"status":     domain.RoundStatusClosed,
+			"closed_at":  now,
+			"updated_at": now,
+		}).Error
+	if err != nil {
+		return err
+	}
+	round.Status = domain.RoundStatusClosed
+	round.ClosedAt = &now
+	round.UpdatedAt = now
+	return nil
+}
+
+func (r *mysqlRoundRepository) GetAction(ctx context.Context, provider, transactionID string) (domain.RoundAction, error) {
+	var action domain.RoundAction
+	err := r.db.WithContext(ctx).Where("provider = ? AND provider_transaction_id = ?", provider, transactionID).First(&action).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return domain.RoundAction{}, domain.ErrRecordNotFound
+		default:
+			return domain.RoundAction{}, err
+		}
+	}
+	return action, nil
+}
+
+func (r *mysqlRoundRepository) StartAction(ctx context.Context, action *domain.RoundAction) error {
+	result := r.db.WithContext(ctx).Clauses(clause.Insert{Modifier: "IGNORE"}).Create(action)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrActionExists
+	}
+	return nil
+}
+
+// CompleteAction only completes actions still pending, so an action
+// recovered by another request is not counted twice in the round's totals.
+func (r *mysqlRoundRepository) CompleteAction(ctx context.Context, round *domain.Round, action *domain.RoundAction) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.RoundAction{}).
+			Where("id = ? AND status = ?", action.ID, domain.RoundActionPending).
+			Updates(map[string]interface{}{
+				"status":         domain.RoundActionCompleted,
+				"transaction_id": action.TransactionID,
+				"updated_at":     time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return domain.ErrRoundActionPending
+		}
+		var column string
+		amount := action.Amount
+		switch action.Type {
+		case domain.RoundActionBet:
+			column = "total_bet"
+		case domain.RoundActionWin:
+			column = "total_win"
+		case domain.RoundActionRollback:
+			column, amount = "total_bet", amount.Neg()
+		}
+		err := tx.Model(&domain.Round{}).Where("id = ?", round.ID).
+			Update(column, gorm.Expr(column+" + ?", amount)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("id = ?", round.ID).First(round).Error
+	})
+	if err != nil {
+		return err
+	}
+	action.Status = domain.RoundActionCompleted
+	return nil
+}
+
+func (r *mysqlRoundRepository) DeleteAction(ctx context.Context, action *domain.RoundAction) error {
+	return r.db.WithContext(ctx).
+		Where("id = ? AND status = ?", action.ID, domain.RoundActionPending).
+		Delete(&domain.RoundAction{}).Error
+}
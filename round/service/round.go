@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"quik/domain"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// moveTimeout bounds the wallet movement of an action, so that a request
+// still running it cannot book it after the action is taken for abandoned.
+const moveTimeout = 30 * time.Second
+
+// pendingActionTimeout is how long an action may stay pending before it is
+// taken for abandoned, its request having died between claiming the provider
+// transaction ID and completing it. It is well beyond moveTimeout, so the
+// movement of an older action has either been booked or never will be.
+const pendingActionTimeout = 5 * time.Minute
+
+type roundService struct {
+	roundRepository domain.RoundRepository
+	walletService   domain.WalletService
+	playerService   domain.PlayerService
+}
+
+func NewRoundService(r domain.RoundRepository, ws domain.WalletService, ps domain.PlayerService) domain.RoundService {
+	return &roundService{
+		roundRepository: r,
+		walletService:   ws,
+		playerService:   ps,
+	}
+}
+
+func (s *roundService) Bet(ctx context.Context, req domain.RoundRequest, actorID int) (domain.RoundResult, error) {
+	if !req.Valid() {
+		return domain.RoundResult{}, domain.ErrInvalidRoundRequest
+	}
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		return domain.RoundResult{}, domain.ErrInvalidAmount
+	}
+	matches := func(a domain.RoundAction) bool {
+		return a.Type == domain.RoundActionBet && a.Amount.Equal(amount)
+	}
+	// A rollback that overtook the bet has claimed its provider
+	// transaction ID, so replay refuses it.
+	if result, found, err := s.replay(ctx, req, matches); found {
+		return result, err
+	}
+	round, err := s.openRound(ctx, req)
+	if err != nil {
+		return domain.RoundResult{}, err
+	}
+	if err := s.checkExcluded(ctx, round.PlayerID); err != nil {
+		return domain.RoundResult{}, err
+	}
+	action := domain.RoundAction{
+		RoundID:               round.ID,
+		Provider:              req.Provider,
+		ProviderTransactionID: req.TransactionID,
+		Type:                  domain.RoundActionBet,
+		Amount:                amount,
+		Status:                domain.RoundActionPending,
+	}
+	return s.execute(ctx, req, &round, &action, matches, func(ctx context.Context) (domain.Transaction, error) {
+		return s.walletService.Debit(ctx, strconv.Itoa(round.WalletID), req.Amount, actorID, s.details(req, domain.CategoryBet))
+	})
+}
+
+func (s *roundService) Win(ctx context.Context, req domain.RoundRequest, actorID int) (domain.RoundResult, error) {
+	if !req.Valid() {
+		return domain.RoundResult{}, domain.ErrInvalidRoundRequest
+	}
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil {
+		return domain.RoundResult{}, domain.ErrInvalidAmount
+	}
+	matches := func(a domain.RoundAction) bool {
+		return a.Type == domain.RoundActionWin && a.Amount.Equal(amount)
+	}
+	if result, found, err := s.replay(ctx, req, matches); found {
+		return result, err
+	}
+	round, err := s.roundRepository.GetRound(ctx, req.Provider, req.RoundID)
+	if err != nil {
+		return domain.RoundResult{}, err
+	}
+	if round.Status == domain.RoundStatusClosed {
+		return domain.RoundResult{}, domain.ErrRoundClosed
+	}
+	action := domain.RoundAction{
+		RoundID:               round.ID,
+		Provider:              req.Provider,
+		ProviderTransactionID: req.TransactionID,
+		Type:                  domain.RoundActionWin,
+		Amount:                amount,
+		Status:                domain.RoundActionPending,
+	}
+	return s.execute(ctx, req, &round, &action, matches, func(ctx context.Context) (domain.Transaction, error) {
+		return s.walletService.Credit(ctx, strconv.Itoa(round.WalletID), req.Amount, actorID, s.details(req, domain.CategoryWin))
+	})
+}
+
+func (s *roundService) Rollback(ctx context.Context, req domain.RoundRequest, actorID int) (domain.RoundResult, error) {
+	if !req.Valid() || req.RollbackTransactionID == "" {
+		return domain.RoundResult{}, domain.ErrInvalidRoundRequest
+	}
+	matches := func(a domain.RoundAction) bool {
+		return a.Type == domain.RoundActionRollback && a.RollbackOf != nil && *a.RollbackOf == req.RollbackTransactionID
+	}
+	if result, found, err := s.replay(ctx, req, matches); found {
+		return result, err
+	}
+	action := domain.RoundAction{
+		Provider:              req.Provider,
+		ProviderTransactionID: req.TransactionID,
+		Type:                  domain.RoundActionRollback,
+		Amount:                decimal.Zero,
+		RollbackOf:            &req.RollbackTransactionID,
+		Status:                domain.RoundActionPending,
+	}
+	bet, err := s.roundRepository.GetAction(ctx, req.Provider, req.RollbackTransactionID)
+	claimed := err == nil && bet.Type == domain.RoundActionTombstone
+	if err == nil && !claimed && bet.Status != domain.RoundActionCompleted {
+		err = s.recoverBet(ctx, req, &bet)
+	}
+	switch {
+	case errors.Is(err, domain.ErrRecordNotFound), claimed:
+		// The bet never reached us, or has not yet: record the rollback
+		// without moving funds, leaving a tombstone on the bet's provider
+		// transaction ID so that the bet is refused if it turns up.
+		round, err := s.openRound(ctx, req)
+		if err != nil {
+			return domain.RoundResult{}, err
+		}
+		if !claimed {
+			tombstone := domain.RoundAction{
+				RoundID:               round.ID,
+				Provider:              req.Provider,
+				ProviderTransactionID: req.RollbackTransactionID,
+				Type:                  domain.RoundActionTombstone,
+				Amount:                decimal.Zero,
+				Status:                domain.RoundActionCompleted,
+			}
+			err := s.roundRepository.StartAction(ctx, &tombstone)
+			if errors.Is(err, domain.ErrActionExists) {
+				// The bet arrived in the meantime; the rollback can
+				// be retried once it is through.
+				return domain.RoundResult{}, domain.ErrRoundActionPending
+			}
+			if err != nil {
+				return domain.RoundResult{}, err
+			}
+		}
+		action.RoundID = round.ID
+		return s.execute(ctx, req, &round, &action, matches, nil)
+	case err != nil:
+		return domain.RoundResult{}, err
+	}
+	if bet.Type != domain.RoundActionBet {
+		return domain.RoundResult{}, domain.ErrRollbackNotBet
+	}
+	round, err := s.roundRepository.GetRound(ctx, req.Provider, req.RoundID)
+	if err != nil {
+		return domain.RoundResult{}, err
+	}
+	if round.ID != bet.RoundID {
+		return domain.RoundResult{}, domain.ErrRoundActionConflict
+	}
+	if round.Status == domain.RoundStatusClosed {
+		return domain.RoundResult{}, domain.ErrRoundClosed
+	}
+	action.RoundID = round.ID
+	action.Amount = bet.Amount
+	return s.execute(ctx, req, &round, &action, matches, func(ctx context.Context) (domain.Transaction, error) {
+		return s.walletService.Refund(ctx, strconv.Itoa(*bet.TransactionID), actorID, s.details(req, ""))
+	})
+}
+
+func (s *roundService) Close(ctx context.Context, provider, roundID string) (domain.Round, error) {
+	round, err := s.roundRepository.GetRound(ctx, provider, roundID)
+	if err != nil {
+		return domain.Round{}, err
+	}
+	if round.Status == domain.RoundStatusClosed {
+		return round, nil
+	}
+	err = s.roundRepository.CloseRound(ctx, &round)
+	return round, err
+}
+
+// openRound loads the request's round, opening it on the request's wallet if
+// it is new.
+func (s *roundService) openRound(ctx context.Context, req domain.RoundRequest) (domain.Round, error) {
+	round, err := s.roundRepository.GetRound(ctx, req.Provider, req.RoundID)
+	if errors.Is(err, domain.ErrRecordNotFound) {
+		var wallet domain.Wallet
+		wallet, err = s.walletService.Get(ctx, req.WalletID)
+		if err != nil {
+			return domain.Round{}, err
+		}
+		round = domain.Round{
+			Provider:        req.Provider,
+			ProviderRoundID: req.RoundID,
+			WalletID:        wallet.ID,
+			PlayerID:        wallet.PlayerID,
+			Status:          domain.RoundStatusOpen,
+			TotalBet:        decimal.Zero,
+			TotalWin:        decimal.Zero,
+		}
+		err = s.roundRepository.FindOrCreateRound(ctx, &round)
+	}
+	if err != nil {
+		return domain.Round{}, err
+	}
+	if req.WalletID != "" && strconv.Itoa(round.WalletID) != req.WalletID {
+		return domain.Round{}, domain.ErrRoundWalletMismatch
+	}
+	if round.Status == domain.RoundStatusClosed {
+		return domain.Round{}, domain.ErrRoundClosed
+	}
+	return round, nil
+}
+
+// checkExcluded refuses bets from self-excluded players. Wins and rollbacks
+// are still settled for them.
+func (s *roundService) checkExcluded(ctx context.Context, playerID int) error {
+	if playerID == 0 {
+		return nil
+	}
+	player, err := s.playerService.Get(ctx, strconv.Itoa(playerID))
+	if err != nil {
+		return err
+	}
+	if player.ExcludedAt(time.Now()) {
+		return domain.ErrPlayerExcluded
+	}
+	return nil
+}
+
+// replay looks the request's provider transaction up. When it was seen
+// before it reports found, along with the original result if the request
+// matches it, so retried requests are answered without moving funds again.
+func (s *roundService) replay(ctx context.Context, req domain.RoundRequest, matches func(domain.RoundAction) bool) (domain.RoundResult, bool, error) {
+	action, err := s.roundRepository.GetAction(ctx, req.Provider, req.TransactionID)
+	switch {
+	case errors.Is(err, domain.ErrRecordNotFound):
+		return domain.RoundResult{}, false, nil
+	case err != nil:
+		return domain.RoundResult{}, true, err
+	}
+	if action.Type == domain.RoundActionTombstone {
+		return domain.RoundResult{}, true, domain.ErrBetRolledBack
+	}
+	round, err := s.roundRepository.GetRound(ctx, req.Provider, req.RoundID)
+	if err != nil && !errors.Is(err, domain.ErrRecordNotFound) {
+		return domain.RoundResult{}, true, err
+	}
+	if round.ID != action.RoundID || !matches(action) {
+		return domain.RoundResult{}, true, domain.ErrRoundActionConflict
+	}
+	if action.Status != domain.RoundActionCompleted {
+		recovered, err := s.recoverAction(ctx, &round, &action)
+		if err != nil {
+			return domain.RoundResult{}, true, err
+		}
+		if !recovered {
+			return domain.RoundResult{}, false, nil
+		}
+	}
+	result, err := s.result(ctx, round, action)
+	return result, true, err
+}
+
+// recoverBet recovers the pending bet a rollback names, failing with
+// ErrRecordNotFound if the bet turns out never to have moved funds.
+func (s *roundService) recoverBet(ctx context.Context, req domain.RoundRequest, bet *domain.RoundAction) error {
+	round, err := s.roundRepository.GetRound(ctx, req.Provider, req.RoundID)
+	if err != nil {
+		return err
+	}
+	if round.ID != bet.RoundID {
+		return domain.ErrRoundActionConflict
+	}
+	recovered, err := s.recoverAction(ctx, &round, bet)
+	if err != nil {
+		return err
+	}
+	if !recovered {
+		return domain.ErrRecordNotFound
+	}
+	return nil
+}
+
+// recoverAction settles an action left pending for longer than
+// pendingActionTimeout. If its wallet transaction was booked, found by the
+// provider transaction ID it carries as reference, the action is completed
+// with it and recovered is true; otherwise the action is dropped so that the
+// request can be run again.
+func (s *roundService) recoverAction(ctx context.Context, round *domain.Round, action *domain.RoundAction) (bool, error) {
+	if time.Since(action.UpdatedAt) < pendingActionTimeout {
+		return false, domain.ErrRoundActionPending
+	}
+	transaction, err := s.booked(ctx, round, action)
+	if err != nil {
+		return false, err
+	}
+	if transaction == nil {
+		return false, s.roundRepository.DeleteAction(ctx, action)
+	}
+	action.TransactionID = &transaction.ID
+	if err := s.roundRepository.CompleteAction(ctx, round, action); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// booked looks up the wallet transaction of the action by the provider
+// transaction ID it carries as reference, returning nil if there is none.
+func (s *roundService) booked(ctx context.Context, round *domain.Round, action *domain.RoundAction) (*domain.Transaction, error) {
+	transactions, _, err := s.walletService.ListTransactions(ctx, strconv.Itoa(round.WalletID), domain.TransactionFilter{
+		Reference: action.ProviderTransactionID,
+		Metadata:  domain.Metadata{"provider": action.Provider},
+		Limit:     1,
+	})
+	if err != nil || len(transactions) == 0 {
+		return nil, err
+	}
+	return &transactions[0], nil
+}
+
+// execute claims the action's provider transaction ID, runs move, if any,
+// within moveTimeout and completes the action with the wallet transaction it
+// booked. A failed move releases the ID again so the provider can retry, but
+// only once no transaction with the action's reference turns up; an action
+// abandoned halfway is left to recoverAction.
+func (s *roundService) execute(ctx context.Context, req domain.RoundRequest, round *domain.Round, action *domain.RoundAction, matches func(domain.RoundAction) bool, move func(ctx context.Context) (domain.Transaction, error)) (domain.RoundResult, error) {
+	err := s.roundRepository.StartAction(ctx, action)
+	if errors.Is(err, domain.ErrActionExists) {
+		// Lost a race with a retry of the same request, or with another
+		// rollback of the same bet.
+		if result, found, err := s.replay(ctx, req, matches); found {
+			return result, err
+		}
+		return domain.RoundResult{}, domain.ErrBetRolledBack
+	}
+	if err != nil {
+		return domain.RoundResult{}, err
+	}
+	if move != nil {
+		moveCtx, cancel := context.WithTimeout(ctx, moveTimeout)
+		transaction, err := move(moveCtx)
+		cancel()
+		if err != nil {
+			// A movement that failed while committing, for example on a
+			// timeout, may have been booked all the same.
+			booked, lookupErr := s.booked(ctx, round, action)
+			switch {
+			case lookupErr != nil:
+				return domain.RoundResult{}, err
+			case booked == nil:
+				if err := s.roundRepository.DeleteAction(ctx, action); err != nil {
+					return domain.RoundResult{}, err
+				}
+				return domain.RoundResult{}, err
+			}
+			transaction = *booked
+		}
+		action.TransactionID = &transaction.ID
+	}
+	if err := s.roundRepository.CompleteAction(ctx, round, action); err != nil {
+		return domain.RoundResult{}, err
+	}
+	return s.result(ctx, *round, *action)
+}
+
+func (s *roundService) result(ctx context.Context, round domain.Round, action domain.RoundAction) (domain.RoundResult, error) {
+	wallet, err := s.walletService.Get(ctx, strconv.Itoa(round.WalletID))
+	if err != nil {
+		return domain.RoundResult{}, err
+	}
+	return domain.RoundResult{Round: round, Action: action, Balance: wallet.Balance}, nil
+}
+
+// details tags the wallet transaction of a provider operation with its
+// provider transaction and round IDs.
+func (s *roundService) details(req domain.RoundRequest, category domain.TransactionCategory) domain.TransactionDetails {
+	return domain.TransactionDetails{
+		Reference: req.TransactionID,
+		Category:  category,
+		Metadata: domain.Metadata{
+			"provider": req.Provider,
+			"round_id": req.RoundID,
+		},
+	}
+}
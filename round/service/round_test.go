@@ -0,0 +1,328 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"quik/domain"
+	"quik/domain/mocks/inmemorydb"
+	"quik/domain/mocks/repository"
+	_playerService "quik/player/service"
+	_walletService "quik/wallet/service"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBet(t *testing.T) {
+	as := assert.New(t)
+	wallet := domain.Wallet{ID: 5, PlayerID: 2, Balance: decimal.NewFromInt(100), Currency: "EUR", Status: domain.WalletStatusActive}
+	round := domain.Round{ID: 9, Provider: "acme", ProviderRoundID: "r-1", WalletID: 5, PlayerID: 2, Status: domain.RoundStatusOpen}
+	req := domain.RoundRequest{Provider: "acme", RoundID: "r-1", TransactionID: "tx-1", WalletID: "5", Amount: "10"}
+
+	t.Run("happy path: the first bet opens the round and debits the stake", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(domain.Round{}, domain.ErrRecordNotFound).Once()
+		walletInMemoryDB.On("Get", context.Background(), "5").Return(wallet, nil)
+		roundRepo.On("FindOrCreateRound", context.Background(), mock.MatchedBy(func(r *domain.Round) bool {
+			return r != nil && r.WalletID == 5 && r.PlayerID == 2 && r.Status == domain.RoundStatusOpen
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Round).ID = 9
+		}).Return(nil).Once()
+		roundRepo.On("StartAction", context.Background(), mock.MatchedBy(func(a *domain.RoundAction) bool {
+			return a != nil && a.RoundID == 9 && a.Type == domain.RoundActionBet && a.Status == domain.RoundActionPending && a.Amount.Equal(decimal.NewFromInt(10))
+		})).Return(nil).Once()
+		walletRepo.On("Get", mock.Anything, "5").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", mock.Anything, mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil)
+		walletRepo.On("Debit", mock.Anything, mock.Anything, mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.Category == domain.CategoryBet && t.Reference == "tx-1" && t.Metadata["round_id"] == "r-1"
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", mock.Anything, "5").Return(nil).Once()
+		roundRepo.On("CompleteAction", context.Background(), mock.Anything, mock.MatchedBy(func(a *domain.RoundAction) bool {
+			return a != nil && a.TransactionID != nil
+		})).Return(nil).Once()
+		playerRepo := &repository.PlayerRepositoryMock{}
+		playerRepo.On("Get", context.Background(), "2").Return(domain.Player{ID: 2}, nil).Once()
+		service := NewRoundService(roundRepo, _walletService.NewWalletService(walletRepo, walletInMemoryDB), _playerService.NewPlayerService(playerRepo))
+		result, err := service.Bet(context.Background(), req, 2)
+		as.NoError(err)
+		as.Equal(9, result.Round.ID)
+		roundRepo.AssertExpectations(t)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("rule violation: a self-excluded player cannot bet", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		playerRepo := &repository.PlayerRepositoryMock{}
+		from, until := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		playerRepo.On("Get", context.Background(), "2").Return(domain.Player{ID: 2, ExcludedFrom: &from, ExcludedUntil: &until}, nil).Once()
+		service := NewRoundService(roundRepo, nil, _playerService.NewPlayerService(playerRepo))
+		_, err := service.Bet(context.Background(), req, 0)
+		as.ErrorIs(err, domain.ErrPlayerExcluded)
+		roundRepo.AssertNotCalled(t, "StartAction", mock.Anything, mock.Anything)
+	})
+
+	t.Run("happy path: a retried bet is answered without debiting again", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		transactionID := 40
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{
+			ID: 1, RoundID: 9, Type: domain.RoundActionBet, Amount: decimal.NewFromInt(10), Status: domain.RoundActionCompleted, TransactionID: &transactionID,
+		}, nil).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		walletInMemoryDB.On("Get", context.Background(), "5").Return(wallet, nil).Once()
+		service := NewRoundService(roundRepo, _walletService.NewWalletService(walletRepo, walletInMemoryDB), nil)
+		result, err := service.Bet(context.Background(), req, 2)
+		as.NoError(err)
+		as.Equal(&transactionID, result.Action.TransactionID)
+		walletRepo.AssertNotCalled(t, "Debit", mock.Anything, mock.Anything, mock.Anything)
+		roundRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: a retry while the bet is being processed conflicts", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{
+			ID: 1, RoundID: 9, Provider: "acme", ProviderTransactionID: "tx-1", Type: domain.RoundActionBet,
+			Amount: decimal.NewFromInt(10), Status: domain.RoundActionPending, UpdatedAt: time.Now(),
+		}, nil).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		service := NewRoundService(roundRepo, nil, nil)
+		_, err := service.Bet(context.Background(), req, 0)
+		as.ErrorIs(err, domain.ErrRoundActionPending)
+	})
+
+	t.Run("happy path: an abandoned bet whose debit was booked is completed with it", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{
+			ID: 1, RoundID: 9, Provider: "acme", ProviderTransactionID: "tx-1", Type: domain.RoundActionBet,
+			Amount: decimal.NewFromInt(10), Status: domain.RoundActionPending, UpdatedAt: time.Now().Add(-time.Hour),
+		}, nil).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		walletInMemoryDB.On("Get", context.Background(), "5").Return(wallet, nil)
+		walletRepo.On("ListTransactions", context.Background(), "5", mock.MatchedBy(func(f domain.TransactionFilter) bool {
+			return f.Reference == "tx-1" && f.Metadata["provider"] == "acme"
+		})).Return([]domain.Transaction{{ID: 40, WalletID: 5, Reference: "tx-1"}}, nil).Once()
+		roundRepo.On("CompleteAction", context.Background(), mock.Anything, mock.MatchedBy(func(a *domain.RoundAction) bool {
+			return a.ID == 1 && a.TransactionID != nil && *a.TransactionID == 40
+		})).Return(nil).Once()
+		service := NewRoundService(roundRepo, _walletService.NewWalletService(walletRepo, walletInMemoryDB), nil)
+		result, err := service.Bet(context.Background(), req, 0)
+		as.NoError(err)
+		as.Equal(40, *result.Action.TransactionID)
+		walletRepo.AssertNotCalled(t, "Debit", mock.Anything, mock.Anything, mock.Anything)
+		roundRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: an abandoned bet that never debited is dropped and run again", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		playerRepo := &repository.PlayerRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{
+			ID: 1, RoundID: 9, Provider: "acme", ProviderTransactionID: "tx-1", Type: domain.RoundActionBet,
+			Amount: decimal.NewFromInt(10), Status: domain.RoundActionPending, UpdatedAt: time.Now().Add(-time.Hour),
+		}, nil).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Twice()
+		walletInMemoryDB.On("Get", context.Background(), "5").Return(wallet, nil)
+		walletRepo.On("ListTransactions", context.Background(), "5", mock.Anything).Return([]domain.Transaction{}, nil).Once()
+		roundRepo.On("DeleteAction", context.Background(), mock.MatchedBy(func(a *domain.RoundAction) bool {
+			return a.ID == 1
+		})).Return(nil).Once()
+		playerRepo.On("Get", context.Background(), "2").Return(domain.Player{ID: 2}, nil).Once()
+		roundRepo.On("StartAction", context.Background(), mock.Anything).Return(nil).Once()
+		walletRepo.On("Get", mock.Anything, "5").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", mock.Anything, mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil)
+		walletRepo.On("Debit", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+		walletInMemoryDB.On("Delete", mock.Anything, "5").Return(nil).Once()
+		roundRepo.On("CompleteAction", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
+		service := NewRoundService(roundRepo, _walletService.NewWalletService(walletRepo, walletInMemoryDB), _playerService.NewPlayerService(playerRepo))
+		_, err := service.Bet(context.Background(), req, 0)
+		as.NoError(err)
+		roundRepo.AssertExpectations(t)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: a transaction ID reused with another amount conflicts", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{
+			ID: 1, RoundID: 9, Type: domain.RoundActionBet, Amount: decimal.NewFromInt(20), Status: domain.RoundActionCompleted,
+		}, nil).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		service := NewRoundService(roundRepo, nil, nil)
+		_, err := service.Bet(context.Background(), req, 2)
+		as.ErrorIs(err, domain.ErrRoundActionConflict)
+	})
+
+	t.Run("input error: a bet rolled back before it arrived is refused", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{
+			ID: 2, RoundID: 9, Provider: "acme", ProviderTransactionID: "tx-1", Type: domain.RoundActionTombstone, Status: domain.RoundActionCompleted,
+		}, nil).Once()
+		service := NewRoundService(roundRepo, nil, nil)
+		_, err := service.Bet(context.Background(), req, 2)
+		as.ErrorIs(err, domain.ErrBetRolledBack)
+		roundRepo.AssertNotCalled(t, "StartAction", mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: a bet overtaken by its rollback while opening the round is refused", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		playerRepo := &repository.PlayerRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		playerRepo.On("Get", context.Background(), "2").Return(domain.Player{ID: 2}, nil).Once()
+		roundRepo.On("StartAction", context.Background(), mock.Anything).Return(domain.ErrActionExists).Once()
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{
+			ID: 2, RoundID: 9, Provider: "acme", ProviderTransactionID: "tx-1", Type: domain.RoundActionTombstone, Status: domain.RoundActionCompleted,
+		}, nil).Once()
+		service := NewRoundService(roundRepo, nil, _playerService.NewPlayerService(playerRepo))
+		_, err := service.Bet(context.Background(), req, 2)
+		as.ErrorIs(err, domain.ErrBetRolledBack)
+		roundRepo.AssertExpectations(t)
+	})
+
+	t.Run("system error: a failed debit releases the bet once no debit is booked", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		playerRepo := &repository.PlayerRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		playerRepo.On("Get", context.Background(), "2").Return(domain.Player{ID: 2}, nil).Once()
+		roundRepo.On("StartAction", context.Background(), mock.Anything).Return(nil).Once()
+		walletRepo.On("Get", mock.Anything, "5").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", mock.Anything, mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil)
+		walletRepo.On("Debit", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("connection reset")).Once()
+		walletInMemoryDB.On("Get", context.Background(), "5").Return(wallet, nil)
+		walletRepo.On("ListTransactions", context.Background(), "5", mock.MatchedBy(func(f domain.TransactionFilter) bool {
+			return f.Reference == "tx-1"
+		})).Return([]domain.Transaction{}, nil).Once()
+		roundRepo.On("DeleteAction", context.Background(), mock.Anything).Return(nil).Once()
+		service := NewRoundService(roundRepo, _walletService.NewWalletService(walletRepo, walletInMemoryDB), _playerService.NewPlayerService(playerRepo))
+		_, err := service.Bet(context.Background(), req, 2)
+		as.EqualError(err, "connection reset")
+		roundRepo.AssertExpectations(t)
+	})
+
+	t.Run("system error: a failed debit that may have been booked stays pending", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		playerRepo := &repository.PlayerRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		playerRepo.On("Get", context.Background(), "2").Return(domain.Player{ID: 2}, nil).Once()
+		roundRepo.On("StartAction", context.Background(), mock.Anything).Return(nil).Once()
+		walletRepo.On("Get", mock.Anything, "5").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", mock.Anything, mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil)
+		walletRepo.On("Debit", mock.Anything, mock.Anything, mock.Anything).Return(context.DeadlineExceeded).Once()
+		walletInMemoryDB.On("Get", context.Background(), "5").Return(wallet, nil)
+		walletRepo.On("ListTransactions", context.Background(), "5", mock.Anything).Return([]domain.Transaction(nil), errors.New("connection reset")).Once()
+		service := NewRoundService(roundRepo, _walletService.NewWalletService(walletRepo, walletInMemoryDB), _playerService.NewPlayerService(playerRepo))
+		_, err := service.Bet(context.Background(), req, 2)
+		as.ErrorIs(err, context.DeadlineExceeded)
+		roundRepo.AssertNotCalled(t, "DeleteAction", mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: provider IDs are required", func(t *testing.T) {
+		service := NewRoundService(&repository.RoundRepositoryMock{}, nil, nil)
+		_, err := service.Bet(context.Background(), domain.RoundRequest{Provider: "acme", Amount: "10"}, 2)
+		as.ErrorIs(err, domain.ErrInvalidRoundRequest)
+	})
+}
+
+func TestWin(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("input error: wins on a closed round are refused", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-2").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(domain.Round{ID: 9, WalletID: 5, Status: domain.RoundStatusClosed}, nil).Once()
+		service := NewRoundService(roundRepo, nil, nil)
+		_, err := service.Win(context.Background(), domain.RoundRequest{Provider: "acme", RoundID: "r-1", TransactionID: "tx-2", Amount: "25"}, 2)
+		as.ErrorIs(err, domain.ErrRoundClosed)
+	})
+}
+
+func TestRollback(t *testing.T) {
+	as := assert.New(t)
+	wallet := domain.Wallet{ID: 5, PlayerID: 2, Balance: decimal.NewFromInt(100), Currency: "EUR", Status: domain.WalletStatusActive}
+	round := domain.Round{ID: 9, Provider: "acme", ProviderRoundID: "r-1", WalletID: 5, PlayerID: 2, Status: domain.RoundStatusOpen}
+	req := domain.RoundRequest{Provider: "acme", RoundID: "r-1", TransactionID: "rb-1", WalletID: "5", RollbackTransactionID: "tx-1"}
+
+	t.Run("happy path: rolling back an unknown bet records it without moving funds", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "rb-1").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		roundRepo.On("StartAction", context.Background(), mock.MatchedBy(func(a *domain.RoundAction) bool {
+			return a != nil && a.RoundID == 9 && a.Type == domain.RoundActionTombstone && a.ProviderTransactionID == "tx-1" &&
+				a.Status == domain.RoundActionCompleted
+		})).Return(nil).Once()
+		roundRepo.On("StartAction", context.Background(), mock.MatchedBy(func(a *domain.RoundAction) bool {
+			return a != nil && a.RoundID == 9 && a.Type == domain.RoundActionRollback && a.Amount.IsZero() &&
+				a.RollbackOf != nil && *a.RollbackOf == "tx-1"
+		})).Return(nil).Once()
+		roundRepo.On("CompleteAction", context.Background(), mock.Anything, mock.MatchedBy(func(a *domain.RoundAction) bool {
+			return a != nil && a.TransactionID == nil
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Get", context.Background(), "5").Return(wallet, nil).Once()
+		service := NewRoundService(roundRepo, _walletService.NewWalletService(walletRepo, walletInMemoryDB), nil)
+		result, err := service.Rollback(context.Background(), req, 2)
+		as.NoError(err)
+		as.True(result.Balance.Equal(decimal.NewFromInt(100)))
+		walletRepo.AssertNotCalled(t, "Credit", mock.Anything, mock.Anything, mock.Anything)
+		roundRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: a second rollback of the same bet is refused", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "rb-2").Return(domain.RoundAction{}, domain.ErrRecordNotFound)
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{
+			ID: 2, RoundID: 9, Provider: "acme", ProviderTransactionID: "tx-1", Type: domain.RoundActionTombstone, Status: domain.RoundActionCompleted,
+		}, nil).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		roundRepo.On("StartAction", context.Background(), mock.Anything).Return(domain.ErrActionExists).Once()
+		service := NewRoundService(roundRepo, nil, nil)
+		second := req
+		second.TransactionID = "rb-2"
+		_, err := service.Rollback(context.Background(), second, 2)
+		as.ErrorIs(err, domain.ErrBetRolledBack)
+	})
+
+	t.Run("input error: a rollback racing the bet it names is retried", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "rb-1").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetRound", context.Background(), "acme", "r-1").Return(round, nil).Once()
+		roundRepo.On("StartAction", context.Background(), mock.MatchedBy(func(a *domain.RoundAction) bool {
+			return a != nil && a.Type == domain.RoundActionTombstone
+		})).Return(domain.ErrActionExists).Once()
+		service := NewRoundService(roundRepo, nil, nil)
+		_, err := service.Rollback(context.Background(), req, 2)
+		as.ErrorIs(err, domain.ErrRoundActionPending)
+		roundRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: only bets can be rolled back", func(t *testing.T) {
+		roundRepo := &repository.RoundRepositoryMock{}
+		roundRepo.On("GetAction", context.Background(), "acme", "rb-1").Return(domain.RoundAction{}, domain.ErrRecordNotFound).Once()
+		roundRepo.On("GetAction", context.Background(), "acme", "tx-1").Return(domain.RoundAction{
+			ID: 1, RoundID: 9, Type: domain.RoundActionWin, Status: domain.RoundActionCompleted,
+		}, nil).Once()
+		service := NewRoundService(roundRepo, nil, nil)
+		_, err := service.Rollback(context.Background(), req, 2)
+		as.ErrorIs(err, domain.ErrRollbackNotBet)
+	})
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"quik/domain"
+	"quik/wallet/handler/middleware"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleHandler struct {
+	ScheduleService domain.ScheduleService
+}
+
+func NewScheduleHandler(router *gin.Engine, ss domain.ScheduleService) {
+	handler := &ScheduleHandler{
+		ScheduleService: ss,
+	}
+
+	api := router.Group("/api/v1")
+	api.POST("/wallets/:wallet_id/schedules", middleware.AuthAdmin(), handler.CreateSchedule)
+	api.GET("/wallets/:wallet_id/schedules", middleware.AuthAdmin(), handler.ListSchedules)
+	api.POST("/schedules/:schedule_id/cancel", middleware.AuthAdmin(), handler.CancelSchedule)
+	api.GET("/schedules/:schedule_id/executions", middleware.AuthAdmin(), handler.ListExecutions)
+}
+
+func isValidInteger(value string) bool {
+	intValue, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || intValue < 1 {
+		return false
+	}
+	return true
+}
+
+func (s *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	var input struct {
+		Amount      string `json:"amount"`
+		RunAt       string `json:"run_at"`
+		Recurrence  string `json:"recurrence"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	runAt, err := time.Parse(time.RFC3339, input.RunAt)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid run_at"})
+		return
+	}
+	var ctx = context.TODO()
+	schedule, err := s.ScheduleService.Create(ctx, walletId, input.Amount, runAt, domain.Recurrence(input.Recurrence), input.Description)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidRecurrence),
+			errors.Is(err, domain.ErrScheduleInPast):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidAmount),
+			errors.Is(err, domain.ErrAmountPrecision):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrWalletClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "credit scheduled", "payload": schedule})
+}
+
+func (s *ScheduleHandler) ListSchedules(c *gin.Context) {
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	var ctx = context.TODO()
+	schedules, err := s.ScheduleService.ListByWallet(ctx, walletId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"payload": schedules})
+}
+
+func (s *ScheduleHandler) CancelSchedule(c *gin.Context) {
+	scheduleId := c.Param("schedule_id")
+	if !isValidInteger(scheduleId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid schedule id"})
+		return
+	}
+	var ctx = context.TODO()
+	schedule, err := s.ScheduleService.Cancel(ctx, scheduleId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrScheduleNotActive):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "schedule cancelled", "payload": schedule})
+}
+
+func (s *ScheduleHandler) ListExecutions(c *gin.Context) {
+	scheduleId := c.Param("schedule_id")
+	if !isValidInteger(scheduleId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid schedule id"})
+		return
+	}
+	var ctx = context.TODO()
+	executions, err := s.ScheduleService.ListExecutions(ctx, scheduleId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"payload": executions})
+}
@@ -0,0 +1,154 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"quik/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type mysqlScheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewMySqlScheduleRepository(db *gorm.DB) domain.ScheduleRepository {
+	return &mysqlScheduleRepository{db: db}
+}
+
+func (s *mysqlScheduleRepository) Create(ctx context.Context, schedule *domain.Schedule) error {
+	return s.db.WithContext(ctx).Create(schedule).Error
+}
+
+func (s *mysqlScheduleRepository) Get(ctx context.Context, id string) (domain.Schedule, error) {
+	var schedule domain.Schedule
+	err := s.db.WithContext(ctx).Where("id = ?", id).First(&schedule).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return domain.Schedule{}, domain.ErrRecordNotFound
+		default:
+			return domain.Schedule{}, err
+		}
+	}
+	return schedule, nil
+}
+
+func (s *mysqlScheduleRepository) ListByWallet(ctx context.Context, walletID string) ([]domain.Schedule, error) {
+	var schedules []domain.Schedule
+	err := s.db.WithContext(ctx).Where("wallet_id = ?", walletID).Order("id").Find(&schedules).Error
+	return schedules, err
+}
+
+func (s *mysqlScheduleRepository) Cancel(ctx context.Context, schedule *domain.Schedule) error {
+	now := time.Now()
+	result := s.db.WithContext(ctx).Model(&domain.Schedule{}).
+		Where("id = ? AND status = ?", schedule.ID, domain.ScheduleStatusActive).
+		Updates(map[string]interface{}{
+			"status":      domain.ScheduleStatusCancelled,
+			"next_run_at": nil,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrScheduleNotActive
+	}
+	schedule.Status = domain.ScheduleStatusCancelled
+	schedule.NextRunAt = nil
+	schedule.UpdatedAt = now
+	return nil
+}
+
+// ClaimDue takes the leases with a single UPDATE, so two instances claiming
+// at once can never both get the same schedule.
+func (s *mysqlScheduleRepository) ClaimDue(ctx context.Context, owner string, now, leaseUntil time.Time, limit int) ([]domain.Schedule, error) {
+	err := s.db.WithContext(ctx).Model(&domain.Schedule{}).
+		Where("status = ? AND next_run_at <= ?", domain.ScheduleStatusActive, now).
+		Where("lease_until IS NULL OR lease_until < ?", now).
+		Order("next_run_at").
+		Limit(limit).
+		Updates(map[string]interface{}{
+			"lease_owner": owner,
+			"lease_until": leaseUntil,
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+	var schedules []domain.Schedule
+	err = s.db.WithContext(ctx).
+		Where("status = ? AND next_run_at <= ?", domain.ScheduleStatusActive, now).
+		Where("lease_owner = ? AND lease_until >= ?", owner, now).
+		Order("next_run_at").
+		Limit(limit).
+		Find(&schedules).Error
+	return schedules, err
+}
+
+func (s *mysqlScheduleRepository) StartExecution(ctx context.Context, execution *domain.ScheduleExecution) error {
+	result := s.db.WithContext(ctx).Clauses(clause.Insert{Modifier: "IGNORE"}).Create(execution)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrExecutionExists
+	}
+	return nil
+}
+
+func (s *mysqlScheduleRepository) GetExecution(ctx context.Context, scheduleID int, scheduledFor time.Time) (domain.ScheduleExecution, error) {
+	var execution domain.ScheduleExecution
+	err := s.db.WithContext(ctx).Where("schedule_id = ? AND scheduled_for = ?", scheduleID, scheduledFor).First(&execution).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return domain.ScheduleExecution{}, domain.ErrRecordNotFound
+		default:
+			return domain.ScheduleExecution{}, err
+		}
+	}
+	return execution, nil
+}
+
+func (s *mysqlScheduleRepository) FinishExecution(ctx context.Context, owner string, schedule *domain.Schedule, execution *domain.ScheduleExecution) error {
+	if execution != nil {
+		err := s.db.WithContext(ctx).Model(execution).Updates(map[string]interface{}{
+			"status":         execution.Status,
+			"transaction_id": execution.TransactionID,
+			"error":          execution.Error,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	now := time.Now()
+	// A schedule cancelled while it ran stays cancelled.
+	result := s.db.WithContext(ctx).Model(&domain.Schedule{}).
+		Where("id = ? AND lease_owner = ? AND status = ?", schedule.ID, owner, domain.ScheduleStatusActive).
+		Updates(map[string]interface{}{
+			"status":      schedule.Status,
+			"next_run_at": schedule.NextRunAt,
+			"lease_owner": "",
+			"lease_until": nil,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrLeaseLost
+	}
+	schedule.LeaseOwner = ""
+	schedule.LeaseUntil = nil
+	schedule.UpdatedAt = now
+	return nil
+}
+
+func (s *mysqlScheduleRepository) ListExecutions(ctx context.Context, scheduleID int) ([]domain.ScheduleExecution, error) {
+	var executions []domain.ScheduleExecution
+	err := s.db.WithContext(ctx).Where("schedule_id = ?", scheduleID).Order("id desc").Find(&executions).Error
+	return executions, err
+}
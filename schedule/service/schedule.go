@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"quik/domain"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// scheduleLeaseTTL is how long an instance may take to run a claimed
+	// schedule before another instance can claim it again.
+	scheduleLeaseTTL       = 5 * time.Minute
+	dueSchedulesBatchSize  = 100
+	maxExecutionErrorWidth = 255
+)
+
+type scheduleService struct {
+	scheduleRepository domain.ScheduleRepository
+	walletService      domain.WalletService
+	owner              string
+}
+
+// NewScheduleService returns a schedule service crediting wallets through
+// ws. owner identifies this instance in the leases it takes and must differ
+// between instances sharing a database.
+func NewScheduleService(r domain.ScheduleRepository, ws domain.WalletService, owner string) domain.ScheduleService {
+	return &scheduleService{
+		scheduleRepository: r,
+		walletService:      ws,
+		owner:              owner,
+	}
+}
+
+func (s *scheduleService) Create(ctx context.Context, walletID, amount string, runAt time.Time, recurrence domain.Recurrence, description string) (domain.Schedule, error) {
+	if recurrence == "" {
+		recurrence = domain.RecurrenceOnce
+	}
+	if !recurrence.Valid() {
+		return domain.Schedule{}, domain.ErrInvalidRecurrence
+	}
+	if !runAt.After(time.Now()) {
+		return domain.Schedule{}, domain.ErrScheduleInPast
+	}
+	wallet, err := s.walletService.Get(ctx, walletID)
+	if err != nil {
+		return domain.Schedule{}, err
+	}
+	if err := wallet.CanCredit(); err != nil {
+		return domain.Schedule{}, err
+	}
+	creditAmount, err := decimal.NewFromString(amount)
+	if err != nil || !creditAmount.IsPositive() {
+		return domain.Schedule{}, domain.ErrInvalidAmount
+	}
+	currency, err := domain.LookupCurrency(wallet.Currency)
+	if err != nil {
+		return domain.Schedule{}, err
+	}
+	if err := currency.ValidateAmount(creditAmount); err != nil {
+		return domain.Schedule{}, err
+	}
+	schedule := domain.Schedule{
+		WalletID:    wallet.ID,
+		PlayerID:    wallet.PlayerID,
+		Amount:      creditAmount,
+		Recurrence:  recurrence,
+		Status:      domain.ScheduleStatusActive,
+		StartsAt:    &runAt,
+		NextRunAt:   &runAt,
+		Description: description,
+	}
+	err = s.scheduleRepository.Create(ctx, &schedule)
+	return schedule, err
+}
+
+func (s *scheduleService) ListByWallet(ctx context.Context, walletID string) ([]domain.Schedule, error) {
+	if _, err := s.walletService.Get(ctx, walletID); err != nil {
+		return nil, err
+	}
+	return s.scheduleRepository.ListByWallet(ctx, walletID)
+}
+
+func (s *scheduleService) Cancel(ctx context.Context, id string) (domain.Schedule, error) {
+	schedule, err := s.scheduleRepository.Get(ctx, id)
+	if err != nil {
+		return domain.Schedule{}, err
+	}
+	if err := s.scheduleRepository.Cancel(ctx, &schedule); err != nil {
+		return domain.Schedule{}, err
+	}
+	return schedule, nil
+}
+
+func (s *scheduleService) ListExecutions(ctx context.Context, id string) ([]domain.ScheduleExecution, error) {
+	schedule, err := s.scheduleRepository.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.scheduleRepository.ListExecutions(ctx, schedule.ID)
+}
+
+// RunDue claims due schedules in batches until none are left. A schedule
+// whose credit is refused still moves on to its next run; the failure is
+// kept in its execution history. Other failures are returned once the rest
+// of the batch has run, and their schedules are retried after the lease.
+func (s *scheduleService) RunDue(ctx context.Context) (int, error) {
+	ran := 0
+	var failed error
+	for {
+		now := time.Now()
+		schedules, err := s.scheduleRepository.ClaimDue(ctx, s.owner, now, now.Add(scheduleLeaseTTL), dueSchedulesBatchSize)
+		if err != nil {
+			return ran, err
+		}
+		for i := range schedules {
+			executed, err := s.run(ctx, &schedules[i], now)
+			switch {
+			case err == nil:
+				if executed {
+					ran++
+				}
+			case errors.Is(err, domain.ErrLeaseLost):
+				// Cancelled or taken over while it ran; its execution is
+				// recorded all the same.
+				if executed {
+					ran++
+				}
+			default:
+				if failed == nil {
+					failed = err
+				}
+			}
+		}
+		if len(schedules) < dueSchedulesBatchSize {
+			return ran, failed
+		}
+	}
+}
+
+// run credits the occurrence due at schedule.NextRunAt, unless an execution
+// of it has already finished, and moves the schedule to its next occurrence.
+// It reports whether it credited the wallet or tried to. A credit failing
+// for a reason that may pass leaves the execution running and the schedule
+// where it is, so that it is retried once the lease runs out.
+func (s *scheduleService) run(ctx context.Context, schedule *domain.Schedule, now time.Time) (bool, error) {
+	execution := &domain.ScheduleExecution{
+		ScheduleID:   schedule.ID,
+		ScheduledFor: *schedule.NextRunAt,
+		WalletID:     schedule.WalletID,
+		Amount:       schedule.Amount,
+		Status:       domain.ExecutionStatusRunning,
+	}
+	err := s.scheduleRepository.StartExecution(ctx, execution)
+	switch {
+	case errors.Is(err, domain.ErrExecutionExists):
+		existing, err := s.scheduleRepository.GetExecution(ctx, schedule.ID, execution.ScheduledFor)
+		if err != nil {
+			return false, err
+		}
+		if existing.Status != domain.ExecutionStatusRunning {
+			execution = nil
+			break
+		}
+		// We hold the lease, so the instance that started the execution
+		// lost it before finishing; the wallet may or may not have been
+		// credited.
+		execution = &existing
+		if err := s.execute(ctx, schedule, execution, true); err != nil {
+			return false, err
+		}
+	case err != nil:
+		return false, err
+	default:
+		if err := s.execute(ctx, schedule, execution, false); err != nil {
+			return false, err
+		}
+	}
+	start := *schedule.NextRunAt
+	if schedule.StartsAt != nil {
+		start = *schedule.StartsAt
+	}
+	schedule.NextRunAt = schedule.Recurrence.Next(start, *schedule.NextRunAt, now)
+	if schedule.NextRunAt == nil {
+		schedule.Status = domain.ScheduleStatusCompleted
+	}
+	return execution != nil, s.scheduleRepository.FinishExecution(ctx, s.owner, schedule, execution)
+}
+
+// execute credits the wallet for the execution and records the outcome on
+// it. When recovering an execution that was left running, it first looks
+// for the credit by its reference so that it is not booked twice.
+func (s *scheduleService) execute(ctx context.Context, schedule *domain.Schedule, execution *domain.ScheduleExecution, recovering bool) error {
+	walletID := strconv.Itoa(schedule.WalletID)
+	details := domain.TransactionDetails{
+		Reference:   fmt.Sprintf("schedule:%d:%d", schedule.ID, execution.ScheduledFor.Unix()),
+		Description: schedule.Description,
+		Category:    domain.CategoryPromotion,
+	}
+	var transaction domain.Transaction
+	var err error
+	if recovering {
+		var booked []domain.Transaction
+		booked, _, err = s.walletService.ListTransactions(ctx, walletID, domain.TransactionFilter{Reference: details.Reference, Limit: 1})
+		if err == nil && len(booked) > 0 {
+			transaction = booked[0]
+		}
+	}
+	if err == nil && transaction.ID == 0 {
+		transaction, err = s.walletService.Credit(ctx, walletID, schedule.Amount.String(), schedule.PlayerID, details)
+	}
+	switch {
+	case err == nil:
+		execution.Status = domain.ExecutionStatusSucceeded
+		execution.TransactionID = &transaction.ID
+	case permanent(err):
+		execution.Status = domain.ExecutionStatusFailed
+		execution.Error = truncate(err.Error(), maxExecutionErrorWidth)
+	default:
+		return err
+	}
+	return nil
+}
+
+// permanent reports whether a credit failed for a reason retrying will not
+// change.
+func permanent(err error) bool {
+	for _, target := range []error{
+		domain.ErrRecordNotFound,
+		domain.ErrWalletClosed,
+		domain.ErrInvalidAmount,
+		domain.ErrAmountPrecision,
+		domain.ErrUnsupportedCurrency,
+		domain.ErrInvalidCategory,
+		domain.ErrDetailsTooLong,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(s string, width int) string {
+	if len(s) > width {
+		return s[:width]
+	}
+	return s
+}
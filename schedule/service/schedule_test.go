@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"quik/domain"
+	"quik/domain/mocks/inmemorydb"
+	"quik/domain/mocks/repository"
+	_walletService "quik/wallet/service"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRecurrenceNext(t *testing.T) {
+	as := assert.New(t)
+	monday := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	as.Nil(domain.RecurrenceOnce.Next(monday, monday, monday))
+	as.Equal(monday.AddDate(0, 0, 7), *domain.RecurrenceWeekly.Next(monday, monday, monday))
+	// Missed runs are skipped, not replayed.
+	as.Equal(monday.AddDate(0, 0, 21), *domain.RecurrenceWeekly.Next(monday, monday, monday.AddDate(0, 0, 15)))
+	as.Equal(monday.AddDate(0, 1, 0), *domain.RecurrenceMonthly.Next(monday, monday, monday.Add(time.Hour)))
+
+	// Monthly runs stay on the last day of shorter months without drifting.
+	endOfJanuary := time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC)
+	endOfFebruary := time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC)
+	as.Equal(endOfFebruary, *domain.RecurrenceMonthly.Next(endOfJanuary, endOfJanuary, endOfJanuary))
+	as.Equal(time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC), *domain.RecurrenceMonthly.Next(endOfJanuary, endOfFebruary, endOfFebruary))
+	as.Equal(time.Date(2026, 4, 30, 9, 0, 0, 0, time.UTC), *domain.RecurrenceMonthly.Next(endOfJanuary, endOfFebruary, endOfFebruary.AddDate(0, 0, 31)))
+}
+
+func TestCreateSchedule(t *testing.T) {
+	as := assert.New(t)
+	wallet := domain.Wallet{ID: 5, PlayerID: 2, Balance: decimal.Zero, Currency: "EUR", Status: domain.WalletStatusActive}
+	runAt := time.Now().Add(time.Hour)
+
+	t.Run("happy path: a weekly credit is scheduled for the wallet's owner", func(t *testing.T) {
+		scheduleRepo := &repository.ScheduleRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletInMemoryDB.On("Get", context.Background(), "5").Return(wallet, nil).Once()
+		scheduleRepo.On("Create", context.Background(), mock.MatchedBy(func(s *domain.Schedule) bool {
+			return s != nil && s.WalletID == 5 && s.PlayerID == 2 && s.Amount.Equal(decimal.NewFromInt(10)) &&
+				s.Recurrence == domain.RecurrenceWeekly && s.Status == domain.ScheduleStatusActive && s.NextRunAt.Equal(runAt)
+		})).Return(nil).Once()
+		walletService := _walletService.NewWalletService(&repository.WalletRepositoryMock{}, walletInMemoryDB)
+		service := NewScheduleService(scheduleRepo, walletService, "instance-a")
+		_, err := service.Create(context.Background(), "5", "10.00", runAt, domain.RecurrenceWeekly, "weekly reload")
+		as.NoError(err)
+		scheduleRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: schedules must start in the future and repeat validly", func(t *testing.T) {
+		service := NewScheduleService(&repository.ScheduleRepositoryMock{}, nil, "instance-a")
+		_, err := service.Create(context.Background(), "5", "10", time.Now().Add(-time.Minute), domain.RecurrenceOnce, "")
+		as.ErrorIs(err, domain.ErrScheduleInPast)
+		_, err = service.Create(context.Background(), "5", "10", runAt, "hourly", "")
+		as.ErrorIs(err, domain.ErrInvalidRecurrence)
+	})
+
+	t.Run("input error: the amount must fit the wallet currency", func(t *testing.T) {
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletInMemoryDB.On("Get", context.Background(), "5").Return(wallet, nil).Once()
+		walletService := _walletService.NewWalletService(&repository.WalletRepositoryMock{}, walletInMemoryDB)
+		service := NewScheduleService(&repository.ScheduleRepositoryMock{}, walletService, "instance-a")
+		_, err := service.Create(context.Background(), "5", "10.001", runAt, domain.RecurrenceOnce, "")
+		as.ErrorIs(err, domain.ErrAmountPrecision)
+	})
+}
+
+func TestRunDue(t *testing.T) {
+	as := assert.New(t)
+	wallet := domain.Wallet{ID: 5, PlayerID: 2, Balance: decimal.Zero, Currency: "EUR", Status: domain.WalletStatusActive}
+	due := time.Now().Add(-time.Minute)
+
+	t.Run("happy path: a due schedule credits the wallet once and moves on", func(t *testing.T) {
+		scheduleRepo := &repository.ScheduleRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		schedule := domain.Schedule{ID: 3, WalletID: 5, PlayerID: 2, Amount: decimal.NewFromInt(10), Recurrence: domain.RecurrenceWeekly, Status: domain.ScheduleStatusActive, NextRunAt: &due}
+		scheduleRepo.On("ClaimDue", context.Background(), "instance-a", mock.Anything, mock.Anything, dueSchedulesBatchSize).Return([]domain.Schedule{schedule}, nil).Once()
+		scheduleRepo.On("StartExecution", context.Background(), mock.MatchedBy(func(e *domain.ScheduleExecution) bool {
+			return e != nil && e.ScheduleID == 3 && e.ScheduledFor.Equal(due) && e.Status == domain.ExecutionStatusRunning
+		})).Return(nil).Once()
+		walletRepo.On("Get", context.Background(), "5").Return(wallet, nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.Amount.Equal(decimal.NewFromInt(10)) && t.PlayerID == 2 && t.Category == domain.CategoryPromotion
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "5").Return(nil).Once()
+		scheduleRepo.On("FinishExecution", context.Background(), "instance-a", mock.MatchedBy(func(s *domain.Schedule) bool {
+			return s != nil && s.Status == domain.ScheduleStatusActive && s.NextRunAt.Equal(due.AddDate(0, 0, 7))
+		}), mock.MatchedBy(func(e *domain.ScheduleExecution) bool {
+			return e != nil && e.Status == domain.ExecutionStatusSucceeded && e.TransactionID != nil
+		})).Return(nil).Once()
+		walletService := _walletService.NewWalletService(walletRepo, walletInMemoryDB)
+		service := NewScheduleService(scheduleRepo, walletService, "instance-a")
+		ran, err := service.RunDue(context.Background())
+		as.NoError(err)
+		as.Equal(1, ran)
+		scheduleRepo.AssertExpectations(t)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: an occurrence already executed elsewhere is not credited again", func(t *testing.T) {
+		scheduleRepo := &repository.ScheduleRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		schedule := domain.Schedule{ID: 3, WalletID: 5, Amount: decimal.NewFromInt(10), Recurrence: domain.RecurrenceOnce, Status: domain.ScheduleStatusActive, NextRunAt: &due}
+		scheduleRepo.On("ClaimDue", context.Background(), "instance-a", mock.Anything, mock.Anything, dueSchedulesBatchSize).Return([]domain.Schedule{schedule}, nil).Once()
+		scheduleRepo.On("StartExecution", context.Background(), mock.Anything).Return(domain.ErrExecutionExists).Once()
+		scheduleRepo.On("GetExecution", context.Background(), 3, due).Return(domain.ScheduleExecution{ID: 8, ScheduleID: 3, Status: domain.ExecutionStatusSucceeded}, nil).Once()
+		scheduleRepo.On("FinishExecution", context.Background(), "instance-a", mock.MatchedBy(func(s *domain.Schedule) bool {
+			return s != nil && s.Status == domain.ScheduleStatusCompleted && s.NextRunAt == nil
+		}), (*domain.ScheduleExecution)(nil)).Return(nil).Once()
+		walletService := _walletService.NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		service := NewScheduleService(scheduleRepo, walletService, "instance-a")
+		ran, err := service.RunDue(context.Background())
+		as.NoError(err)
+		as.Equal(0, ran)
+		walletRepo.AssertNotCalled(t, "Credit", mock.Anything, mock.Anything, mock.Anything)
+		scheduleRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: a failed credit is recorded and the schedule still moves on", func(t *testing.T) {
+		scheduleRepo := &repository.ScheduleRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		schedule := domain.Schedule{ID: 3, WalletID: 5, Amount: decimal.NewFromInt(10), Recurrence: domain.RecurrenceDaily, Status: domain.ScheduleStatusActive, NextRunAt: &due}
+		scheduleRepo.On("ClaimDue", context.Background(), "instance-a", mock.Anything, mock.Anything, dueSchedulesBatchSize).Return([]domain.Schedule{schedule}, nil).Once()
+		scheduleRepo.On("StartExecution", context.Background(), mock.Anything).Return(nil).Once()
+		walletRepo.On("Get", context.Background(), "5").Return(domain.Wallet{ID: 5, Currency: "EUR", Status: domain.WalletStatusClosed}, nil).Once()
+		scheduleRepo.On("FinishExecution", context.Background(), "instance-a", mock.MatchedBy(func(s *domain.Schedule) bool {
+			return s != nil && s.NextRunAt.Equal(due.AddDate(0, 0, 1))
+		}), mock.MatchedBy(func(e *domain.ScheduleExecution) bool {
+			return e != nil && e.Status == domain.ExecutionStatusFailed && e.Error == domain.ErrWalletClosed.Error()
+		})).Return(nil).Once()
+		walletService := _walletService.NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		service := NewScheduleService(scheduleRepo, walletService, "instance-a")
+		ran, err := service.RunDue(context.Background())
+		as.NoError(err)
+		as.Equal(1, ran)
+		scheduleRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: an execution left running finds its credit instead of repeating it", func(t *testing.T) {
+		scheduleRepo := &repository.ScheduleRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		schedule := domain.Schedule{ID: 3, WalletID: 5, Amount: decimal.NewFromInt(10), Recurrence: domain.RecurrenceOnce, Status: domain.ScheduleStatusActive, NextRunAt: &due}
+		reference := fmt.Sprintf("schedule:3:%d", due.Unix())
+		scheduleRepo.On("ClaimDue", context.Background(), "instance-a", mock.Anything, mock.Anything, dueSchedulesBatchSize).Return([]domain.Schedule{schedule}, nil).Once()
+		scheduleRepo.On("StartExecution", context.Background(), mock.Anything).Return(domain.ErrExecutionExists).Once()
+		scheduleRepo.On("GetExecution", context.Background(), 3, due).Return(domain.ScheduleExecution{ID: 8, ScheduleID: 3, ScheduledFor: due, Status: domain.ExecutionStatusRunning}, nil).Once()
+		walletInMemoryDB.On("Get", context.Background(), "5").Return(wallet, nil).Once()
+		walletRepo.On("ListTransactions", context.Background(), "5", mock.MatchedBy(func(f domain.TransactionFilter) bool {
+			return f.Reference == reference
+		})).Return([]domain.Transaction{{ID: 41, WalletID: 5, Reference: reference}}, nil).Once()
+		scheduleRepo.On("FinishExecution", context.Background(), "instance-a", mock.Anything, mock.MatchedBy(func(e *domain.ScheduleExecution) bool {
+			return e != nil && e.ID == 8 && e.Status == domain.ExecutionStatusSucceeded && *e.TransactionID == 41
+		})).Return(nil).Once()
+		walletService := _walletService.NewWalletService(walletRepo, walletInMemoryDB)
+		service := NewScheduleService(scheduleRepo, walletService, "instance-a")
+		ran, err := service.RunDue(context.Background())
+		as.NoError(err)
+		as.Equal(1, ran)
+		walletRepo.AssertNotCalled(t, "Credit", mock.Anything, mock.Anything, mock.Anything)
+		scheduleRepo.AssertExpectations(t)
+	})
+
+	t.Run("system error: a credit failing for a passing reason is left to be retried", func(t *testing.T) {
+		scheduleRepo := &repository.ScheduleRepositoryMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		schedule := domain.Schedule{ID: 3, WalletID: 5, Amount: decimal.NewFromInt(10), Recurrence: domain.RecurrenceDaily, Status: domain.ScheduleStatusActive, NextRunAt: &due}
+		scheduleRepo.On("ClaimDue", context.Background(), "instance-a", mock.Anything, mock.Anything, dueSchedulesBatchSize).Return([]domain.Schedule{schedule}, nil).Once()
+		scheduleRepo.On("StartExecution", context.Background(), mock.Anything).Return(nil).Once()
+		walletRepo.On("Get", context.Background(), "5").Return(domain.Wallet{}, errors.New("connection reset")).Once()
+		walletService := _walletService.NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		service := NewScheduleService(scheduleRepo, walletService, "instance-a")
+		ran, err := service.RunDue(context.Background())
+		as.EqualError(err, "connection reset")
+		as.Equal(0, ran)
+		scheduleRepo.AssertNotCalled(t, "FinishExecution", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -0,0 +1,200 @@
+package http
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"quik/domain"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statementFlushEvery is how many transactions are written between flushes
+// of a streamed statement.
+const statementFlushEvery = 100
+
+// statementStream sends the response headers with the opening balance, so
+// errors found before it can still be answered with a JSON error.
+type statementStream struct {
+	c           *gin.Context
+	contentType string
+	extension   string
+	currency    string
+	started     bool
+	pending     int
+}
+
+func (s *statementStream) start(statement domain.Statement) {
+	s.c.Header("Content-Type", s.contentType)
+	s.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"wallet-%d-statement.%s\"", statement.WalletID, s.extension))
+	s.c.Status(http.StatusOK)
+	s.currency = statement.Currency
+	s.started = true
+}
+
+func (s *statementStream) isStarted() bool {
+	return s.started
+}
+
+// written flushes the response once enough transactions have piled up.
+func (s *statementStream) written(flush func() error) error {
+	s.pending++
+	if s.pending < statementFlushEvery {
+		return nil
+	}
+	s.pending = 0
+	return flush()
+}
+
+type csvStatementWriter struct {
+	statementStream
+	w *csv.Writer
+}
+
+func newCSVStatementWriter(c *gin.Context) *csvStatementWriter {
+	return &csvStatementWriter{
+		statementStream: statementStream{c: c, contentType: "text/csv", extension: "csv"},
+		w:               csv.NewWriter(c.Writer),
+	}
+}
+
+func (s *csvStatementWriter) flush() error {
+	s.w.Flush()
+	s.c.Writer.Flush()
+	return s.w.Error()
+}
+
+func (s *csvStatementWriter) Opening(statement domain.Statement) error {
+	s.start(statement)
+	if err := s.w.Write([]string{"entry", "id", "created_at", "type", "category", "reference", "description", "amount", "balance", "currency"}); err != nil {
+		return err
+	}
+	return s.w.Write([]string{"opening_balance", "", statement.From.Format(time.RFC3339), "", "", "", "", "", statement.OpeningBalance.String(), statement.Currency})
+}
+
+func (s *csvStatementWriter) Transaction(t domain.Transaction) error {
+	err := s.w.Write([]string{"transaction", strconv.Itoa(t.ID), t.CreatedAt.Format(time.RFC3339), string(t.Type), string(t.Category), csvText(t.Reference), csvText(t.Description), t.Amount.String(), t.BalanceAfter.String(), s.currency})
+	if err != nil {
+		return err
+	}
+	return s.written(s.flush)
+}
+
+// csvText neutralises caller-supplied text that a spreadsheet would
+// otherwise run as a formula by prefixing it with an apostrophe.
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (s *csvStatementWriter) Closing(statement domain.Statement) error {
+	if err := s.w.Write([]string{"closing_balance", "", statement.To.Format(time.RFC3339), "", "", "", "", "", statement.ClosingBalance.String(), statement.Currency}); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+type ndjsonStatementWriter struct {
+	statementStream
+	enc *json.Encoder
+}
+
+func newNDJSONStatementWriter(c *gin.Context) *ndjsonStatementWriter {
+	return &ndjsonStatementWriter{
+		statementStream: statementStream{c: c, contentType: "application/x-ndjson", extension: "ndjson"},
+		enc:             json.NewEncoder(c.Writer),
+	}
+}
+
+func (s *ndjsonStatementWriter) flush() error {
+	s.c.Writer.Flush()
+	return nil
+}
+
+func (s *ndjsonStatementWriter) Opening(statement domain.Statement) error {
+	s.start(statement)
+	return s.enc.Encode(gin.H{"entry": "opening_balance", "at": statement.From, "balance": statement.OpeningBalance, "currency": statement.Currency})
+}
+
+func (s *ndjsonStatementWriter) Transaction(t domain.Transaction) error {
+	if err := s.enc.Encode(gin.H{"entry": "transaction", "transaction": t}); err != nil {
+		return err
+	}
+	return s.written(s.flush)
+}
+
+func (s *ndjsonStatementWriter) Closing(statement domain.Statement) error {
+	err := s.enc.Encode(gin.H{"entry": "closing_balance", "at": statement.To, "balance": statement.ClosingBalance, "currency": statement.Currency, "transactions": statement.Transactions})
+	if err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+func (w *WalletHandler) GetWalletStatement(c *gin.Context) {
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	var from, to time.Time
+	for param, target := range map[string]*time.Time{
+		"from": &from,
+		"to":   &to,
+	} {
+		if value := c.Query(param); value != "" {
+			date, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid " + param})
+				return
+			}
+			*target = date
+		}
+	}
+	var writer interface {
+		domain.StatementWriter
+		isStarted() bool
+	}
+	switch c.DefaultQuery("format", "csv") {
+	case "csv":
+		writer = newCSVStatementWriter(c)
+	case "ndjson":
+		writer = newNDJSONStatementWriter(c)
+	default:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid format"})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	if w.refuseForeign(ctx, c, playerId, walletId) {
+		return
+	}
+	err := w.WalletService.WriteStatement(ctx, walletId, from, to, writer)
+	if err == nil {
+		return
+	}
+	if writer.isStarted() {
+		// The status line is already out; all that is left is to cut the
+		// statement short, which shows as a missing closing balance.
+		log.Printf("Statement of wallet %s failed: %v\n", walletId, err)
+		return
+	}
+	switch {
+	case errors.Is(err, domain.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidStatementPeriod):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
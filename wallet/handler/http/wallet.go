@@ -3,28 +3,51 @@ package http
 import (
 	"context"
 	"errors"
+	"io"
 	"net/http"
 	"quik/domain"
 	"quik/wallet/handler/middleware"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 type WalletHandler struct {
 	WalletService domain.WalletService
+	PlayerService domain.PlayerService
 }
 
-func NewWalletHandler(router *gin.Engine, ws domain.WalletService) {
+func NewWalletHandler(router *gin.Engine, ws domain.WalletService, ps domain.PlayerService, is domain.IdempotencyStore) {
 	handler := &WalletHandler{
 		WalletService: ws,
+		PlayerService: ps,
 	}
 
 	api := router.Group("/api/v1")
 	api.POST("/wallets", middleware.AuthPlayer(), handler.CreateWallet)
+	api.POST("/wallets/batch", middleware.AuthAdmin(), middleware.Idempotent(is), handler.BatchWallets)
+	api.PUT("/wallets/:wallet_id/default", middleware.AuthPlayer(), handler.SetDefaultWallet)
+	api.PUT("/wallets/:wallet_id/label", middleware.AuthPlayer(), handler.SetWalletLabel)
 	api.GET("/wallets/:wallet_id/balance", middleware.AuthPlayer(), handler.GetWalletBalance)
-	api.POST("/wallets/:wallet_id/credit", middleware.AuthPlayer(), handler.CreditWallet)
-	api.POST("/wallets/:wallet_id/debit", middleware.AuthPlayer(), handler.DebitWallet)
+	api.POST("/wallets/:wallet_id/credit", middleware.AuthPlayer(), middleware.Idempotent(is), handler.CreditWallet)
+	api.POST("/wallets/:wallet_id/debit", middleware.AuthPlayer(), middleware.Idempotent(is), handler.DebitWallet)
+	api.GET("/wallets/:wallet_id/transactions", middleware.AuthPlayer(), handler.ListWalletTransactions)
+	api.GET("/wallets/:wallet_id/statement", middleware.AuthPlayer(), handler.GetWalletStatement)
+	api.POST("/transfers", middleware.AuthPlayer(), middleware.Idempotent(is), handler.CreateTransfer)
+	api.POST("/wallets/:wallet_id/holds", middleware.AuthPlayer(), middleware.Idempotent(is), handler.CreateHold)
+	api.POST("/wallets/:wallet_id/holds/:hold_id/capture", middleware.AuthPlayer(), middleware.Idempotent(is), handler.CaptureHold)
+	api.POST("/wallets/:wallet_id/holds/:hold_id/void", middleware.AuthPlayer(), middleware.Idempotent(is), handler.VoidHold)
+	api.POST("/wallets/:wallet_id/bonuses", middleware.AuthAdmin(), middleware.Idempotent(is), handler.CreditBonus)
+	api.GET("/wallets/:wallet_id/bonuses", middleware.AuthPlayer(), handler.ListBonuses)
+	api.POST("/transactions/:transaction_id/reverse", middleware.AuthAdmin(), middleware.Idempotent(is), handler.ReverseTransaction)
+	api.GET("/wallets/:wallet_id/limits", middleware.AuthPlayer(), handler.ListLimits)
+	api.PUT("/wallets/:wallet_id/limits", middleware.AuthPlayer(), handler.SetLimit)
+	api.PUT("/wallets/:wallet_id/credit-limit", middleware.AuthAdmin(), handler.SetCreditLimit)
+	api.POST("/wallets/:wallet_id/freeze", middleware.AuthAdmin(), handler.setWalletStatus(domain.WalletStatusFrozen, "wallet frozen"))
+	api.POST("/wallets/:wallet_id/unfreeze", middleware.AuthAdmin(), handler.setWalletStatus(domain.WalletStatusActive, "wallet unfrozen"))
+	api.POST("/wallets/:wallet_id/close", middleware.AuthAdmin(), handler.setWalletStatus(domain.WalletStatusClosed, "wallet closed"))
 }
 
 func isValidInteger(value string) bool {
@@ -36,23 +59,179 @@ func isValidInteger(value string) bool {
 }
 
 func (w *WalletHandler) CreateWallet(c *gin.Context) {
+	var input struct {
+		Currency  string `json:"currency"`
+		Label     string `json:"label"`
+		IsDefault bool   `json:"is_default"`
+	}
+	// The body is optional; wallets created without one hold euros.
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	var ctx = context.TODO()
 	var wallet domain.Wallet
 
 	id, _ := c.Get("playerId")
 	playerId, _ := id.(int)
 	wallet.PlayerID = playerId
+	wallet.Currency = input.Currency
+	wallet.Label = input.Label
+	wallet.IsDefault = input.IsDefault
 	err := w.WalletService.Create(ctx, &wallet)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrUnsupportedCurrency),
+			errors.Is(err, domain.ErrInvalidWalletLabel),
+			errors.Is(err, domain.ErrWalletLimitReached):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 	}
 	c.JSON(http.StatusOK, gin.H{"payload": wallet})
 }
 
+func (w *WalletHandler) SetDefaultWallet(c *gin.Context) {
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	wallet, err := w.WalletService.SetDefault(ctx, playerId, walletId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrWalletClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "default wallet set", "payload": wallet})
+}
+
+func (w *WalletHandler) SetWalletLabel(c *gin.Context) {
+	var input struct {
+		Label string `json:"label"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	wallet, err := w.WalletService.SetLabel(ctx, playerId, walletId, input.Label)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidWalletLabel):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "wallet label set", "payload": wallet})
+}
+
+// refuseForeign responds and returns true unless the wallet belongs to the
+// authenticated player. Other players' wallets are reported as not found.
+func (w *WalletHandler) refuseForeign(ctx context.Context, c *gin.Context, playerId int, walletId string) bool {
+	if _, err := w.WalletService.GetForPlayer(ctx, playerId, walletId); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return true
+	}
+	return false
+}
+
+// refuseExcluded responds and returns true when the authenticated player is
+// self-excluded, or when their exclusion cannot be checked.
+func (w *WalletHandler) refuseExcluded(ctx context.Context, c *gin.Context, playerId int) bool {
+	player, err := w.PlayerService.Get(ctx, strconv.Itoa(playerId))
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return true
+	}
+	if player.ExcludedAt(time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{"error": domain.ErrPlayerExcluded.Error()})
+		return true
+	}
+	return false
+}
+
+// ownerExcluded reports whether the player owning the wallet is
+// self-excluded. System wallets belong to no player and are never excluded.
+func (w *WalletHandler) ownerExcluded(ctx context.Context, walletId string) (bool, error) {
+	wallet, err := w.WalletService.Get(ctx, walletId)
+	if err != nil {
+		return false, err
+	}
+	if wallet.PlayerID == 0 {
+		return false, nil
+	}
+	player, err := w.PlayerService.Get(ctx, strconv.Itoa(wallet.PlayerID))
+	if err != nil {
+		return false, err
+	}
+	return player.ExcludedAt(time.Now()), nil
+}
+
+// refuseExcludedOwner is refuseExcluded for the player owning the wallet.
+// System wallets belong to no player and are never refused.
+func (w *WalletHandler) refuseExcludedOwner(ctx context.Context, c *gin.Context, walletId string) bool {
+	wallet, err := w.WalletService.Get(ctx, walletId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return true
+	}
+	if wallet.PlayerID == 0 {
+		return false
+	}
+	return w.refuseExcluded(ctx, c, wallet.PlayerID)
+}
+
 func (w *WalletHandler) CreditWallet(c *gin.Context) {
 	var input struct {
-		Amount string `json:"amount" `
+		Amount  string `json:"amount" `
+		Deposit bool   `json:"deposit"`
+		domain.TransactionDetails
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -65,27 +244,49 @@ func (w *WalletHandler) CreditWallet(c *gin.Context) {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid amount"})
 		return
 	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
 	var ctx = context.TODO()
-	err := w.WalletService.Credit(ctx, walletId, input.Amount)
+	credit := w.WalletService.Credit
+	if input.Deposit || input.Category == domain.CategoryDeposit {
+		if w.refuseExcluded(ctx, c, playerId) || w.refuseExcludedOwner(ctx, c, walletId) {
+			return
+		}
+		credit = w.WalletService.Deposit
+	}
+	transaction, err := credit(ctx, walletId, input.Amount, playerId, input.TransactionDetails)
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrRecordNotFound):
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
-		case errors.Is(err, domain.ErrInvalidAmount):
+		case errors.Is(err, domain.ErrDepositLimitExceeded),
+			errors.Is(err, domain.ErrInvalidCategory),
+			errors.Is(err, domain.ErrInvalidMetadata),
+			errors.Is(err, domain.ErrDetailsTooLong):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidAmount),
+			errors.Is(err, domain.ErrAmountPrecision):
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
+		case errors.Is(err, domain.ErrWalletFrozen),
+			errors.Is(err, domain.ErrWalletClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
 		default:
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "wallet credited"})
+	c.JSON(http.StatusOK, gin.H{"message": "wallet credited", "payload": transaction})
 }
 
 func (w *WalletHandler) DebitWallet(c *gin.Context) {
 	var input struct {
-		Amount string `json:"amount" validate:"required"`
+		Amount     string `json:"amount" validate:"required"`
+		Withdrawal bool   `json:"withdrawal"`
+		domain.TransactionDetails
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -96,25 +297,48 @@ func (w *WalletHandler) DebitWallet(c *gin.Context) {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid amount"})
 		return
 	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
 	var ctx = context.TODO()
-	err := w.WalletService.Debit(ctx, walletId, input.Amount)
+	// Self-excluded players can still withdraw their funds.
+	debit := w.WalletService.Withdraw
+	if !input.Withdrawal && input.Category != domain.CategoryWithdrawal {
+		if w.refuseExcluded(ctx, c, playerId) || w.refuseExcludedOwner(ctx, c, walletId) {
+			return
+		}
+		debit = w.WalletService.Debit
+	}
+	transaction, err := debit(ctx, walletId, input.Amount, playerId, input.TransactionDetails)
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrRecordNotFound):
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
+		case errors.Is(err, domain.ErrLossLimitExceeded),
+			errors.Is(err, domain.ErrWagerLimitExceeded),
+			errors.Is(err, domain.ErrInvalidCategory),
+			errors.Is(err, domain.ErrInvalidMetadata),
+			errors.Is(err, domain.ErrDetailsTooLong):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
 		case errors.Is(err, domain.ErrInsufficientFunds):
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
-		case errors.Is(err, domain.ErrInvalidAmount):
+		case errors.Is(err, domain.ErrInvalidAmount),
+			errors.Is(err, domain.ErrAmountPrecision):
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
+		case errors.Is(err, domain.ErrWalletFrozen),
+			errors.Is(err, domain.ErrPoolWallet),
+			errors.Is(err, domain.ErrWalletClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
 		default:
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "wallet debited"})
+	c.JSON(http.StatusOK, gin.H{"message": "wallet debited", "payload": transaction})
 }
 
 func (w *WalletHandler) GetWalletBalance(c *gin.Context) {
@@ -135,8 +359,716 @@ func (w *WalletHandler) GetWalletBalance(c *gin.Context) {
 			return
 		}
 	}
+	if at := c.Query("at"); at != "" {
+		// Historical balances are only shown to the wallet's owner.
+		id, _ := c.Get("playerId")
+		playerId, _ := id.(int)
+		if wallet.PlayerID != playerId {
+			c.JSON(http.StatusNotFound, gin.H{"error": domain.ErrRecordNotFound.Error()})
+			return
+		}
+		w.getWalletBalanceAt(c, wallet, at)
+		return
+	}
+	payload := map[string]interface{}{
+		"balance":           wallet.Balance,
+		"available_balance": wallet.AvailableBalance(),
+		"cash_balance":      wallet.CashBalance(),
+		"bonus_balance":     wallet.BonusBalance,
+		"credit_limit":      wallet.CreditLimit,
+		"used_credit":       wallet.UsedCredit(),
+		"currency":          wallet.Currency,
+	}
+	c.JSON(http.StatusOK, gin.H{"payload": payload})
+}
+
+// getWalletBalanceAt responds with the balance the wallet held at the RFC3339
+// moment given in the at query parameter.
+func (w *WalletHandler) getWalletBalanceAt(c *gin.Context, wallet domain.Wallet, at string) {
+	moment, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid at"})
+		return
+	}
+	var ctx = context.TODO()
+	balance, err := w.WalletService.BalanceAt(ctx, wallet.ID, moment)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrBalanceTimeInFuture):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	payload := map[string]interface{}{
+		"balance":  balance,
+		"currency": wallet.Currency,
+		"at":       moment,
+	}
+	c.JSON(http.StatusOK, gin.H{"payload": payload})
+}
+
+// parseTransactionFilter builds a domain.TransactionFilter from the query
+// string, returning a validation message for the first invalid parameter.
+func parseTransactionFilter(c *gin.Context) (domain.TransactionFilter, string) {
+	var filter domain.TransactionFilter
+	switch txType := domain.TransactionType(c.Query("type")); txType {
+	case "", domain.TransactionTypeCredit, domain.TransactionTypeDebit:
+		filter.Type = txType
+	default:
+		return filter, "invalid type"
+	}
+	for param, target := range map[string]**decimal.Decimal{
+		"min_amount": &filter.MinAmount,
+		"max_amount": &filter.MaxAmount,
+	} {
+		if value := c.Query(param); value != "" {
+			amount, err := decimal.NewFromString(value)
+			if err != nil {
+				return filter, "invalid " + param
+			}
+			*target = &amount
+		}
+	}
+	for param, target := range map[string]*time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	} {
+		if value := c.Query(param); value != "" {
+			date, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, "invalid " + param
+			}
+			*target = date
+		}
+	}
+	if category := domain.TransactionCategory(c.Query("category")); category != "" {
+		systemCategory := category == domain.CategoryJackpot || category == domain.CategoryOpeningBalance
+		if !systemCategory && !category.Valid(domain.TransactionTypeCredit) && !category.Valid(domain.TransactionTypeDebit) {
+			return filter, "invalid category"
+		}
+		filter.Category = category
+	}
+	filter.Reference = c.Query("reference")
+	// Metadata filters are passed as metadata[key]=value.
+	for key, value := range c.QueryMap("metadata") {
+		if !domain.ValidMetadataKey(key) {
+			return filter, "invalid metadata key"
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = domain.Metadata{}
+		}
+		filter.Metadata[key] = value
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		if !isValidInteger(cursor) {
+			return filter, "invalid cursor"
+		}
+		filter.Cursor, _ = strconv.Atoi(cursor)
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if !isValidInteger(limit) {
+			return filter, "invalid limit"
+		}
+		filter.Limit, _ = strconv.Atoi(limit)
+	}
+	return filter, ""
+}
+
+func (w *WalletHandler) ListWalletTransactions(c *gin.Context) {
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	filter, msg := parseTransactionFilter(c)
+	if msg != "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	if w.refuseForeign(ctx, c, playerId, walletId) {
+		return
+	}
+	transactions, nextCursor, err := w.WalletService.ListTransactions(ctx, walletId, filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
 	payload := map[string]interface{}{
-		"balance": wallet.Balance,
+		"transactions": transactions,
+		"next_cursor":  nil,
+	}
+	if nextCursor > 0 {
+		payload["next_cursor"] = strconv.Itoa(nextCursor)
 	}
 	c.JSON(http.StatusOK, gin.H{"payload": payload})
 }
+
+func (w *WalletHandler) CreateTransfer(c *gin.Context) {
+	var input struct {
+		FromWalletID string `json:"from_wallet_id"`
+		ToWalletID   string `json:"to_wallet_id"`
+		Amount       string `json:"amount"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !isValidInteger(input.FromWalletID) || !isValidInteger(input.ToWalletID) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	if w.refuseForeign(ctx, c, playerId, input.FromWalletID) || w.refuseExcluded(ctx, c, playerId) {
+		return
+	}
+	transfer, err := w.WalletService.Transfer(ctx, input.FromWalletID, input.ToWalletID, input.Amount, playerId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInsufficientFunds),
+			errors.Is(err, domain.ErrInvalidAmount),
+			errors.Is(err, domain.ErrAmountPrecision),
+			errors.Is(err, domain.ErrCurrencyMismatch),
+			errors.Is(err, domain.ErrSameWallet):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrRateNotFound):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrWalletFrozen),
+			errors.Is(err, domain.ErrPoolWallet),
+			errors.Is(err, domain.ErrWalletClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "transfer completed", "payload": transfer})
+}
+
+func (w *WalletHandler) CreateHold(c *gin.Context) {
+	var input struct {
+		Amount string `json:"amount"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	if w.refuseForeign(ctx, c, playerId, walletId) || w.refuseExcluded(ctx, c, playerId) {
+		return
+	}
+	hold, err := w.WalletService.Hold(ctx, walletId, input.Amount, playerId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInsufficientFunds),
+			errors.Is(err, domain.ErrInvalidAmount),
+			errors.Is(err, domain.ErrAmountPrecision):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrLossLimitExceeded),
+			errors.Is(err, domain.ErrWagerLimitExceeded):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrWalletFrozen),
+			errors.Is(err, domain.ErrPoolWallet),
+			errors.Is(err, domain.ErrWalletClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "funds held", "payload": hold})
+}
+
+func (w *WalletHandler) CaptureHold(c *gin.Context) {
+	// An empty body captures the whole hold.
+	var input struct {
+		Amount string `json:"amount"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	walletId, holdId := c.Param("wallet_id"), c.Param("hold_id")
+	if !isValidInteger(walletId) || !isValidInteger(holdId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid id parameter"})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	if w.refuseForeign(ctx, c, playerId, walletId) || w.refuseExcluded(ctx, c, playerId) {
+		return
+	}
+	transaction, err := w.WalletService.CaptureHold(ctx, walletId, holdId, input.Amount, playerId)
+	if err != nil {
+		respondHoldError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "hold captured", "payload": transaction})
+}
+
+func (w *WalletHandler) VoidHold(c *gin.Context) {
+	walletId, holdId := c.Param("wallet_id"), c.Param("hold_id")
+	if !isValidInteger(walletId) || !isValidInteger(holdId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid id parameter"})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	if w.refuseForeign(ctx, c, playerId, walletId) {
+		return
+	}
+	hold, err := w.WalletService.VoidHold(ctx, walletId, holdId)
+	if err != nil {
+		respondHoldError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "hold voided", "payload": hold})
+}
+
+func respondHoldError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrHoldNotActive),
+		errors.Is(err, domain.ErrHoldExpired):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrInvalidAmount),
+		errors.Is(err, domain.ErrAmountPrecision):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, domain.ErrWalletFrozen),
+		errors.Is(err, domain.ErrPoolWallet),
+		errors.Is(err, domain.ErrWalletClosed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+func (w *WalletHandler) ReverseTransaction(c *gin.Context) {
+	// An empty body reverses whatever has not been reversed yet.
+	var input struct {
+		Amount string `json:"amount"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	transactionId := c.Param("transaction_id")
+	if !isValidInteger(transactionId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid transaction id"})
+		return
+	}
+	var ctx = context.TODO()
+	transaction, err := w.WalletService.Reverse(ctx, transactionId, input.Amount, 0)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrAlreadyReversed),
+			errors.Is(err, domain.ErrTransactionNotReversible):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInsufficientFunds),
+			errors.Is(err, domain.ErrInvalidAmount),
+			errors.Is(err, domain.ErrAmountPrecision):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrWalletFrozen),
+			errors.Is(err, domain.ErrPoolWallet),
+			errors.Is(err, domain.ErrWalletClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "transaction reversed", "payload": transaction})
+}
+
+func (w *WalletHandler) CreditBonus(c *gin.Context) {
+	var input struct {
+		Amount             string `json:"amount"`
+		WageringMultiplier string `json:"wagering_multiplier"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	var ctx = context.TODO()
+	bonus, err := w.WalletService.CreditBonus(ctx, walletId, input.Amount, input.WageringMultiplier, 0)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidAmount),
+			errors.Is(err, domain.ErrAmountPrecision):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrWalletFrozen),
+			errors.Is(err, domain.ErrWalletClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "bonus credited", "payload": bonus})
+}
+
+func (w *WalletHandler) ListBonuses(c *gin.Context) {
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	var ctx = context.TODO()
+	bonuses, err := w.WalletService.ListBonuses(ctx, walletId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	type bonusProgress struct {
+		domain.Bonus
+		WageringProgress decimal.Decimal `json:"wagering_progress"`
+	}
+	payload := make([]bonusProgress, 0, len(bonuses))
+	for _, bonus := range bonuses {
+		payload = append(payload, bonusProgress{bonus, bonus.WageringProgress()})
+	}
+	c.JSON(http.StatusOK, gin.H{"payload": payload})
+}
+
+func (w *WalletHandler) SetLimit(c *gin.Context) {
+	// An empty amount removes the limit once the cooling period has passed.
+	var input struct {
+		Type   string `json:"type"`
+		Period string `json:"period"`
+		Amount string `json:"amount"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	if w.refuseForeign(ctx, c, playerId, walletId) {
+		return
+	}
+	limit, err := w.WalletService.SetLimit(ctx, walletId, domain.LimitType(input.Type), domain.LimitPeriod(input.Period), input.Amount)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidLimitType),
+			errors.Is(err, domain.ErrInvalidLimitPeriod):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidAmount),
+			errors.Is(err, domain.ErrAmountPrecision):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "limit set", "payload": limit})
+}
+
+func (w *WalletHandler) ListLimits(c *gin.Context) {
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	id, _ := c.Get("playerId")
+	playerId, _ := id.(int)
+	var ctx = context.TODO()
+	if w.refuseForeign(ctx, c, playerId, walletId) {
+		return
+	}
+	limits, err := w.WalletService.ListLimits(ctx, walletId)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"payload": limits})
+}
+
+func (w *WalletHandler) SetCreditLimit(c *gin.Context) {
+	var input struct {
+		CreditLimit string `json:"credit_limit"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	walletId := c.Param("wallet_id")
+	if !isValidInteger(walletId) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+		return
+	}
+	var ctx = context.TODO()
+	wallet, err := w.WalletService.SetCreditLimit(ctx, walletId, input.CreditLimit)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrInvalidAmount),
+			errors.Is(err, domain.ErrAmountPrecision):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, domain.ErrWalletClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "credit limit set", "payload": wallet})
+}
+
+// setWalletStatus returns the handler of an admin endpoint moving a wallet to
+// status. Every change needs a reason, which is kept on the wallet and in its
+// status history.
+func (w *WalletHandler) setWalletStatus(status domain.WalletStatus, message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input struct {
+			Reason string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		walletId := c.Param("wallet_id")
+		if !isValidInteger(walletId) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id"})
+			return
+		}
+		var ctx = context.TODO()
+		wallet, err := w.WalletService.SetStatus(ctx, walletId, status, input.Reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrRecordNotFound):
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			case errors.Is(err, domain.ErrStatusReasonRequired):
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			case errors.Is(err, domain.ErrInvalidStatusTransition),
+				errors.Is(err, domain.ErrWalletNotEmpty):
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": message, "payload": wallet})
+	}
+}
+
+// mergeExcluded spreads the results of the items that ran back to their
+// indexes in the submitted batch of size n, reporting every other item as
+// failed because its wallet's owner is excluded.
+func mergeExcluded(results []domain.BatchResult, indexes []int, n int) []domain.BatchResult {
+	merged := make([]domain.BatchResult, n)
+	for i := range merged {
+		merged[i] = domain.BatchResult{Index: i, Err: domain.ErrPlayerExcluded}
+	}
+	for i, result := range results {
+		result.Index = indexes[i]
+		merged[result.Index] = result
+	}
+	return merged
+}
+
+// batchErrors maps the errors a batch item can fail with to the HTTP status
+// and the code reported for it.
+var batchErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{domain.ErrRecordNotFound, http.StatusNotFound, "wallet_not_found"},
+	{domain.ErrInsufficientFunds, http.StatusBadRequest, "insufficient_funds"},
+	{domain.ErrInvalidAmount, http.StatusBadRequest, "invalid_amount"},
+	{domain.ErrAmountPrecision, http.StatusBadRequest, "invalid_amount"},
+	{domain.ErrInvalidBatchItem, http.StatusBadRequest, "invalid_type"},
+	{domain.ErrInvalidCategory, http.StatusUnprocessableEntity, "invalid_category"},
+	{domain.ErrInvalidMetadata, http.StatusUnprocessableEntity, "invalid_metadata"},
+	{domain.ErrDetailsTooLong, http.StatusUnprocessableEntity, "details_too_long"},
+	{domain.ErrDepositLimitExceeded, http.StatusUnprocessableEntity, "deposit_limit_exceeded"},
+	{domain.ErrLossLimitExceeded, http.StatusUnprocessableEntity, "loss_limit_exceeded"},
+	{domain.ErrWagerLimitExceeded, http.StatusUnprocessableEntity, "wager_limit_exceeded"},
+	{domain.ErrWalletFrozen, http.StatusForbidden, "wallet_frozen"},
+	{domain.ErrWalletClosed, http.StatusForbidden, "wallet_closed"},
+	{domain.ErrPoolWallet, http.StatusForbidden, "pool_wallet"},
+	{domain.ErrPlayerExcluded, http.StatusForbidden, "player_excluded"},
+	{domain.ErrEditConflict, http.StatusConflict, "edit_conflict"},
+}
+
+func batchError(err error) (int, string) {
+	for _, e := range batchErrors {
+		if errors.Is(err, e.err) {
+			return e.status, e.code
+		}
+	}
+	return http.StatusInternalServerError, "internal_error"
+}
+
+// BatchWallets applies up to domain.MaxBatchItems credits and debits in one
+// call, either atomically or reporting the outcome of every item.
+func (w *WalletHandler) BatchWallets(c *gin.Context) {
+	var input struct {
+		Mode  domain.BatchMode   `json:"mode"`
+		Items []domain.BatchItem `json:"items"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	for i, item := range input.Items {
+		if !isValidInteger(item.WalletID) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid wallet id", "index": i})
+			return
+		}
+	}
+	var ctx = context.TODO()
+	// Oversized batches are refused before any of their owners is looked up.
+	if len(input.Items) > domain.MaxBatchItems {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": domain.ErrBatchTooLarge.Error()})
+		return
+	}
+	// The owner of every wallet debited or deposited into must not be
+	// excluded. In best-effort mode only those items fail and the rest still
+	// run; any other batch is refused as a whole.
+	excluded := map[string]bool{}
+	var items []domain.BatchItem
+	var indexes []int
+	for i, item := range input.Items {
+		if item.Type == domain.TransactionTypeDebit || item.Category == domain.CategoryDeposit {
+			if _, ok := excluded[item.WalletID]; !ok {
+				ownerExcluded, err := w.ownerExcluded(ctx, item.WalletID)
+				// Unknown wallets are left to the batch, which reports them.
+				if err != nil && !errors.Is(err, domain.ErrRecordNotFound) {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				excluded[item.WalletID] = ownerExcluded
+			}
+			if excluded[item.WalletID] {
+				if input.Mode != domain.BatchModeBestEffort {
+					status, code := batchError(domain.ErrPlayerExcluded)
+					err := &domain.BatchItemError{Index: i, Err: domain.ErrPlayerExcluded}
+					c.JSON(status, gin.H{"error": err.Error(), "index": i, "code": code})
+					return
+				}
+				continue
+			}
+		}
+		items = append(items, item)
+		indexes = append(indexes, i)
+	}
+	var results []domain.BatchResult
+	var err error
+	if len(items) > 0 || len(input.Items) == 0 {
+		results, err = w.WalletService.Batch(ctx, items, input.Mode, 0)
+	}
+	if err == nil && len(items) < len(input.Items) {
+		results = mergeExcluded(results, indexes, len(input.Items))
+	}
+	if err != nil {
+		var itemErr *domain.BatchItemError
+		switch {
+		case errors.Is(err, domain.ErrEmptyBatch),
+			errors.Is(err, domain.ErrBatchTooLarge),
+			errors.Is(err, domain.ErrInvalidBatchMode):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.As(err, &itemErr):
+			status, code := batchError(itemErr.Err)
+			c.JSON(status, gin.H{"error": err.Error(), "index": itemErr.Index, "code": code})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	payload := make([]gin.H, len(results))
+	failed := 0
+	for i, result := range results {
+		if result.Err != nil {
+			_, code := batchError(result.Err)
+			payload[i] = gin.H{"index": result.Index, "status": "failed", "error": result.Err.Error(), "code": code}
+			failed++
+			continue
+		}
+		payload[i] = gin.H{"index": result.Index, "status": "ok", "transaction": result.Transaction}
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "batch processed", "failed": failed, "payload": payload})
+}
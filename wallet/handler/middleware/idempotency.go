@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"quik/domain"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// responseRecorder captures the response body so it can be replayed for
+// retries carrying the same idempotency key.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Idempotent makes a mutating endpoint safe to retry. The first request with a
+// given Idempotency-Key is executed and its response stored; replays with the
+// same key and body get the stored response, while a different body is
+// rejected. It must run after AuthPlayer so keys are scoped per player.
+func Idempotent(store domain.IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		playerId, _ := c.Get("playerId")
+		key = fmt.Sprintf("%v:%s", playerId, key)
+		hash := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.Path+"\n"), body...))
+		fingerprint := hex.EncodeToString(hash[:])
+
+		var ctx = context.TODO()
+		record := domain.IdempotencyRecord{Fingerprint: fingerprint, CreatedAt: time.Now()}
+		reserved, err := store.Reserve(ctx, key, &record)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if !reserved {
+			replay(c, store, key, fingerprint)
+			return
+		}
+
+		// A panicking handler is treated like a server error: the key is
+		// released before the panic is passed on.
+		defer func() {
+			if r := recover(); r != nil {
+				store.Delete(ctx, key)
+				panic(r)
+			}
+		}()
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors leave nothing committed, so the key is released to
+		// let the client retry.
+		if recorder.Status() >= http.StatusInternalServerError {
+			store.Delete(ctx, key)
+			return
+		}
+		record.StatusCode = recorder.Status()
+		record.Body = recorder.body.Bytes()
+		store.Save(ctx, key, &record)
+	}
+}
+
+func replay(c *gin.Context, store domain.IdempotencyStore, key, fingerprint string) {
+	record, err := store.Get(context.TODO(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrKeyNotFound):
+			c.JSON(http.StatusConflict, gin.H{"error": domain.ErrIdempotencyKeyInProgress.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		c.Abort()
+		return
+	}
+	switch {
+	case record.Fingerprint != fingerprint:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": domain.ErrIdempotencyKeyReused.Error()})
+	case record.StatusCode == 0:
+		c.JSON(http.StatusConflict, gin.H{"error": domain.ErrIdempotencyKeyInProgress.Error()})
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.StatusCode, "application/json; charset=utf-8", record.Body)
+	}
+	c.Abort()
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"quik/domain"
+	"quik/domain/mocks/inmemorydb"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newIdempotentRouter(store domain.IdempotencyStore, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/credit", func(c *gin.Context) {
+		c.Set("playerId", 1)
+	}, Idempotent(store), func(c *gin.Context) {
+		*calls++
+		c.JSON(http.StatusOK, gin.H{"message": "wallet credited"})
+	})
+	return router
+}
+
+func sendCredit(router *gin.Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/credit", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, "abc")
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res
+}
+
+func TestIdempotent(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("happy path: First request executes and stores the response", func(t *testing.T) {
+		store := &inmemorydb.IdempotencyStoreMock{}
+		calls := 0
+		store.On("Reserve", context.TODO(), "1:abc", mock.Anything).Return(true, nil).Once()
+		store.On("Save", context.TODO(), "1:abc", mock.MatchedBy(func(r *domain.IdempotencyRecord) bool {
+			return r.StatusCode == http.StatusOK && strings.Contains(string(r.Body), "wallet credited")
+		})).Return(nil).Once()
+		res := sendCredit(newIdempotentRouter(store, &calls), `{"amount":"10"}`)
+		as.Equal(http.StatusOK, res.Code)
+		as.Equal(1, calls)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Replay returns the original response", func(t *testing.T) {
+		store := &inmemorydb.IdempotencyStoreMock{}
+		calls := 0
+		router := newIdempotentRouter(store, &calls)
+		var fingerprint string
+		store.On("Reserve", context.TODO(), "1:abc", mock.Anything).Run(func(args mock.Arguments) {
+			fingerprint = args.Get(2).(*domain.IdempotencyRecord).Fingerprint
+		}).Return(false, nil).Once()
+		store.On("Get", context.TODO(), "1:abc").Return(domain.IdempotencyRecord{}, domain.ErrKeyNotFound).Once()
+		as.Equal(http.StatusConflict, sendCredit(router, `{"amount":"10"}`).Code)
+
+		store.On("Reserve", context.TODO(), "1:abc", mock.Anything).Return(false, nil).Once()
+		store.On("Get", context.TODO(), "1:abc").Return(domain.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			StatusCode:  http.StatusOK,
+			Body:        []byte(`{"message":"wallet credited"}`),
+		}, nil).Once()
+		res := sendCredit(router, `{"amount":"10"}`)
+		as.Equal(http.StatusOK, res.Code)
+		as.Equal(`{"message":"wallet credited"}`, res.Body.String())
+		as.Equal("true", res.Header().Get("Idempotent-Replayed"))
+		as.Zero(calls)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("input error: Reused key with a different body", func(t *testing.T) {
+		store := &inmemorydb.IdempotencyStoreMock{}
+		calls := 0
+		store.On("Reserve", context.TODO(), "1:abc", mock.Anything).Return(false, nil).Once()
+		store.On("Get", context.TODO(), "1:abc").Return(domain.IdempotencyRecord{
+			Fingerprint: "another request",
+			StatusCode:  http.StatusOK,
+		}, nil).Once()
+		res := sendCredit(newIdempotentRouter(store, &calls), `{"amount":"99"}`)
+		as.Equal(http.StatusUnprocessableEntity, res.Code)
+		as.Zero(calls)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("system error: Key is released when the handler fails", func(t *testing.T) {
+		store := &inmemorydb.IdempotencyStoreMock{}
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/credit", func(c *gin.Context) {
+			c.Set("playerId", 1)
+		}, Idempotent(store), func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "something failed"})
+		})
+		store.On("Reserve", context.TODO(), "1:abc", mock.Anything).Return(true, nil).Once()
+		store.On("Delete", context.TODO(), "1:abc").Return(nil).Once()
+		as.Equal(http.StatusInternalServerError, sendCredit(router, `{"amount":"10"}`).Code)
+		store.AssertExpectations(t)
+	})
+
+	t.Run("system error: Key is released when the handler panics", func(t *testing.T) {
+		store := &inmemorydb.IdempotencyStoreMock{}
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(gin.Recovery())
+		router.POST("/credit", func(c *gin.Context) {
+			c.Set("playerId", 1)
+		}, Idempotent(store), func(c *gin.Context) {
+			panic("something failed")
+		})
+		store.On("Reserve", context.TODO(), "1:abc", mock.Anything).Return(true, nil).Once()
+		store.On("Delete", context.TODO(), "1:abc").Return(nil).Once()
+		as.Equal(http.StatusInternalServerError, sendCredit(router, `{"amount":"10"}`).Code)
+		store.AssertExpectations(t)
+		store.AssertNotCalled(t, "Save", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"os"
 	"quik/internal/encryption"
@@ -38,3 +39,45 @@ func AuthPlayer() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+const AdminKeyHeader = "X-Admin-Key"
+
+// AuthAdmin protects back-office endpoints with the shared ADMIN_API_KEY.
+// Requests are refused outright while no key is configured.
+func AuthAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminKey := os.Getenv("ADMIN_API_KEY")
+		clientKey := c.GetHeader(AdminKeyHeader)
+		if adminKey == "" || subtle.ConstantTimeCompare([]byte(clientKey), []byte(adminKey)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid admin key"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+const ProviderKeyHeader = "X-Provider-Key"
+
+// AuthProvider protects the game provider endpoints. PROVIDER_API_KEYS holds
+// comma-separated provider:key pairs; the provider whose key matches is set
+// on the context. Requests are refused outright while no key is configured.
+func AuthProvider() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientKey := c.GetHeader(ProviderKeyHeader)
+		for _, pair := range strings.Split(os.Getenv("PROVIDER_API_KEYS"), ",") {
+			parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				continue
+			}
+			provider, key := parts[0], parts[1]
+			if subtle.ConstantTimeCompare([]byte(clientKey), []byte(key)) == 1 {
+				c.Set("provider", provider)
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid provider key"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func sendProviderRequest(key string) (*httptest.ResponseRecorder, string) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var provider string
+	router.POST("/rounds/win", AuthProvider(), func(c *gin.Context) {
+		provider = c.GetString("provider")
+		c.JSON(http.StatusOK, gin.H{"message": "win settled"})
+	})
+	req := httptest.NewRequest(http.MethodPost, "/rounds/win", nil)
+	if key != "" {
+		req.Header.Set(ProviderKeyHeader, key)
+	}
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, req)
+	return res, provider
+}
+
+func TestAuthProvider(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("happy path: A provider's key authenticates it", func(t *testing.T) {
+		t.Setenv("PROVIDER_API_KEYS", "acme:acmekey, globex:globexkey")
+		res, provider := sendProviderRequest("globexkey")
+		as.Equal(http.StatusOK, res.Code)
+		as.Equal("globex", provider)
+	})
+
+	t.Run("input error: An unknown or missing key is refused", func(t *testing.T) {
+		t.Setenv("PROVIDER_API_KEYS", "acme:acmekey")
+		res, _ := sendProviderRequest("otherkey")
+		as.Equal(http.StatusUnauthorized, res.Code)
+		res, _ = sendProviderRequest("")
+		as.Equal(http.StatusUnauthorized, res.Code)
+	})
+
+	t.Run("input error: Every key is refused while none is configured", func(t *testing.T) {
+		t.Setenv("PROVIDER_API_KEYS", "acme:")
+		res, _ := sendProviderRequest("")
+		as.Equal(http.StatusUnauthorized, res.Code)
+	})
+}
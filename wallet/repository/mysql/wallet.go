@@ -4,13 +4,18 @@ import (
 	"context"
 	"errors"
 	"quik/domain"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
-	ErrRecordNotFound = errors.New("record not found")
-	ErrEditConflict   = errors.New("edit conflict")
+	ErrRecordNotFound = domain.ErrRecordNotFound
+	ErrEditConflict   = domain.ErrEditConflict
 )
 
 type mysqlWalletRepository struct {
@@ -26,6 +31,57 @@ func (w *mysqlWalletRepository) Create(ctx context.Context, wallet *domain.Walle
 	return err
 }
 
+func (w *mysqlWalletRepository) CreateForPlayer(ctx context.Context, wallet *domain.Wallet, maxWallets int) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Locking the player serialises the wallet creations of a player, so
+		// neither the cap nor the single default can be raced past.
+		if err := lockPlayer(tx, wallet.PlayerID); err != nil {
+			return err
+		}
+		var held int64
+		if err := tx.Model(&domain.Wallet{}).Where("player_id = ?", wallet.PlayerID).Count(&held).Error; err != nil {
+			return err
+		}
+		if maxWallets > 0 && held >= int64(maxWallets) {
+			return domain.ErrWalletLimitReached
+		}
+		// A player left without a default, their default having closed,
+		// gets the new wallet as default too.
+		var defaults int64
+		if err := tx.Model(&domain.Wallet{}).Where("player_id = ? AND is_default", wallet.PlayerID).Count(&defaults).Error; err != nil {
+			return err
+		}
+		if defaults == 0 {
+			wallet.IsDefault = true
+		}
+		if wallet.IsDefault {
+			err := tx.Model(&domain.Wallet{}).Where("player_id = ? AND is_default", wallet.PlayerID).
+				Update("is_default", false).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Create(wallet).Error
+	})
+}
+
+// lockPlayer locks the player's row until the end of tx. Every change to
+// which of a player's wallets is their default holds it, as do movements
+// checked against the player's limits, before locking any wallet.
+func lockPlayer(tx *gorm.DB, playerID int) error {
+	var player domain.Player
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", playerID).First(&player).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
 func (w *mysqlWalletRepository) Get(ctx context.Context, id string) (domain.Wallet, error) {
 	var wallet domain.Wallet
 	err := w.db.WithContext(ctx).Where("id = ?", id).First(&wallet).Error
@@ -40,12 +96,574 @@ func (w *mysqlWalletRepository) Get(ctx context.Context, id string) (domain.Wall
 	return wallet, nil
 }
 
-func (w *mysqlWalletRepository) Credit(ctx context.Context, wallet *domain.Wallet) error {
-	err := w.db.WithContext(ctx).Save(wallet).Error
+func (w *mysqlWalletRepository) ListByPlayer(ctx context.Context, playerID int) ([]domain.Wallet, error) {
+	var wallets []domain.Wallet
+	err := w.db.WithContext(ctx).Where("player_id = ?", playerID).Order("id").Find(&wallets).Error
+	return wallets, err
+}
+
+func (w *mysqlWalletRepository) SetDefault(ctx context.Context, wallet *domain.Wallet) error {
+	err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockPlayer(tx, wallet.PlayerID); err != nil {
+			return err
+		}
+		// Closing a wallet takes the same lock, so the status read here
+		// cannot change before the default is set.
+		var current domain.Wallet
+		if err := tx.Where("id = ?", wallet.ID).First(&current).Error; err != nil {
+			return err
+		}
+		if current.Status == domain.WalletStatusClosed {
+			return domain.ErrWalletClosed
+		}
+		return tx.Model(&domain.Wallet{}).Where("player_id = ?", wallet.PlayerID).
+			Update("is_default", gorm.Expr("id = ?", wallet.ID)).Error
+	})
+	if err != nil {
+		return err
+	}
+	wallet.IsDefault = true
+	return nil
+}
+
+func (w *mysqlWalletRepository) SetLabel(ctx context.Context, wallet *domain.Wallet) error {
+	return w.db.WithContext(ctx).Model(&domain.Wallet{}).Where("id = ?", wallet.ID).
+		Update("label", wallet.Label).Error
+}
+
+func (w *mysqlWalletRepository) Credit(ctx context.Context, wallet *domain.Wallet, transaction *domain.Transaction) error {
+	return w.saveWithTransaction(ctx, wallet, transaction)
+}
+
+func (w *mysqlWalletRepository) Debit(ctx context.Context, wallet *domain.Wallet, transaction *domain.Transaction) error {
+	return w.saveWithTransaction(ctx, wallet, transaction)
+}
+
+// saveWithTransaction persists the new wallet balance and appends its ledger
+// entry atomically, so a balance never changes without a matching record.
+func (w *mysqlWalletRepository) saveWithTransaction(ctx context.Context, wallet *domain.Wallet, transaction *domain.Transaction) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := updateWallet(tx, wallet); err != nil {
+			return err
+		}
+		return appendTransaction(tx, wallet, transaction)
+	})
+}
+
+// appendTransaction inserts a ledger entry together with its balanced
+// journal lines.
+func appendTransaction(tx *gorm.DB, wallet *domain.Wallet, transaction *domain.Transaction) error {
+	if err := tx.Create(transaction).Error; err != nil {
+		return err
+	}
+	lines := domain.JournalLines(*transaction, wallet.Currency)
+	return tx.Create(&lines).Error
+}
+
+// updateWallet writes the wallet balances, status and credit limit only if
+// the wallet still has the version it was read with; otherwise
+// ErrEditConflict is returned and nothing is written.
+func updateWallet(tx *gorm.DB, wallet *domain.Wallet) error {
+	now := time.Now()
+	result := tx.Model(&domain.Wallet{}).
+		Where("id = ? AND version = ?", wallet.ID, wallet.Version).
+		Updates(map[string]interface{}{
+			"balance":       wallet.Balance,
+			"held_balance":  wallet.HeldBalance,
+			"bonus_balance": wallet.BonusBalance,
+			"credit_limit":  wallet.CreditLimit,
+			"status":        wallet.Status,
+			"status_reason": wallet.StatusReason,
+			"version":       wallet.Version + 1,
+			"updated_at":    now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEditConflict
+	}
+	wallet.Version++
+	wallet.UpdatedAt = now
+	return nil
+}
+
+func (w *mysqlWalletRepository) ListTransactions(ctx context.Context, id string, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	query := w.db.WithContext(ctx).Where("wallet_id = ?", id)
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("amount <= ?", *filter.MaxAmount)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at > ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at <= ?", filter.To)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.Reference != "" {
+		query = query.Where("reference = ?", filter.Reference)
+	}
+	for key, value := range filter.Metadata {
+		// Keys are restricted to a safe character set, so quoting them
+		// into the JSON path is enough.
+		query = query.Where("JSON_UNQUOTE(JSON_EXTRACT(metadata, ?)) = ?", `$."`+key+`"`, value)
+	}
+	if filter.Cursor > 0 {
+		query = query.Where("id < ?", filter.Cursor)
+	}
+	err := query.Order("id desc").Limit(filter.Limit).Find(&transactions).Error
+	return transactions, err
+}
+
+func (w *mysqlWalletRepository) CreateTransfer(ctx context.Context, transfer *domain.Transfer) error {
+	err := w.db.WithContext(ctx).Create(transfer).Error
 	return err
 }
 
-func (w *mysqlWalletRepository) Debit(ctx context.Context, wallet *domain.Wallet) error {
-	err := w.db.WithContext(ctx).Save(wallet).Error
+func (w *mysqlWalletRepository) Transaction(ctx context.Context, fn func(r domain.WalletRepository) error) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&mysqlWalletRepository{db: tx})
+	})
+}
+
+func (w *mysqlWalletRepository) ListLimits(ctx context.Context, playerID int, currency string) ([]domain.Limit, error) {
+	var limits []domain.Limit
+	err := w.db.WithContext(ctx).Where("player_id = ? AND currency = ?", playerID, currency).Order("id").Find(&limits).Error
+	return limits, err
+}
+
+func (w *mysqlWalletRepository) SaveLimit(ctx context.Context, limit *domain.Limit) error {
+	if limit.ID != 0 {
+		return w.db.WithContext(ctx).Save(limit).Error
+	}
+	result := w.db.WithContext(ctx).Clauses(clause.Insert{Modifier: "IGNORE"}).Create(limit)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrDuplicateRecord
+	}
+	return nil
+}
+
+func (w *mysqlWalletRepository) SumActivity(ctx context.Context, playerID int, currency string, since time.Time) (domain.WalletActivity, error) {
+	wallets := w.db.WithContext(ctx).Model(&domain.Wallet{}).Select("id").Where("player_id = ? AND currency = ?", playerID, currency)
+	var deposited, wagered, won, held decimal.NullDecimal
+	err := w.db.WithContext(ctx).Model(&domain.Transaction{}).
+		Select(`SUM(CASE WHEN type = @credit AND deposit THEN amount END),
+			SUM(CASE WHEN type = @debit AND NOT withdrawal AND transfer_id IS NULL AND reversal_of_id IS NULL THEN amount END),
+			SUM(CASE WHEN type = @credit AND NOT deposit AND transfer_id IS NULL AND bonus_id IS NULL AND NOT (category <=> @opening) AND NOT (category <=> @bonus) AND NOT (category <=> @promotion) THEN amount END)`,
+			map[string]interface{}{"credit": domain.TransactionTypeCredit, "debit": domain.TransactionTypeDebit, "opening": domain.CategoryOpeningBalance, "bonus": domain.CategoryBonus, "promotion": domain.CategoryPromotion}).
+		Where("wallet_id IN (?) AND created_at > ?", wallets, since).
+		Row().Scan(&deposited, &wagered, &won)
+	if err != nil {
+		return domain.WalletActivity{}, err
+	}
+	// Stakes reserved by holds count as soon as they are held, so that holds
+	// cannot be used to go over a limit before they are captured.
+	err = w.db.WithContext(ctx).Model(&domain.Hold{}).
+		Select("SUM(amount)").
+		Where("wallet_id IN (?) AND status = ?", wallets, domain.HoldStatusActive).
+		Row().Scan(&held)
+	return domain.WalletActivity{Deposited: deposited.Decimal, Wagered: wagered.Decimal.Add(held.Decimal), Won: won.Decimal}, err
+}
+
+func (w *mysqlWalletRepository) Update(ctx context.Context, wallet *domain.Wallet) error {
+	return updateWallet(w.db.WithContext(ctx), wallet)
+}
+
+func (w *mysqlWalletRepository) UpdateStatus(ctx context.Context, wallet *domain.Wallet, change *domain.WalletStatusChange) error {
+	closing := wallet.Status == domain.WalletStatusClosed && wallet.PlayerID != 0
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if closing {
+			if err := lockPlayer(tx, wallet.PlayerID); err != nil {
+				return err
+			}
+		}
+		if err := updateWallet(tx, wallet); err != nil {
+			return err
+		}
+		if err := tx.Create(change).Error; err != nil {
+			return err
+		}
+		if !closing {
+			return nil
+		}
+		// The default moves to the player's oldest wallet still open, if
+		// any. The flag is read again under the lock, as SetDefault may
+		// have moved it since the wallet was loaded.
+		result := tx.Model(&domain.Wallet{}).Where("id = ? AND is_default", wallet.ID).Update("is_default", false)
+		if result.Error != nil {
+			return result.Error
+		}
+		wallet.IsDefault = false
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		var next domain.Wallet
+		err := tx.Where("player_id = ? AND status <> ?", wallet.PlayerID, domain.WalletStatusClosed).Order("id").First(&next).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil
+		case err != nil:
+			return err
+		}
+		return tx.Model(&domain.Wallet{}).Where("id = ?", next.ID).Update("is_default", true).Error
+	})
+}
+
+func (w *mysqlWalletRepository) ListWallets(ctx context.Context, afterID, limit int) ([]domain.Wallet, error) {
+	var wallets []domain.Wallet
+	err := w.db.WithContext(ctx).Where("id > ?", afterID).Order("id").Limit(limit).Find(&wallets).Error
+	return wallets, err
+}
+
+func (w *mysqlWalletRepository) LedgerBalances(ctx context.Context, walletIDs []int) (map[int]decimal.Decimal, error) {
+	var sums []struct {
+		WalletID int
+		Balance  decimal.Decimal
+	}
+	err := w.db.WithContext(ctx).Model(&domain.Transaction{}).
+		Select("wallet_id, SUM(CASE WHEN type = ? THEN amount ELSE -amount END) AS balance", domain.TransactionTypeCredit).
+		Where("wallet_id IN ?", walletIDs).
+		Group("wallet_id").
+		Scan(&sums).Error
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[int]decimal.Decimal, len(sums))
+	for _, sum := range sums {
+		balances[sum.WalletID] = sum.Balance
+	}
+	return balances, nil
+}
+
+func (w *mysqlWalletRepository) LatestSnapshot(ctx context.Context, walletID int, at time.Time) (domain.BalanceSnapshot, error) {
+	var snapshot domain.BalanceSnapshot
+	err := w.db.WithContext(ctx).
+		Where("wallet_id = ? AND as_of <= ?", walletID, at).
+		Order("as_of DESC").First(&snapshot).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return domain.BalanceSnapshot{}, ErrRecordNotFound
+		default:
+			return domain.BalanceSnapshot{}, err
+		}
+	}
+	return snapshot, nil
+}
+
+func (w *mysqlWalletRepository) CreateSnapshot(ctx context.Context, snapshot *domain.BalanceSnapshot) error {
+	return w.db.WithContext(ctx).Create(snapshot).Error
+}
+
+func (w *mysqlWalletRepository) SumTransactions(ctx context.Context, walletID int, from, to time.Time) (decimal.Decimal, int, error) {
+	var sum struct {
+		Balance decimal.NullDecimal
+		Count   int
+	}
+	row := w.db.WithContext(ctx).Model(&domain.Transaction{}).
+		Select("SUM(CASE WHEN type = ? THEN amount ELSE -amount END), COUNT(*)", domain.TransactionTypeCredit).
+		Where("wallet_id = ? AND created_at > ? AND created_at <= ?", walletID, from, to).
+		Row()
+	if err := row.Scan(&sum.Balance, &sum.Count); err != nil {
+		return decimal.Zero, 0, err
+	}
+	return sum.Balance.Decimal, sum.Count, nil
+}
+
+func (w *mysqlWalletRepository) StreamTransactions(ctx context.Context, walletID int, from, to time.Time, fn func(t domain.Transaction) error) error {
+	rows, err := w.db.WithContext(ctx).Model(&domain.Transaction{}).
+		Where("wallet_id = ? AND created_at > ? AND created_at <= ?", walletID, from, to).
+		Order("created_at, id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var transaction domain.Transaction
+		if err := w.db.ScanRows(rows, &transaction); err != nil {
+			return err
+		}
+		if err := fn(transaction); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (w *mysqlWalletRepository) BookOpeningBalance(ctx context.Context, walletID int) (*domain.Transaction, error) {
+	var opening *domain.Transaction
+	err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The lock keeps movements out until the entry is in.
+		var wallet domain.Wallet
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", walletID).First(&wallet).Error
+		if err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		var opened int64
+		err = tx.Model(&domain.Transaction{}).
+			Where("wallet_id = ? AND category = ?", walletID, domain.CategoryOpeningBalance).
+			Count(&opened).Error
+		if err != nil || opened > 0 {
+			return err
+		}
+		var ledger decimal.NullDecimal
+		err = tx.Model(&domain.Transaction{}).
+			Select("SUM(CASE WHEN type = ? THEN amount ELSE -amount END)", domain.TransactionTypeCredit).
+			Where("wallet_id = ?", walletID).
+			Row().Scan(&ledger)
+		if err != nil {
+			return err
+		}
+		gap := wallet.Balance.Sub(ledger.Decimal)
+		if gap.IsZero() {
+			return nil
+		}
+		opening = &domain.Transaction{
+			WalletID:      wallet.ID,
+			Type:          domain.TransactionTypeCredit,
+			Amount:        gap.Abs(),
+			BalanceBefore: decimal.Zero,
+			BalanceAfter:  gap,
+			Category:      domain.CategoryOpeningBalance,
+			Description:   "Opening balance",
+			CreatedAt:     wallet.CreatedAt,
+		}
+		if gap.IsNegative() {
+			opening.Type = domain.TransactionTypeDebit
+		}
+		if err := appendTransaction(tx, &wallet, opening); err != nil {
+			return err
+		}
+		return tx.Where("wallet_id = ?", walletID).Delete(&domain.BalanceSnapshot{}).Error
+	})
+	return opening, err
+}
+
+func (w *mysqlWalletRepository) JournalMissing(ctx context.Context, limit int) (int, error) {
+	journaled := 0
+	err := w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var transactions []domain.Transaction
+		err := tx.Where("NOT EXISTS (SELECT 1 FROM journal_lines WHERE journal_lines.transaction_id = wallet_transactions.id)").
+			Order("id").Limit(limit).Find(&transactions).Error
+		if err != nil || len(transactions) == 0 {
+			return err
+		}
+		walletIDs := make([]int, len(transactions))
+		for i, transaction := range transactions {
+			walletIDs[i] = transaction.WalletID
+		}
+		var wallets []domain.Wallet
+		if err := tx.Select("id, currency").Where("id IN ?", walletIDs).Find(&wallets).Error; err != nil {
+			return err
+		}
+		currencies := make(map[int]string, len(wallets))
+		for _, wallet := range wallets {
+			currencies[wallet.ID] = wallet.Currency
+		}
+		var lines []domain.JournalLine
+		for _, transaction := range transactions {
+			lines = append(lines, domain.JournalLines(transaction, currencies[transaction.WalletID])...)
+		}
+		journaled = len(transactions)
+		return tx.Create(&lines).Error
+	})
+	return journaled, err
+}
+
+func (w *mysqlWalletRepository) Contribute(ctx context.Context, transaction *domain.Transaction) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		delta := transaction.Amount
+		if transaction.Type == domain.TransactionTypeDebit {
+			var wallet domain.Wallet
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", transaction.WalletID).First(&wallet).Error
+			if err != nil {
+				return err
+			}
+			transaction.Amount = decimal.Min(transaction.Amount, decimal.Max(wallet.Balance, decimal.Zero))
+			if !transaction.Amount.IsPositive() {
+				transaction.Amount = decimal.Zero
+				return nil
+			}
+			delta = transaction.Amount.Neg()
+		}
+		// The increment locks the row, so the balance read back is the one
+		// this contribution produced.
+		err := tx.Model(&domain.Wallet{}).Where("id = ?", transaction.WalletID).
+			Updates(map[string]interface{}{
+				"balance":    gorm.Expr("balance + ?", delta),
+				"version":    gorm.Expr("version + 1"),
+				"updated_at": time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+		var wallet domain.Wallet
+		if err := tx.Where("id = ?", transaction.WalletID).First(&wallet).Error; err != nil {
+			return err
+		}
+		transaction.BalanceAfter = wallet.Balance
+		transaction.BalanceBefore = wallet.Balance.Sub(delta)
+		return appendTransaction(tx, &wallet, transaction)
+	})
+}
+
+func (w *mysqlWalletRepository) ListContributions(ctx context.Context, debitID int) ([]domain.Transaction, error) {
+	var transactions []domain.Transaction
+	err := w.db.WithContext(ctx).
+		Where("reference = ? AND category = ?", strconv.Itoa(debitID), domain.CategoryJackpot).
+		Order("id").Find(&transactions).Error
+	return transactions, err
+}
+
+func (w *mysqlWalletRepository) GetHold(ctx context.Context, id string) (domain.Hold, error) {
+	var hold domain.Hold
+	err := w.db.WithContext(ctx).Where("id = ?", id).First(&hold).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return domain.Hold{}, ErrRecordNotFound
+		default:
+			return domain.Hold{}, err
+		}
+	}
+	return hold, nil
+}
+
+func (w *mysqlWalletRepository) ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]domain.Hold, error) {
+	var holds []domain.Hold
+	err := w.db.WithContext(ctx).
+		Where("status = ? AND expires_at <= ?", domain.HoldStatusActive, now).
+		Order("expires_at").Limit(limit).Find(&holds).Error
+	return holds, err
+}
+
+func (w *mysqlWalletRepository) LockWallets(ctx context.Context, ids []int) error {
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+	// Limit checks lock the player before the wallet, so the owners are
+	// locked first here too.
+	owners := w.db.WithContext(ctx).Model(&domain.Wallet{}).Select("player_id").Where("id IN ?", sorted)
+	var players []domain.Player
+	err := w.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN (?)", owners).Order("id").Find(&players).Error
+	if err != nil {
+		return err
+	}
+	var wallets []domain.Wallet
+	return w.db.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", sorted).Order("id").Find(&wallets).Error
+}
+
+func (w *mysqlWalletRepository) LockPlayer(ctx context.Context, playerID int) error {
+	return lockPlayer(w.db.WithContext(ctx), playerID)
+}
+
+func (w *mysqlWalletRepository) CountActiveHolds(ctx context.Context, walletID int) (int, error) {
+	var count int64
+	err := w.db.WithContext(ctx).Model(&domain.Hold{}).
+		Where("wallet_id = ? AND status = ?", walletID, domain.HoldStatusActive).
+		Count(&count).Error
+	return int(count), err
+}
+
+func (w *mysqlWalletRepository) CreateHold(ctx context.Context, wallet *domain.Wallet, hold *domain.Hold) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := updateWallet(tx, wallet); err != nil {
+			return err
+		}
+		return tx.Create(hold).Error
+	})
+}
+
+func (w *mysqlWalletRepository) SettleHold(ctx context.Context, wallet *domain.Wallet, hold *domain.Hold, transaction *domain.Transaction) error {
+	return w.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Hold{}).
+			Where("id = ? AND status = ?", hold.ID, domain.HoldStatusActive).
+			Updates(map[string]interface{}{
+				"status":          hold.Status,
+				"captured_amount": hold.CapturedAmount,
+				"updated_at":      time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return domain.ErrHoldNotActive
+		}
+		if err := updateWallet(tx, wallet); err != nil {
+			return err
+		}
+		if transaction == nil {
+			return nil
+		}
+		return appendTransaction(tx, wallet, transaction)
+	})
+}
+
+func (w *mysqlWalletRepository) GetTransaction(ctx context.Context, id string) (domain.Transaction, error) {
+	var transaction domain.Transaction
+	err := w.db.WithContext(ctx).Where("id = ?", id).First(&transaction).Error
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return domain.Transaction{}, ErrRecordNotFound
+		default:
+			return domain.Transaction{}, err
+		}
+	}
+	return transaction, nil
+}
+
+func (w *mysqlWalletRepository) ReversedAmount(ctx context.Context, transactionID int) (decimal.Decimal, error) {
+	var total decimal.NullDecimal
+	err := w.db.WithContext(ctx).Model(&domain.Transaction{}).
+		Where("reversal_of_id = ?", transactionID).
+		Select("SUM(amount)").Row().Scan(&total)
+	return total.Decimal, err
+}
+
+// ListBonuses returns the wallet's bonuses oldest first, optionally limited
+// to one status.
+func (w *mysqlWalletRepository) ListBonuses(ctx context.Context, walletID int, status domain.BonusStatus) ([]domain.Bonus, error) {
+	var bonuses []domain.Bonus
+	query := w.db.WithContext(ctx).Where("wallet_id = ?", walletID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id").Find(&bonuses).Error
+	return bonuses, err
+}
+
+func (w *mysqlWalletRepository) SaveBonus(ctx context.Context, bonus *domain.Bonus) error {
+	err := w.db.WithContext(ctx).Save(bonus).Error
 	return err
 }
+
+func (w *mysqlWalletRepository) CreateJackpot(ctx context.Context, pool *domain.JackpotPool) error {
+	result := w.db.WithContext(ctx).Clauses(clause.Insert{Modifier: "IGNORE"}).Create(pool)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrJackpotExists
+	}
+	return nil
+}
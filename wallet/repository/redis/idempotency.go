@@ -0,0 +1,64 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"quik/domain"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const idempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyInFlightTTL bounds how long a reserved key stays in progress, so
+// that a request that never finishes, for example because the process
+// crashed, does not block retries for the whole idempotencyKeyTTL. Save
+// extends the key to idempotencyKeyTTL once the response is known.
+const idempotencyInFlightTTL = 5 * time.Minute
+
+type redisIdempotencyStore struct {
+	db *redis.Client
+}
+
+func NewRedisIdempotencyStore(redisClient *redis.Client) domain.IdempotencyStore {
+	return &redisIdempotencyStore{redisClient}
+}
+
+func idempotencyKey(key string) string {
+	return "idempotency:" + key
+}
+
+func (r *redisIdempotencyStore) Reserve(ctx context.Context, key string, record *domain.IdempotencyRecord) (bool, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	return r.db.SetNX(ctx, idempotencyKey(key), string(data), idempotencyInFlightTTL).Result()
+}
+
+func (r *redisIdempotencyStore) Get(ctx context.Context, key string) (domain.IdempotencyRecord, error) {
+	var record domain.IdempotencyRecord
+	val, err := r.db.Get(ctx, idempotencyKey(key)).Result()
+	if err == redis.Nil {
+		return domain.IdempotencyRecord{}, domain.ErrKeyNotFound
+	} else if err != nil {
+		return domain.IdempotencyRecord{}, err
+	}
+	err = json.Unmarshal([]byte(val), &record)
+	return record, err
+}
+
+func (r *redisIdempotencyStore) Save(ctx context.Context, key string, record *domain.IdempotencyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	err = r.db.Set(ctx, idempotencyKey(key), string(data), idempotencyKeyTTL).Err()
+	return err
+}
+
+func (r *redisIdempotencyStore) Delete(ctx context.Context, key string) error {
+	err := r.db.Del(ctx, idempotencyKey(key)).Err()
+	return err
+}
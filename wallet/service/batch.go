@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+	"sort"
+	"strconv"
+)
+
+// Batch applies a list of credit and debit instructions. In all-or-nothing
+// mode they share one database transaction and the first failing item, as a
+// *domain.BatchItemError, aborts the whole batch. In best-effort mode every
+// item stands alone and its failure is only reported in its result.
+func (w *walletService) Batch(ctx context.Context, items []domain.BatchItem, mode domain.BatchMode, actorID int) ([]domain.BatchResult, error) {
+	switch {
+	case len(items) == 0:
+		return nil, domain.ErrEmptyBatch
+	case len(items) > domain.MaxBatchItems:
+		return nil, domain.ErrBatchTooLarge
+	case !mode.Valid():
+		return nil, domain.ErrInvalidBatchMode
+	}
+	if mode == domain.BatchModeBestEffort {
+		return w.batchBestEffort(ctx, items, actorID), nil
+	}
+	return w.batchAllOrNothing(ctx, items, actorID)
+}
+
+func (w *walletService) batchBestEffort(ctx context.Context, items []domain.BatchItem, actorID int) []domain.BatchResult {
+	results := make([]domain.BatchResult, len(items))
+	for i, item := range items {
+		results[i].Index = i
+		var transaction domain.Transaction
+		err := validateBatchItem(item)
+		switch {
+		case err != nil:
+		case item.Type == domain.TransactionTypeCredit:
+			transaction, err = w.Credit(ctx, item.WalletID, item.Amount, actorID, item.TransactionDetails)
+		default:
+			transaction, err = w.Debit(ctx, item.WalletID, item.Amount, actorID, item.TransactionDetails)
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Transaction = &transaction
+	}
+	return results
+}
+
+func (w *walletService) batchAllOrNothing(ctx context.Context, items []domain.BatchItem, actorID int) ([]domain.BatchResult, error) {
+	var results []domain.BatchResult
+	var touched map[int]bool
+	// The batch locks its wallets in ID order before writing any of them, so
+	// overlapping batches wait for each other instead of deadlocking. A
+	// wallet written by another request before the lock still rolls the
+	// whole batch back, so it is retried from the start rather than item by
+	// item.
+	err := retryOnConflict(func() error {
+		results = make([]domain.BatchResult, len(items))
+		touched = make(map[int]bool)
+		return w.walletRepository.Transaction(ctx, func(r domain.WalletRepository) error {
+			if err := r.LockWallets(ctx, batchWalletIDs(items)); err != nil {
+				return err
+			}
+			bound := w.withRepository(r)
+			for i, item := range items {
+				transaction, err := bound.applyBatchItem(ctx, item, actorID)
+				if err != nil {
+					return &domain.BatchItemError{Index: i, Err: err}
+				}
+				results[i] = domain.BatchResult{Index: i, Transaction: &transaction}
+				touched[transaction.WalletID] = true
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for walletID := range touched {
+		w.walletInMemoryDB.Delete(ctx, strconv.Itoa(walletID))
+	}
+	return results, nil
+}
+
+// batchWalletIDs lists the distinct wallets of the items in ascending order.
+// IDs that are not numbers are left out; their items fail once they are
+// applied.
+func batchWalletIDs(items []domain.BatchItem) []int {
+	seen := make(map[int]bool)
+	var ids []int
+	for _, item := range items {
+		id, err := strconv.Atoi(item.WalletID)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// applyBatchItem reads the wallet afresh, so that items of the same batch
+// see each other's balances, and applies the item to it.
+func (w *walletService) applyBatchItem(ctx context.Context, item domain.BatchItem, actorID int) (domain.Transaction, error) {
+	if err := validateBatchItem(item); err != nil {
+		return domain.Transaction{}, err
+	}
+	wallet, err := w.walletRepository.Get(ctx, item.WalletID)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+	if item.Type == domain.TransactionTypeCredit {
+		return w.applyCredit(ctx, &wallet, item.Amount, actorID, item.TransactionDetails)
+	}
+	return w.applyDebit(ctx, &wallet, item.Amount, actorID, item.TransactionDetails)
+}
+
+func validateBatchItem(item domain.BatchItem) error {
+	if item.Type != domain.TransactionTypeCredit && item.Type != domain.TransactionTypeDebit {
+		return domain.ErrInvalidBatchItem
+	}
+	// Withdrawals are paid out through their own endpoint only.
+	if item.Category == domain.CategoryWithdrawal {
+		return domain.ErrInvalidCategory
+	}
+	return item.Validate(item.Type)
+}
+
+// withRepository returns a copy of the service that reads and writes through
+// r, typically a repository bound to a database transaction.
+func (w *walletService) withRepository(r domain.WalletRepository) *walletService {
+	bound := *w
+	bound.walletRepository = r
+	return &bound
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+
+	"github.com/shopspring/decimal"
+)
+
+// WithBonusSpendOrder sets whether debits spend cash or bonus funds first.
+func WithBonusSpendOrder(order domain.BonusSpendOrder) Option {
+	return func(w *walletService) {
+		w.bonusSpendOrder = order
+	}
+}
+
+// CreditBonus grants amount as bonus funds locked behind a wagering
+// requirement of amount times wageringMultiplier. The multiplier must be
+// positive, or the bonus would turn into cash straight away.
+func (w *walletService) CreditBonus(ctx context.Context, id, amount, wageringMultiplier string, actorID int) (domain.Bonus, error) {
+	multiplier, err := decimal.NewFromString(wageringMultiplier)
+	if err != nil || !multiplier.IsPositive() {
+		return domain.Bonus{}, domain.ErrInvalidAmount
+	}
+	var bonus domain.Bonus
+	_, err = w.mutate(ctx, id, func(wallet *domain.Wallet) (domain.Transaction, error) {
+		if err := wallet.CanCredit(); err != nil {
+			return domain.Transaction{}, err
+		}
+		bonusAmount, err := parseAmount(amount, wallet.Currency)
+		if err != nil {
+			return domain.Transaction{}, err
+		}
+		if !bonusAmount.IsPositive() {
+			return domain.Transaction{}, domain.ErrInvalidAmount
+		}
+		bonus = domain.Bonus{
+			WalletID:           wallet.ID,
+			PlayerID:           actorID,
+			Amount:             bonusAmount,
+			Remaining:          bonusAmount,
+			WageringMultiplier: multiplier,
+			WageringRequired:   bonusAmount.Mul(multiplier),
+			Wagered:            decimal.Zero,
+			Status:             domain.BonusStatusActive,
+		}
+		transaction := domain.Transaction{
+			WalletID:      wallet.ID,
+			PlayerID:      actorID,
+			Type:          domain.TransactionTypeCredit,
+			Amount:        bonusAmount,
+			BonusAmount:   bonusAmount,
+			BalanceBefore: wallet.Balance,
+		}
+		wallet.Balance = wallet.Balance.Add(bonusAmount)
+		wallet.BonusBalance = wallet.BonusBalance.Add(bonusAmount)
+		transaction.BalanceAfter = wallet.Balance
+		err = w.walletRepository.Transaction(ctx, func(r domain.WalletRepository) error {
+			if err := r.SaveBonus(ctx, &bonus); err != nil {
+				return err
+			}
+			transaction.BonusID = &bonus.ID
+			return r.Credit(ctx, wallet, &transaction)
+		})
+		return transaction, err
+	})
+	if err != nil {
+		return domain.Bonus{}, err
+	}
+	return bonus, nil
+}
+
+func (w *walletService) ListBonuses(ctx context.Context, id string) ([]domain.Bonus, error) {
+	wallet, err := w.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return w.walletRepository.ListBonuses(ctx, wallet.ID, "")
+}
+
+// wager works out how much of a debit is paid from bonus funds, following
+// the configured spend order, and counts the debit towards the wagering of
+// the wallet's active bonuses, oldest first. Bonuses that reach their
+// requirement have their remaining funds converted into cash. It updates
+// wallet.BonusBalance and returns the bonus part of the debit along with the
+// bonuses it changed, which must be saved with the debit.
+func (w *walletService) wager(ctx context.Context, wallet *domain.Wallet, amount decimal.Decimal) (decimal.Decimal, []domain.Bonus, error) {
+	if !wallet.BonusBalance.IsPositive() {
+		return decimal.Zero, nil, nil
+	}
+	bonuses, err := w.walletRepository.ListBonuses(ctx, wallet.ID, domain.BonusStatusActive)
+	if err != nil {
+		return decimal.Zero, nil, err
+	}
+
+	var bonusPart decimal.Decimal
+	switch w.bonusSpendOrder {
+	case domain.BonusSpendBonusFirst:
+		bonusPart = decimal.Min(amount, wallet.BonusBalance)
+	default:
+		bonusPart = decimal.Min(decimal.Max(amount.Sub(wallet.CashBalance()), decimal.Zero), wallet.BonusBalance)
+	}
+	wallet.BonusBalance = wallet.BonusBalance.Sub(bonusPart)
+
+	toSpend, toWager := bonusPart, amount
+	for i := range bonuses {
+		bonus := &bonuses[i]
+		spent := decimal.Min(toSpend, bonus.Remaining)
+		bonus.Remaining = bonus.Remaining.Sub(spent)
+		toSpend = toSpend.Sub(spent)
+		if !bonus.Remaining.IsPositive() {
+			bonus.Status = domain.BonusStatusSpent
+			continue
+		}
+		wagered := decimal.Min(toWager, bonus.WageringRequired.Sub(bonus.Wagered))
+		bonus.Wagered = bonus.Wagered.Add(wagered)
+		toWager = toWager.Sub(wagered)
+		if bonus.Wagered.GreaterThanOrEqual(bonus.WageringRequired) {
+			wallet.BonusBalance = wallet.BonusBalance.Sub(bonus.Remaining)
+			bonus.Converted = bonus.Remaining
+			bonus.Remaining = decimal.Zero
+			bonus.Status = domain.BonusStatusCompleted
+		}
+	}
+	return bonusPart, bonuses, nil
+}
+
+// unwager takes a reversed debit of amount back out of the wagering of the
+// bonuses that existed when the debit was booked, newest first, undoing what
+// wager counted for it. A completed bonus that falls short of its requirement
+// again locks the funds it converted back up, as far as the wallet's cash
+// still covers them. It updates wallet.BonusBalance and returns the bonuses
+// it changed, which must be saved with the reversal, and how much it locked
+// back up.
+func (w *walletService) unwager(ctx context.Context, wallet *domain.Wallet, debit domain.Transaction, amount decimal.Decimal) ([]domain.Bonus, decimal.Decimal, error) {
+	bonuses, err := w.walletRepository.ListBonuses(ctx, wallet.ID, "")
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+	changed := make(map[int]bool)
+	relocked := unwagerBonuses(wallet, bonuses, debit, amount, changed)
+	return pickBonuses(bonuses, changed), relocked, nil
+}
+
+// unwagerBonuses does the work of unwager on the wallet's bonuses, oldest
+// first, in place, marking the indexes it changed.
+func unwagerBonuses(wallet *domain.Wallet, bonuses []domain.Bonus, debit domain.Transaction, amount decimal.Decimal, changed map[int]bool) decimal.Decimal {
+	toUnwager, relockedTotal := amount, decimal.Zero
+	for i := len(bonuses) - 1; i >= 0 && toUnwager.IsPositive(); i-- {
+		bonus := &bonuses[i]
+		if bonus.Status == domain.BonusStatusSpent || bonus.CreatedAt.After(debit.CreatedAt) || !bonus.Wagered.IsPositive() {
+			continue
+		}
+		unwagered := decimal.Min(toUnwager, bonus.Wagered)
+		bonus.Wagered = bonus.Wagered.Sub(unwagered)
+		toUnwager = toUnwager.Sub(unwagered)
+		if bonus.Status == domain.BonusStatusCompleted && bonus.Wagered.LessThan(bonus.WageringRequired) {
+			relocked := decimal.Min(bonus.Converted, decimal.Max(wallet.CashBalance(), decimal.Zero))
+			wallet.BonusBalance = wallet.BonusBalance.Add(relocked)
+			relockedTotal = relockedTotal.Add(relocked)
+			bonus.Remaining = relocked
+			bonus.Converted = decimal.Zero
+			bonus.Status = domain.BonusStatusActive
+			if !relocked.IsPositive() {
+				bonus.Status = domain.BonusStatusSpent
+			}
+		}
+		changed[i] = true
+	}
+	return relockedTotal
+}
+
+// restoreBonuses returns up to amount of a refunded debit's bonus part to the
+// bonuses it could have been spent from: those that existed when the debit
+// was booked and have not completed since, oldest first, as wager spends
+// them. It plans the restoration without touching the bonuses and returns
+// what each of them gets back, by index, and the total.
+func restoreBonuses(bonuses []domain.Bonus, debit domain.Transaction, amount decimal.Decimal) (map[int]decimal.Decimal, decimal.Decimal) {
+	restored, total := make(map[int]decimal.Decimal), decimal.Zero
+	for i := range bonuses {
+		bonus := bonuses[i]
+		if bonus.Status == domain.BonusStatusCompleted || bonus.CreatedAt.After(debit.CreatedAt) {
+			continue
+		}
+		part := decimal.Min(amount.Sub(total), bonus.Amount.Sub(bonus.Remaining))
+		if !part.IsPositive() {
+			continue
+		}
+		restored[i] = part
+		total = total.Add(part)
+	}
+	return restored, total
+}
+
+// pickBonuses returns the bonuses at the marked indexes, in order.
+func pickBonuses(bonuses []domain.Bonus, changed map[int]bool) []domain.Bonus {
+	var picked []domain.Bonus
+	for i := range bonuses {
+		if changed[i] {
+			picked = append(picked, bonuses[i])
+		}
+	}
+	return picked
+}
+
+// converted totals what the bonuses completed by a debit turned into cash.
+func converted(bonuses []domain.Bonus) decimal.Decimal {
+	total := decimal.Zero
+	for _, bonus := range bonuses {
+		if bonus.Status == domain.BonusStatusCompleted {
+			total = total.Add(bonus.Converted)
+		}
+	}
+	return total
+}
+
+// saveBonuses stores the bonuses changed by a debit through r, which should
+// be bound to the same database transaction as the debit itself.
+func saveBonuses(ctx context.Context, r domain.WalletRepository, bonuses []domain.Bonus) error {
+	for i := range bonuses {
+		if err := r.SaveBonus(ctx, &bonuses[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBonusTransaction reports whether a ledger entry moved bonus funds.
+func isBonusTransaction(t domain.Transaction) bool {
+	return t.BonusID != nil || t.BonusAmount.IsPositive()
+}
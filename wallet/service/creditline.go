@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+)
+
+func (w *walletService) SetCreditLimit(ctx context.Context, id, amount string) (domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := retryOnConflict(func() error {
+		var err error
+		wallet, err = w.walletRepository.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if wallet.Status == domain.WalletStatusClosed {
+			return domain.ErrWalletClosed
+		}
+		creditLimit, err := parseAmount(amount, wallet.Currency)
+		if err != nil {
+			return err
+		}
+		wallet.CreditLimit = creditLimit
+		return w.walletRepository.Update(ctx, &wallet)
+	})
+	if err != nil {
+		return domain.Wallet{}, err
+	}
+	w.walletInMemoryDB.Delete(ctx, id)
+	return wallet, nil
+}
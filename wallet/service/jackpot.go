@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+	"strconv"
+
+	"github.com/shopspring/decimal"
+)
+
+// WithJackpots makes every qualifying debit pay into the jackpot pools of
+// its currency.
+func WithJackpots(r domain.JackpotRepository) Option {
+	return func(w *walletService) {
+		w.jackpots = r
+	}
+}
+
+// contributions builds the credits the debit pays into each jackpot pool of
+// the wallet's currency. They are booked by contribute once the debit has
+// its ID.
+func (w *walletService) contributions(ctx context.Context, wallet domain.Wallet, debit domain.Transaction) ([]domain.Transaction, error) {
+	if w.jackpots == nil {
+		return nil, nil
+	}
+	currency, err := domain.LookupCurrency(wallet.Currency)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := w.jackpots.ListByCurrency(ctx, currency.Code)
+	if err != nil {
+		return nil, err
+	}
+	var contributions []domain.Transaction
+	for _, pool := range pools {
+		amount := pool.Contribution(debit, currency)
+		if !amount.IsPositive() {
+			continue
+		}
+		contributions = append(contributions, domain.Transaction{
+			WalletID: pool.WalletID,
+			PlayerID: debit.PlayerID,
+			Type:     domain.TransactionTypeCredit,
+			Amount:   amount,
+			Category: domain.CategoryJackpot,
+			Metadata: domain.Metadata{"jackpot_id": strconv.Itoa(pool.ID)},
+		})
+	}
+	return contributions, nil
+}
+
+// withdrawals builds the debits taking a reversed debit's contributions back
+// out of the pools: the reversed share of what each pool received or, once
+// the debit is reversed in full, whatever of it is still in the pool. Like
+// contributions, they are booked by contribute and linked to the debit.
+func (w *walletService) withdrawals(ctx context.Context, wallet domain.Wallet, debit domain.Transaction, amount, remaining decimal.Decimal, actorID int) ([]domain.Transaction, error) {
+	if w.jackpots == nil || debit.Type != domain.TransactionTypeDebit {
+		return nil, nil
+	}
+	currency, err := domain.LookupCurrency(wallet.Currency)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := w.walletRepository.ListContributions(ctx, debit.ID)
+	if err != nil {
+		return nil, err
+	}
+	var withdrawals []domain.Transaction
+	contributed, left := make(map[int]decimal.Decimal), make(map[int]decimal.Decimal)
+	for _, entry := range entries {
+		if entry.Type == domain.TransactionTypeDebit {
+			left[entry.WalletID] = left[entry.WalletID].Sub(entry.Amount)
+			continue
+		}
+		if _, seen := contributed[entry.WalletID]; !seen {
+			withdrawals = append(withdrawals, domain.Transaction{
+				WalletID: entry.WalletID,
+				PlayerID: actorID,
+				Type:     domain.TransactionTypeDebit,
+				Category: domain.CategoryJackpot,
+				Metadata: entry.Metadata,
+			})
+		}
+		contributed[entry.WalletID] = contributed[entry.WalletID].Add(entry.Amount)
+		left[entry.WalletID] = left[entry.WalletID].Add(entry.Amount)
+	}
+	var due []domain.Transaction
+	for _, withdrawal := range withdrawals {
+		withdrawal.Amount = left[withdrawal.WalletID]
+		if amount.LessThan(remaining) {
+			share := contributed[withdrawal.WalletID].Mul(amount).Div(debit.Amount).Truncate(currency.MinorUnits)
+			withdrawal.Amount = decimal.Min(share, withdrawal.Amount)
+		}
+		if withdrawal.Amount.IsPositive() {
+			due = append(due, withdrawal)
+		}
+	}
+	return due, nil
+}
+
+// contribute books the contributions of debit, linking each to it.
+func contribute(ctx context.Context, r domain.WalletRepository, debit domain.Transaction, contributions []domain.Transaction) error {
+	for i := range contributions {
+		contributions[i].Reference = strconv.Itoa(debit.ID)
+		if err := r.Contribute(ctx, &contributions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forgetPools drops the cached copies of the pool wallets that received
+// contributions.
+func (w *walletService) forgetPools(ctx context.Context, contributions []domain.Transaction) {
+	for _, contribution := range contributions {
+		w.walletInMemoryDB.Delete(ctx, strconv.Itoa(contribution.WalletID))
+	}
+}
+
+// jackpotDetails tags the pool's own ledger entries, and the payout to its
+// winner, with the pool.
+func jackpotDetails(pool domain.JackpotPool, category domain.TransactionCategory) domain.TransactionDetails {
+	return domain.TransactionDetails{
+		Reference: "jackpot:" + strconv.Itoa(pool.ID),
+		Category:  category,
+		Metadata:  domain.Metadata{"jackpot_id": strconv.Itoa(pool.ID)},
+	}
+}
+
+func (w *walletService) OpenJackpot(ctx context.Context, pool *domain.JackpotPool, actorID int) error {
+	return w.walletRepository.Transaction(ctx, func(r domain.WalletRepository) error {
+		// The pool's wallet belongs to no player and only pays out.
+		created := domain.Wallet{Currency: pool.Currency, Status: domain.WalletStatusActive, Pool: true}
+		if err := r.Create(ctx, &created); err != nil {
+			return err
+		}
+		pool.WalletID = created.ID
+		if err := r.CreateJackpot(ctx, pool); err != nil {
+			return err
+		}
+		wallet, err := r.Get(ctx, strconv.Itoa(created.ID))
+		if err != nil {
+			return err
+		}
+		pool.Balance = wallet.Balance
+		if !pool.SeedAmount.IsPositive() {
+			return nil
+		}
+		// The seed comes from the house, like the contributions.
+		seed := domain.Transaction{
+			WalletID:      wallet.ID,
+			PlayerID:      actorID,
+			Type:          domain.TransactionTypeCredit,
+			Amount:        pool.SeedAmount,
+			BalanceBefore: wallet.Balance,
+		}
+		jackpotDetails(*pool, domain.CategoryJackpot).Apply(&seed)
+		wallet.Balance = wallet.Balance.Add(pool.SeedAmount)
+		seed.BalanceAfter = wallet.Balance
+		if err := r.Credit(ctx, &wallet, &seed); err != nil {
+			return err
+		}
+		pool.Balance = wallet.Balance
+		return nil
+	})
+}
+
+func (w *walletService) PayoutJackpot(ctx context.Context, pool domain.JackpotPool, walletID string, actorID int) (domain.JackpotPayout, error) {
+	poolWalletID := strconv.Itoa(pool.WalletID)
+	if walletID == poolWalletID {
+		return domain.JackpotPayout{}, domain.ErrSameWallet
+	}
+	winnerID, err := strconv.Atoi(walletID)
+	if err != nil {
+		return domain.JackpotPayout{}, domain.ErrRecordNotFound
+	}
+	var payout domain.JackpotPayout
+	err = w.walletRepository.Transaction(ctx, func(r domain.WalletRepository) error {
+		// Debits lock the player's wallet before the pools they pay into,
+		// so the winner is locked before the pool to take the locks in the
+		// same order. Contributions then wait for the payout instead of
+		// moving the pool's balance under it.
+		if err := r.LockWallets(ctx, []int{winnerID}); err != nil {
+			return err
+		}
+		if err := r.LockWallets(ctx, []int{pool.WalletID}); err != nil {
+			return err
+		}
+		poolWallet, err := r.Get(ctx, poolWalletID)
+		if err != nil {
+			return err
+		}
+		winner, err := r.Get(ctx, walletID)
+		if err != nil {
+			return err
+		}
+		if winner.Currency != poolWallet.Currency {
+			return domain.ErrCurrencyMismatch
+		}
+		if err := winner.CanCredit(); err != nil {
+			return err
+		}
+		amount := poolWallet.Balance
+		if !amount.IsPositive() {
+			return domain.ErrJackpotEmpty
+		}
+		payout = domain.JackpotPayout{Pool: pool}
+		payout.Credit = domain.Transaction{
+			WalletID:      winner.ID,
+			PlayerID:      actorID,
+			Type:          domain.TransactionTypeCredit,
+			Amount:        amount,
+			BalanceBefore: winner.Balance,
+		}
+		jackpotDetails(pool, domain.CategoryWin).Apply(&payout.Credit)
+		winner.Balance = winner.Balance.Add(amount)
+		payout.Credit.BalanceAfter = winner.Balance
+		if err := r.Credit(ctx, &winner, &payout.Credit); err != nil {
+			return err
+		}
+		payout.Debit = domain.Transaction{
+			WalletID:      poolWallet.ID,
+			PlayerID:      actorID,
+			Type:          domain.TransactionTypeDebit,
+			Amount:        amount,
+			BalanceBefore: poolWallet.Balance,
+		}
+		jackpotDetails(pool, domain.CategoryJackpot).Apply(&payout.Debit)
+		poolWallet.Balance = poolWallet.Balance.Sub(amount)
+		payout.Debit.BalanceAfter = poolWallet.Balance
+		payout.Pool.Balance = poolWallet.Balance
+		if err := r.Debit(ctx, &poolWallet, &payout.Debit); err != nil {
+			return err
+		}
+		if !pool.SeedAmount.IsPositive() {
+			return nil
+		}
+		// The reseed comes from the house, like the contributions.
+		payout.Reseed = &domain.Transaction{
+			WalletID:      poolWallet.ID,
+			PlayerID:      actorID,
+			Type:          domain.TransactionTypeCredit,
+			Amount:        pool.SeedAmount,
+			BalanceBefore: poolWallet.Balance,
+		}
+		jackpotDetails(pool, domain.CategoryJackpot).Apply(payout.Reseed)
+		poolWallet.Balance = poolWallet.Balance.Add(pool.SeedAmount)
+		payout.Reseed.BalanceAfter = poolWallet.Balance
+		payout.Pool.Balance = poolWallet.Balance
+		return r.Credit(ctx, &poolWallet, payout.Reseed)
+	})
+	if err != nil {
+		return domain.JackpotPayout{}, err
+	}
+	w.walletInMemoryDB.Delete(ctx, poolWalletID)
+	w.walletInMemoryDB.Delete(ctx, walletID)
+	return payout, nil
+}
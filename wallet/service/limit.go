@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"quik/domain"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// limitedWrite runs write once a movement of amount on the wallet passed its
+// owner's limits of the given types. When any of them applies, the check and
+// the write share one database transaction holding the player's lock, so a
+// movement on another of the player's wallets, which counts towards the same
+// limits, waits for the write instead of passing the same totals.
+func (w *walletService) limitedWrite(ctx context.Context, wallet domain.Wallet, amount decimal.Decimal, limitTypes []domain.LimitType, write func(r domain.WalletRepository) error) error {
+	limits, err := w.limitsOf(ctx, wallet, limitTypes)
+	if err != nil {
+		return err
+	}
+	if len(limits) == 0 {
+		return write(w.walletRepository)
+	}
+	return w.walletRepository.Transaction(ctx, func(r domain.WalletRepository) error {
+		if err := r.LockPlayer(ctx, wallet.PlayerID); err != nil {
+			return err
+		}
+		if err := w.withRepository(r).checkLimits(ctx, limits, amount); err != nil {
+			return err
+		}
+		return write(r)
+	})
+}
+
+// limitsOf lists the limits of the given types the wallet's owner holds in
+// the wallet's currency.
+func (w *walletService) limitsOf(ctx context.Context, wallet domain.Wallet, limitTypes []domain.LimitType) ([]domain.Limit, error) {
+	if len(limitTypes) == 0 {
+		return nil, nil
+	}
+	all, err := w.walletRepository.ListLimits(ctx, wallet.PlayerID, wallet.Currency)
+	if err != nil {
+		return nil, err
+	}
+	var limits []domain.Limit
+	for _, limit := range all {
+		if containsLimitType(limitTypes, limit.Type) {
+			limits = append(limits, limit)
+		}
+	}
+	return limits, nil
+}
+
+// checkLimits refuses a movement of amount that would take the player over
+// any of the limits within that limit's rolling window.
+func (w *walletService) checkLimits(ctx context.Context, limits []domain.Limit, amount decimal.Decimal) error {
+	now := time.Now()
+	for _, limit := range limits {
+		if err := w.limitUsage(ctx, &limit, now); err != nil {
+			return err
+		}
+		if limit.Remaining.Valid && amount.GreaterThan(limit.Remaining.Decimal) {
+			return fmt.Errorf("%w: %s limit of %s has %s remaining",
+				limit.Type.ExceededError(), limit.Period, limit.Amount.Decimal, limit.Remaining.Decimal)
+		}
+	}
+	return nil
+}
+
+func containsLimitType(limitTypes []domain.LimitType, limitType domain.LimitType) bool {
+	for _, t := range limitTypes {
+		if t == limitType {
+			return true
+		}
+	}
+	return false
+}
+
+// limitUsage settles the limit and fills in how much of it the current window
+// used and what remains.
+func (w *walletService) limitUsage(ctx context.Context, limit *domain.Limit, now time.Time) error {
+	limit.Settle(now)
+	activity, err := w.walletRepository.SumActivity(ctx, limit.PlayerID, limit.Currency, now.Add(-limit.Period.Window()))
+	if err != nil {
+		return err
+	}
+	limit.Used = activity.Used(limit.Type)
+	limit.Remaining = decimal.NullDecimal{}
+	if limit.Amount.Valid {
+		limit.Remaining = decimal.NewNullDecimal(decimal.Max(limit.Amount.Decimal.Sub(limit.Used), decimal.Zero))
+	}
+	return nil
+}
+
+func (w *walletService) SetLimit(ctx context.Context, id string, limitType domain.LimitType, period domain.LimitPeriod, amount string) (domain.Limit, error) {
+	if !limitType.Valid() {
+		return domain.Limit{}, domain.ErrInvalidLimitType
+	}
+	if !period.Valid() {
+		return domain.Limit{}, domain.ErrInvalidLimitPeriod
+	}
+	wallet, err := w.Get(ctx, id)
+	if err != nil {
+		return domain.Limit{}, err
+	}
+	var limitAmount decimal.NullDecimal
+	if amount != "" {
+		value, err := parseAmount(amount, wallet.Currency)
+		if err != nil {
+			return domain.Limit{}, err
+		}
+		limitAmount = decimal.NewNullDecimal(value)
+	}
+	now := time.Now()
+	var limit domain.Limit
+	for attempt := 0; ; attempt++ {
+		limits, err := w.walletRepository.ListLimits(ctx, wallet.PlayerID, wallet.Currency)
+		if err != nil {
+			return domain.Limit{}, err
+		}
+		limit = domain.Limit{PlayerID: wallet.PlayerID, Currency: wallet.Currency, Type: limitType, Period: period}
+		for _, existing := range limits {
+			if existing.Type == limitType && existing.Period == period {
+				limit = existing
+			}
+		}
+		limit.Change(limitAmount, now)
+		err = w.walletRepository.SaveLimit(ctx, &limit)
+		// A concurrent request created the limit first; apply the change to
+		// its row instead.
+		if errors.Is(err, domain.ErrDuplicateRecord) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return domain.Limit{}, err
+		}
+		break
+	}
+	err = w.limitUsage(ctx, &limit, now)
+	return limit, err
+}
+
+func (w *walletService) ListLimits(ctx context.Context, id string) ([]domain.Limit, error) {
+	wallet, err := w.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := w.walletRepository.ListLimits(ctx, wallet.PlayerID, wallet.Currency)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range limits {
+		if err := w.limitUsage(ctx, &limits[i], now); err != nil {
+			return nil, err
+		}
+	}
+	return limits, nil
+}
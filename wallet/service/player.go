@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+	"strconv"
+)
+
+// WithMaxWalletsPerPlayer caps how many wallets a player may hold; 0 leaves
+// it uncapped.
+func WithMaxWalletsPerPlayer(max int) Option {
+	return func(w *walletService) {
+		w.maxWallets = max
+	}
+}
+
+func (w *walletService) GetForPlayer(ctx context.Context, playerID int, id string) (domain.Wallet, error) {
+	return w.playerWallet(ctx, playerID, id)
+}
+
+func (w *walletService) ListByPlayer(ctx context.Context, playerID int) ([]domain.Wallet, error) {
+	return w.walletRepository.ListByPlayer(ctx, playerID)
+}
+
+func (w *walletService) SetDefault(ctx context.Context, playerID int, id string) (domain.Wallet, error) {
+	wallet, err := w.playerWallet(ctx, playerID, id)
+	if err != nil {
+		return domain.Wallet{}, err
+	}
+	if err := wallet.CanCredit(); err != nil {
+		return domain.Wallet{}, err
+	}
+	if wallet.IsDefault {
+		return wallet, nil
+	}
+	if err := w.walletRepository.SetDefault(ctx, &wallet); err != nil {
+		return domain.Wallet{}, err
+	}
+	// The previous default changed as well.
+	if err := w.forgetPlayerWallets(ctx, playerID); err != nil {
+		return domain.Wallet{}, err
+	}
+	return wallet, nil
+}
+
+// forgetPlayerWallets drops the cached copies of all the player's wallets.
+func (w *walletService) forgetPlayerWallets(ctx context.Context, playerID int) error {
+	wallets, err := w.walletRepository.ListByPlayer(ctx, playerID)
+	if err != nil {
+		return err
+	}
+	for _, cached := range wallets {
+		w.walletInMemoryDB.Delete(ctx, strconv.Itoa(cached.ID))
+	}
+	return nil
+}
+
+func (w *walletService) SetLabel(ctx context.Context, playerID int, id, label string) (domain.Wallet, error) {
+	if !domain.ValidWalletLabel(label) {
+		return domain.Wallet{}, domain.ErrInvalidWalletLabel
+	}
+	wallet, err := w.playerWallet(ctx, playerID, id)
+	if err != nil {
+		return domain.Wallet{}, err
+	}
+	wallet.Label = label
+	if err := w.walletRepository.SetLabel(ctx, &wallet); err != nil {
+		return domain.Wallet{}, err
+	}
+	w.walletInMemoryDB.Delete(ctx, id)
+	return wallet, nil
+}
+
+// playerWallet loads the wallet, reporting wallets of other players as not
+// found.
+func (w *walletService) playerWallet(ctx context.Context, playerID int, id string) (domain.Wallet, error) {
+	wallet, err := w.walletRepository.Get(ctx, id)
+	if err != nil {
+		return domain.Wallet{}, err
+	}
+	if wallet.PlayerID != playerID {
+		return domain.Wallet{}, domain.ErrRecordNotFound
+	}
+	return wallet, nil
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"quik/domain"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// walletBatchSize is how many wallets the periodic jobs load at a time.
+const walletBatchSize = 500
+
+// Reconcile recomputes every wallet's balance from its ledger and compares it
+// with the stored balance and the cached copy. Each batch of wallets is read
+// together with its ledger sums inside one database transaction so that
+// concurrent movements cannot show up as drift. Stale cache entries are
+// deleted rather than overwritten when repairCache is set, so the next read
+// refills them from the database; deleting an entry that was merely newer
+// than the batch is harmless.
+func (w *walletService) Reconcile(ctx context.Context, repairCache bool) (domain.ReconciliationReport, error) {
+	report := domain.ReconciliationReport{StartedAt: time.Now(), Mismatches: []domain.BalanceMismatch{}}
+	afterID := 0
+	for {
+		var wallets []domain.Wallet
+		var ledger map[int]decimal.Decimal
+		err := w.walletRepository.Transaction(ctx, func(r domain.WalletRepository) error {
+			var err error
+			wallets, err = r.ListWallets(ctx, afterID, walletBatchSize)
+			if err != nil || len(wallets) == 0 {
+				return err
+			}
+			ids := make([]int, len(wallets))
+			for i, wallet := range wallets {
+				ids[i] = wallet.ID
+			}
+			ledger, err = r.LedgerBalances(ctx, ids)
+			return err
+		})
+		if err != nil {
+			return report, err
+		}
+		for _, wallet := range wallets {
+			mismatch, err := w.reconcileWallet(ctx, wallet, ledger[wallet.ID], repairCache)
+			if err != nil {
+				return report, err
+			}
+			if mismatch != nil {
+				report.Mismatches = append(report.Mismatches, *mismatch)
+			}
+		}
+		report.WalletsChecked += len(wallets)
+		if len(wallets) < walletBatchSize {
+			report.FinishedAt = time.Now()
+			return report, nil
+		}
+		afterID = wallets[len(wallets)-1].ID
+	}
+}
+
+// reconcileWallet returns the mismatch found for a single wallet, or nil when
+// its stored, ledger and cached balances all agree.
+func (w *walletService) reconcileWallet(ctx context.Context, wallet domain.Wallet, ledgerBalance decimal.Decimal, repairCache bool) (*domain.BalanceMismatch, error) {
+	mismatch := domain.BalanceMismatch{
+		WalletID:      wallet.ID,
+		StoredBalance: wallet.Balance,
+		LedgerBalance: ledgerBalance,
+		LedgerDrift:   !wallet.Balance.Equal(ledgerBalance),
+	}
+	id := strconv.Itoa(wallet.ID)
+	cached, err := w.walletInMemoryDB.Get(ctx, id)
+	switch {
+	case errors.Is(err, domain.ErrKeyNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		mismatch.CachedBalance = &cached.Balance
+		mismatch.CacheDrift = !cached.Balance.Equal(wallet.Balance)
+	}
+	if !mismatch.LedgerDrift && !mismatch.CacheDrift {
+		return nil, nil
+	}
+	if mismatch.CacheDrift && repairCache {
+		if err := w.walletInMemoryDB.Delete(ctx, id); err != nil {
+			return nil, err
+		}
+		mismatch.CacheRepaired = true
+	}
+	return &mismatch, nil
+}
+
+// OpenBalances walks every wallet, booking the opening entries of those whose
+// balance predates the ledger, and then journals the ledger entries booked
+// before the journal existed. Wallets that already have an opening entry are
+// left alone, so running it again books nothing new.
+func (w *walletService) OpenBalances(ctx context.Context) (int, error) {
+	opened := 0
+	afterID := 0
+	for {
+		wallets, err := w.walletRepository.ListWallets(ctx, afterID, walletBatchSize)
+		if err != nil {
+			return opened, err
+		}
+		for _, wallet := range wallets {
+			opening, err := w.walletRepository.BookOpeningBalance(ctx, wallet.ID)
+			if err != nil {
+				return opened, err
+			}
+			if opening != nil {
+				opened++
+			}
+		}
+		if len(wallets) < walletBatchSize {
+			break
+		}
+		afterID = wallets[len(wallets)-1].ID
+	}
+	for {
+		journaled, err := w.walletRepository.JournalMissing(ctx, walletBatchSize)
+		if err != nil {
+			return opened, err
+		}
+		if journaled < walletBatchSize {
+			return opened, nil
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"quik/domain"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// snapshotSettleDelay keeps snapshots clear of transactions that may still be
+// committing, so nothing created before a snapshot's AsOf can appear later.
+const snapshotSettleDelay = time.Minute
+
+// BalanceAt starts from the latest snapshot taken at or before at and adds the
+// transactions created after the snapshot up to and including at. Moments
+// before the wallet existed yield a zero balance. A balance the wallet held
+// before its ledger only counts once OpenBalances has booked it.
+func (w *walletService) BalanceAt(ctx context.Context, walletID int, at time.Time) (decimal.Decimal, error) {
+	if at.After(time.Now()) {
+		return decimal.Zero, domain.ErrBalanceTimeInFuture
+	}
+	balance, from, err := w.latestSnapshot(ctx, walletID, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	sum, _, err := w.walletRepository.SumTransactions(ctx, walletID, from, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return balance.Add(sum), nil
+}
+
+// latestSnapshot returns the balance and moment of the wallet's latest
+// snapshot as of at, or a zero balance from the beginning of time.
+func (w *walletService) latestSnapshot(ctx context.Context, walletID int, at time.Time) (decimal.Decimal, time.Time, error) {
+	snapshot, err := w.walletRepository.LatestSnapshot(ctx, walletID, at)
+	switch {
+	case errors.Is(err, domain.ErrRecordNotFound):
+		return decimal.Zero, time.Time{}, nil
+	case err != nil:
+		return decimal.Zero, time.Time{}, err
+	}
+	return snapshot.Balance, snapshot.AsOf, nil
+}
+
+func (w *walletService) TakeSnapshots(ctx context.Context) (int, error) {
+	asOf := time.Now().Add(-snapshotSettleDelay)
+	taken := 0
+	afterID := 0
+	for {
+		wallets, err := w.walletRepository.ListWallets(ctx, afterID, walletBatchSize)
+		if err != nil {
+			return taken, err
+		}
+		for _, wallet := range wallets {
+			balance, from, err := w.latestSnapshot(ctx, wallet.ID, asOf)
+			if err != nil {
+				return taken, err
+			}
+			sum, count, err := w.walletRepository.SumTransactions(ctx, wallet.ID, from, asOf)
+			if err != nil {
+				return taken, err
+			}
+			if count == 0 {
+				continue
+			}
+			snapshot := domain.BalanceSnapshot{WalletID: wallet.ID, Balance: balance.Add(sum), AsOf: asOf}
+			if err := w.walletRepository.CreateSnapshot(ctx, &snapshot); err != nil {
+				return taken, err
+			}
+			taken++
+		}
+		if len(wallets) < walletBatchSize {
+			return taken, nil
+		}
+		afterID = wallets[len(wallets)-1].ID
+	}
+}
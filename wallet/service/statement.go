@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+	"time"
+)
+
+// WriteStatement opens the statement at the wallet's balance as of from and
+// then runs through the period's transactions as they are read, so its size
+// is not bounded by memory. The closing balance is the opening balance plus
+// the transactions written.
+func (w *walletService) WriteStatement(ctx context.Context, id string, from, to time.Time, sw domain.StatementWriter) error {
+	if now := time.Now(); to.IsZero() || to.After(now) {
+		to = now
+	}
+	if !to.After(from) {
+		return domain.ErrInvalidStatementPeriod
+	}
+	wallet, err := w.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	opening, err := w.BalanceAt(ctx, wallet.ID, from)
+	if err != nil {
+		return err
+	}
+	statement := domain.Statement{
+		WalletID:       wallet.ID,
+		Currency:       wallet.Currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		ClosingBalance: opening,
+	}
+	if err := sw.Opening(statement); err != nil {
+		return err
+	}
+	err = w.walletRepository.StreamTransactions(ctx, wallet.ID, from, to, func(t domain.Transaction) error {
+		if t.Type == domain.TransactionTypeCredit {
+			statement.ClosingBalance = statement.ClosingBalance.Add(t.Amount)
+		} else {
+			statement.ClosingBalance = statement.ClosingBalance.Sub(t.Amount)
+		}
+		statement.Transactions++
+		return sw.Transaction(t)
+	})
+	if err != nil {
+		return err
+	}
+	return sw.Closing(statement)
+}
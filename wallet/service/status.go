@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"quik/domain"
+	"strings"
+)
+
+// walletStatusTransitions lists the states each state may move to.
+var walletStatusTransitions = map[domain.WalletStatus][]domain.WalletStatus{
+	domain.WalletStatusActive: {domain.WalletStatusFrozen, domain.WalletStatusClosed},
+	domain.WalletStatusFrozen: {domain.WalletStatusActive, domain.WalletStatusClosed},
+}
+
+func (w *walletService) SetStatus(ctx context.Context, id string, status domain.WalletStatus, reason string) (domain.Wallet, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return domain.Wallet{}, domain.ErrStatusReasonRequired
+	}
+	var wallet domain.Wallet
+	var wasDefault bool
+	err := retryOnConflict(func() error {
+		var err error
+		wallet, err = w.walletRepository.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		current := wallet.Status
+		if current == "" {
+			current = domain.WalletStatusActive
+		}
+		if !canTransition(current, status) {
+			return domain.ErrInvalidStatusTransition
+		}
+		if status == domain.WalletStatusClosed {
+			if !wallet.Empty() {
+				return domain.ErrWalletNotEmpty
+			}
+			// A hold of the wallet could still be captured after it closes.
+			holds, err := w.walletRepository.CountActiveHolds(ctx, wallet.ID)
+			if err != nil {
+				return err
+			}
+			if holds > 0 {
+				return domain.ErrWalletNotEmpty
+			}
+		}
+		wasDefault = wallet.IsDefault
+		wallet.Status = status
+		wallet.StatusReason = reason
+		change := domain.WalletStatusChange{WalletID: wallet.ID, From: current, To: status, Reason: reason}
+		return w.walletRepository.UpdateStatus(ctx, &wallet, &change)
+	})
+	if err != nil {
+		return domain.Wallet{}, err
+	}
+	w.walletInMemoryDB.Delete(ctx, id)
+	if wasDefault && !wallet.IsDefault {
+		// The default moved to another of the player's wallets.
+		if err := w.forgetPlayerWallets(ctx, wallet.PlayerID); err != nil {
+			return domain.Wallet{}, err
+		}
+	}
+	return wallet, nil
+}
+
+func canTransition(from, to domain.WalletStatus) bool {
+	for _, allowed := range walletStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
+	"math/rand"
 	"quik/domain"
-	"sync"
+	"strconv"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
@@ -11,18 +14,65 @@ import (
 type walletService struct {
 	walletRepository domain.WalletRepository
 	walletInMemoryDB domain.WalletInMemoryDB
+	rateProvider     domain.RateProvider
+	conversion       domain.ConversionConfig
+	holdTTL          time.Duration
+	bonusSpendOrder  domain.BonusSpendOrder
+	jackpots         domain.JackpotRepository
+	maxWallets       int
 }
 
-func NewWalletService(r domain.WalletRepository, i domain.WalletInMemoryDB) domain.WalletService {
-	return &walletService{
+const defaultHoldTTL = 15 * time.Minute
+
+// Option configures an optional collaborator of the wallet service.
+type Option func(*walletService)
+
+// WithRateProvider enables transfers between wallets of different
+// currencies, priced from the provider's rates and the conversion config.
+func WithRateProvider(p domain.RateProvider, c domain.ConversionConfig) Option {
+	return func(w *walletService) {
+		w.rateProvider = p
+		w.conversion = c
+	}
+}
+
+// WithHoldTTL sets how long a hold reserves funds before it expires.
+func WithHoldTTL(ttl time.Duration) Option {
+	return func(w *walletService) {
+		w.holdTTL = ttl
+	}
+}
+
+func NewWalletService(r domain.WalletRepository, i domain.WalletInMemoryDB, opts ...Option) domain.WalletService {
+	w := &walletService{
 		walletRepository: r,
 		walletInMemoryDB: i,
+		holdTTL:          defaultHoldTTL,
+	}
+	for _, opt := range opts {
+		opt(w)
 	}
+	return w
 }
 
 func (w *walletService) Create(ctx context.Context, wallet *domain.Wallet) error {
-	err := w.walletRepository.Create(ctx, wallet)
-	return err
+	if wallet.Currency == "" {
+		wallet.Currency = domain.DefaultCurrency
+	}
+	currency, err := domain.LookupCurrency(wallet.Currency)
+	if err != nil {
+		return err
+	}
+	wallet.Currency = currency.Code
+	if !domain.ValidWalletLabel(wallet.Label) {
+		return domain.ErrInvalidWalletLabel
+	}
+	wallet.Status = domain.WalletStatusActive
+	// System wallets, such as jackpot pools, belong to no player.
+	if wallet.PlayerID == 0 {
+		return w.walletRepository.Create(ctx, wallet)
+	}
+	return w.walletRepository.CreateForPlayer(ctx, wallet, w.maxWallets)
 }
 
 func (w *walletService) Get(ctx context.Context, id string) (domain.Wallet, error) {
@@ -37,41 +87,736 @@ func (w *walletService) Get(ctx context.Context, id string) (domain.Wallet, erro
 	return wallet, nil
 }
 
-func (w *walletService) Credit(ctx context.Context, id, amount string) error {
-	var mutex = &sync.Mutex{}
-	mutex.Lock()
-	wallet, err := w.walletRepository.Get(ctx, id)
+func (w *walletService) Credit(ctx context.Context, id, amount string, actorID int, details domain.TransactionDetails) (domain.Transaction, error) {
+	return w.credit(ctx, id, amount, actorID, details)
+}
+
+func (w *walletService) Deposit(ctx context.Context, id, amount string, actorID int, details domain.TransactionDetails) (domain.Transaction, error) {
+	if details.Category == "" {
+		details.Category = domain.CategoryDeposit
+	}
+	if details.Category != domain.CategoryDeposit {
+		return domain.Transaction{}, domain.ErrInvalidCategory
+	}
+	return w.credit(ctx, id, amount, actorID, details)
+}
+
+func (w *walletService) credit(ctx context.Context, id, amount string, actorID int, details domain.TransactionDetails) (domain.Transaction, error) {
+	if err := details.Validate(domain.TransactionTypeCredit); err != nil {
+		return domain.Transaction{}, err
+	}
+	return w.mutate(ctx, id, func(wallet *domain.Wallet) (domain.Transaction, error) {
+		return w.applyCredit(ctx, wallet, amount, actorID, details)
+	})
+}
+
+// applyCredit validates a credit against the loaded wallet and writes it
+// through the repository. Credits in the deposit category are deposits.
+func (w *walletService) applyCredit(ctx context.Context, wallet *domain.Wallet, amount string, actorID int, details domain.TransactionDetails) (domain.Transaction, error) {
+	deposit := details.Category == domain.CategoryDeposit
+	if err := wallet.CanCredit(); err != nil {
+		return domain.Transaction{}, err
+	}
+	creditAmount, err := parseAmount(amount, wallet.Currency)
 	if err != nil {
-		return err
+		return domain.Transaction{}, err
 	}
-	creditAmount, err := decimal.NewFromString(amount)
-	if creditAmount.IsNegative() || err != nil {
-		return domain.ErrInvalidAmount
+	transaction := domain.Transaction{
+		WalletID:      wallet.ID,
+		PlayerID:      actorID,
+		Type:          domain.TransactionTypeCredit,
+		Amount:        creditAmount,
+		BalanceBefore: wallet.Balance,
+		Deposit:       deposit,
 	}
+	details.Apply(&transaction)
 	wallet.Balance = wallet.Balance.Add(creditAmount)
-	err = w.walletRepository.Credit(ctx, &wallet)
-	w.walletInMemoryDB.Delete(ctx, id)
-	mutex.Unlock()
-	return err
+	transaction.BalanceAfter = wallet.Balance
+	var limitTypes []domain.LimitType
+	if deposit {
+		limitTypes = []domain.LimitType{domain.LimitTypeDeposit}
+	}
+	err = w.limitedWrite(ctx, *wallet, creditAmount, limitTypes, func(r domain.WalletRepository) error {
+		return r.Credit(ctx, wallet, &transaction)
+	})
+	return transaction, err
+}
+
+func (w *walletService) Debit(ctx context.Context, id, amount string, actorID int, details domain.TransactionDetails) (domain.Transaction, error) {
+	if details.Category == domain.CategoryWithdrawal {
+		return w.Withdraw(ctx, id, amount, actorID, details)
+	}
+	if err := details.Validate(domain.TransactionTypeDebit); err != nil {
+		return domain.Transaction{}, err
+	}
+	return w.mutate(ctx, id, func(wallet *domain.Wallet) (domain.Transaction, error) {
+		return w.applyDebit(ctx, wallet, amount, actorID, details)
+	})
 }
 
-func (w *walletService) Debit(ctx context.Context, id, amount string) error {
-	var mutex = &sync.Mutex{}
-	mutex.Lock()
-	wallet, err := w.walletRepository.Get(ctx, id)
+// applyDebit validates a debit against the loaded wallet, counts it towards
+// bonus wagering and writes it through the repository.
+func (w *walletService) applyDebit(ctx context.Context, wallet *domain.Wallet, amount string, actorID int, details domain.TransactionDetails) (domain.Transaction, error) {
+	if err := wallet.CanDebit(); err != nil {
+		return domain.Transaction{}, err
+	}
+	debitAmount, err := parseAmount(amount, wallet.Currency)
 	if err != nil {
-		return err
+		return domain.Transaction{}, err
+	}
+	if wallet.SpendableBalance().LessThan(debitAmount) {
+		return domain.Transaction{}, domain.ErrInsufficientFunds
 	}
-	debitAmount, err := decimal.NewFromString(amount)
-	if debitAmount.IsNegative() || err != nil {
-		return domain.ErrInvalidAmount
+	bonusAmount, bonuses, err := w.wager(ctx, wallet, debitAmount)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+	transaction := domain.Transaction{
+		WalletID:       wallet.ID,
+		PlayerID:       actorID,
+		Type:           domain.TransactionTypeDebit,
+		Amount:         debitAmount,
+		BonusAmount:    bonusAmount,
+		BonusConverted: converted(bonuses),
+		BalanceBefore:  wallet.Balance,
 	}
+	details.Apply(&transaction)
 	wallet.Balance = wallet.Balance.Sub(debitAmount)
-	if wallet.Balance.IsNegative() {
-		return domain.ErrInsufficientFunds
+	transaction.BalanceAfter = wallet.Balance
+	contributions, err := w.contributions(ctx, *wallet, transaction)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+	write := func(r domain.WalletRepository) error {
+		return r.Debit(ctx, wallet, &transaction)
+	}
+	if len(bonuses) > 0 || len(contributions) > 0 {
+		write = func(r domain.WalletRepository) error {
+			return r.Transaction(ctx, func(r domain.WalletRepository) error {
+				if err := r.Debit(ctx, wallet, &transaction); err != nil {
+					return err
+				}
+				if err := saveBonuses(ctx, r, bonuses); err != nil {
+					return err
+				}
+				return contribute(ctx, r, transaction, contributions)
+			})
+		}
+	}
+	// Every stake could be lost, so it counts in full against both the
+	// wager and the loss limits.
+	err = w.limitedWrite(ctx, *wallet, debitAmount, []domain.LimitType{domain.LimitTypeWager, domain.LimitTypeLoss}, write)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+	w.forgetPools(ctx, contributions)
+	return transaction, nil
+}
+
+func (w *walletService) Withdraw(ctx context.Context, id, amount string, actorID int, details domain.TransactionDetails) (domain.Transaction, error) {
+	if details.Category == "" {
+		details.Category = domain.CategoryWithdrawal
+	}
+	if details.Category != domain.CategoryWithdrawal {
+		return domain.Transaction{}, domain.ErrInvalidCategory
+	}
+	if err := details.Validate(domain.TransactionTypeDebit); err != nil {
+		return domain.Transaction{}, err
+	}
+	return w.mutate(ctx, id, func(wallet *domain.Wallet) (domain.Transaction, error) {
+		if err := wallet.CanDebit(); err != nil {
+			return domain.Transaction{}, err
+		}
+		withdrawAmount, err := parseAmount(amount, wallet.Currency)
+		if err != nil {
+			return domain.Transaction{}, err
+		}
+		// Neither held nor bonus funds can be withdrawn.
+		transaction := domain.Transaction{
+			WalletID:      wallet.ID,
+			PlayerID:      actorID,
+			Type:          domain.TransactionTypeDebit,
+			Amount:        withdrawAmount,
+			BalanceBefore: wallet.Balance,
+			Withdrawal:    true,
+		}
+		details.Apply(&transaction)
+		wallet.Balance = wallet.Balance.Sub(withdrawAmount)
+		if wallet.AvailableBalance().IsNegative() || wallet.CashBalance().IsNegative() {
+			return domain.Transaction{}, domain.ErrInsufficientFunds
+		}
+		transaction.BalanceAfter = wallet.Balance
+		err = w.walletRepository.Debit(ctx, wallet, &transaction)
+		return transaction, err
+	})
+}
+
+// parseAmount parses a non-negative amount and checks that it fits the
+// precision of the given currency.
+func parseAmount(amount, currencyCode string) (decimal.Decimal, error) {
+	value, err := decimal.NewFromString(amount)
+	if value.IsNegative() || err != nil {
+		return decimal.Decimal{}, domain.ErrInvalidAmount
+	}
+	currency, err := domain.LookupCurrency(currencyCode)
+	if err != nil {
+		return decimal.Decimal{}, err
+	}
+	return value, currency.ValidateAmount(value)
+}
+
+// maxEditConflictRetries bounds how many times a mutation is re-read and
+// re-applied after losing an optimistic-locking race to another writer.
+const maxEditConflictRetries = 100
+
+// mutate loads the wallet and hands it to apply, which validates the change
+// and writes it through the repository. The repository rejects the write with
+// ErrEditConflict if the wallet changed since it was read, in which case the
+// whole read-modify-write is retried against the fresh balance.
+func (w *walletService) mutate(ctx context.Context, id string, apply func(wallet *domain.Wallet) (domain.Transaction, error)) (domain.Transaction, error) {
+	var transaction domain.Transaction
+	err := retryOnConflict(func() error {
+		wallet, err := w.walletRepository.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		transaction, err = apply(&wallet)
+		return err
+	})
+	if err != nil {
+		return domain.Transaction{}, err
 	}
-	err = w.walletRepository.Debit(ctx, &wallet)
 	w.walletInMemoryDB.Delete(ctx, id)
-	mutex.Unlock()
-	return err
+	return transaction, nil
+}
+
+// retryOnConflict calls fn again, after a short randomised backoff, for as
+// long as it fails with ErrEditConflict and retries remain.
+func retryOnConflict(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if errors.Is(err, domain.ErrEditConflict) && attempt < maxEditConflictRetries {
+			time.Sleep(time.Duration(rand.Int63n(int64(attempt+1) * int64(time.Millisecond))))
+			continue
+		}
+		return err
+	}
+}
+
+// Transfer debits one wallet and credits another in a single database
+// transaction, so the funds are never lost or duplicated between the legs.
+// Only the acting player's own wallets can be debited; other players' wallets
+// are reported as ErrRecordNotFound.
+func (w *walletService) Transfer(ctx context.Context, fromID, toID, amount string, actorID int) (domain.Transfer, error) {
+	if fromID == toID {
+		return domain.Transfer{}, domain.ErrSameWallet
+	}
+	transferAmount, err := decimal.NewFromString(amount)
+	if err != nil || !transferAmount.IsPositive() {
+		return domain.Transfer{}, domain.ErrInvalidAmount
+	}
+	var transfer domain.Transfer
+	err = retryOnConflict(func() error {
+		from, err := w.playerWallet(ctx, actorID, fromID)
+		if err != nil {
+			return err
+		}
+		to, err := w.walletRepository.Get(ctx, toID)
+		if err != nil {
+			return err
+		}
+		if err := from.CanDebit(); err != nil {
+			return err
+		}
+		if err := to.CanCredit(); err != nil {
+			return err
+		}
+		if _, err := parseAmount(amount, from.Currency); err != nil {
+			return err
+		}
+		toAmount, exchangeRate := transferAmount, (*decimal.Decimal)(nil)
+		if from.Currency != to.Currency {
+			toAmount, exchangeRate, err = w.convert(ctx, transferAmount, from.Currency, to.Currency)
+			if err != nil {
+				return err
+			}
+		}
+		transfer = domain.Transfer{
+			FromWalletID: from.ID,
+			ToWalletID:   to.ID,
+			PlayerID:     actorID,
+			Amount:       transferAmount,
+			ToAmount:     toAmount,
+			ExchangeRate: exchangeRate,
+		}
+		transfer.Debit = domain.Transaction{
+			WalletID:      from.ID,
+			PlayerID:      actorID,
+			Type:          domain.TransactionTypeDebit,
+			Amount:        transferAmount,
+			BalanceBefore: from.Balance,
+			ExchangeRate:  exchangeRate,
+		}
+		// Bonus funds can only be wagered, never moved out of the wallet.
+		from.Balance = from.Balance.Sub(transferAmount)
+		if from.AvailableBalance().IsNegative() || from.CashBalance().IsNegative() {
+			return domain.ErrInsufficientFunds
+		}
+		transfer.Debit.BalanceAfter = from.Balance
+		transfer.Credit = domain.Transaction{
+			WalletID:      to.ID,
+			PlayerID:      actorID,
+			Type:          domain.TransactionTypeCredit,
+			Amount:        toAmount,
+			BalanceBefore: to.Balance,
+			ExchangeRate:  exchangeRate,
+		}
+		to.Balance = to.Balance.Add(toAmount)
+		transfer.Credit.BalanceAfter = to.Balance
+
+		return w.walletRepository.Transaction(ctx, func(r domain.WalletRepository) error {
+			if err := r.CreateTransfer(ctx, &transfer); err != nil {
+				return err
+			}
+			transfer.Debit.TransferID = &transfer.ID
+			transfer.Credit.TransferID = &transfer.ID
+			// Wallets are always written in ascending ID order so that
+			// transfers running in opposite directions cannot deadlock.
+			if from.ID < to.ID {
+				if err := r.Debit(ctx, &from, &transfer.Debit); err != nil {
+					return err
+				}
+				return r.Credit(ctx, &to, &transfer.Credit)
+			}
+			if err := r.Credit(ctx, &to, &transfer.Credit); err != nil {
+				return err
+			}
+			return r.Debit(ctx, &from, &transfer.Debit)
+		})
+	})
+	if err != nil {
+		return domain.Transfer{}, err
+	}
+	w.walletInMemoryDB.Delete(ctx, fromID)
+	w.walletInMemoryDB.Delete(ctx, toID)
+	return transfer, nil
+}
+
+// convert prices amount from one currency into another at the provider's
+// rate less the configured spread, rounded to the target currency precision.
+// It returns the converted amount and the rate actually applied.
+func (w *walletService) convert(ctx context.Context, amount decimal.Decimal, from, to string) (decimal.Decimal, *decimal.Decimal, error) {
+	if w.rateProvider == nil {
+		return decimal.Decimal{}, nil, domain.ErrCurrencyMismatch
+	}
+	rate, err := w.rateProvider.Rate(ctx, from, to)
+	if err != nil {
+		return decimal.Decimal{}, nil, err
+	}
+	currency, err := domain.LookupCurrency(to)
+	if err != nil {
+		return decimal.Decimal{}, nil, err
+	}
+	applied := rate.Rate.Mul(decimal.NewFromInt(1).Sub(w.conversion.Spread))
+	converted := w.conversion.Round(amount.Mul(applied), currency.MinorUnits)
+	if !converted.IsPositive() {
+		return decimal.Decimal{}, nil, domain.ErrInvalidAmount
+	}
+	return converted, &applied, nil
+}
+
+const (
+	defaultTransactionPageSize = 20
+	maxTransactionPageSize     = 100
+)
+
+// ListTransactions returns a page of the wallet's transaction history along
+// with the cursor for the next page, which is zero on the last page.
+func (w *walletService) ListTransactions(ctx context.Context, id string, filter domain.TransactionFilter) ([]domain.Transaction, int, error) {
+	if _, err := w.Get(ctx, id); err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultTransactionPageSize
+	}
+	if filter.Limit > maxTransactionPageSize {
+		filter.Limit = maxTransactionPageSize
+	}
+	pageSize := filter.Limit
+	filter.Limit++
+	transactions, err := w.walletRepository.ListTransactions(ctx, id, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	var nextCursor int
+	if len(transactions) > pageSize {
+		transactions = transactions[:pageSize]
+		nextCursor = transactions[pageSize-1].ID
+	}
+	return transactions, nextCursor, nil
+}
+
+// Hold reserves amount on the wallet, lowering its available balance until
+// the hold is captured, voided or expires.
+func (w *walletService) Hold(ctx context.Context, id, amount string, actorID int) (domain.Hold, error) {
+	var hold domain.Hold
+	err := retryOnConflict(func() error {
+		wallet, err := w.walletRepository.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := wallet.CanDebit(); err != nil {
+			return err
+		}
+		holdAmount, err := parseAmount(amount, wallet.Currency)
+		if err != nil {
+			return err
+		}
+		if !holdAmount.IsPositive() {
+			return domain.ErrInvalidAmount
+		}
+		wallet.HeldBalance = wallet.HeldBalance.Add(holdAmount)
+		if wallet.SpendableBalance().IsNegative() {
+			return domain.ErrInsufficientFunds
+		}
+		hold = domain.Hold{
+			WalletID:       wallet.ID,
+			PlayerID:       actorID,
+			Amount:         holdAmount,
+			CapturedAmount: decimal.Zero,
+			Status:         domain.HoldStatusActive,
+			ExpiresAt:      time.Now().Add(w.holdTTL),
+		}
+		// A hold reserves a stake, so it is checked like a debit. Capturing
+		// it is not checked again: active holds already count as wagered.
+		return w.limitedWrite(ctx, wallet, holdAmount, []domain.LimitType{domain.LimitTypeWager, domain.LimitTypeLoss}, func(r domain.WalletRepository) error {
+			return r.CreateHold(ctx, &wallet, &hold)
+		})
+	})
+	if err != nil {
+		return domain.Hold{}, err
+	}
+	w.walletInMemoryDB.Delete(ctx, id)
+	return hold, nil
+}
+
+// getActiveHold loads a hold of the given wallet that can still be settled.
+func (w *walletService) getActiveHold(ctx context.Context, id, holdID string) (domain.Hold, error) {
+	hold, err := w.walletRepository.GetHold(ctx, holdID)
+	if err != nil {
+		return domain.Hold{}, err
+	}
+	if strconv.Itoa(hold.WalletID) != id {
+		return domain.Hold{}, domain.ErrRecordNotFound
+	}
+	if hold.Status != domain.HoldStatusActive {
+		return domain.Hold{}, domain.ErrHoldNotActive
+	}
+	if !time.Now().Before(hold.ExpiresAt) {
+		return domain.Hold{}, domain.ErrHoldExpired
+	}
+	return hold, nil
+}
+
+func (w *walletService) CaptureHold(ctx context.Context, id, holdID, amount string, actorID int) (domain.Transaction, error) {
+	hold, err := w.getActiveHold(ctx, id, holdID)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+	var transaction domain.Transaction
+	var contributions []domain.Transaction
+	err = retryOnConflict(func() error {
+		wallet, err := w.walletRepository.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := wallet.CanDebit(); err != nil {
+			return err
+		}
+		captureAmount := hold.Amount
+		if amount != "" {
+			captureAmount, err = parseAmount(amount, wallet.Currency)
+			if err != nil {
+				return err
+			}
+			if !captureAmount.IsPositive() || captureAmount.GreaterThan(hold.Amount) {
+				return domain.ErrInvalidAmount
+			}
+		}
+		bonusAmount, bonuses, err := w.wager(ctx, &wallet, captureAmount)
+		if err != nil {
+			return err
+		}
+		transaction = domain.Transaction{
+			WalletID:       wallet.ID,
+			PlayerID:       actorID,
+			Type:           domain.TransactionTypeDebit,
+			Amount:         captureAmount,
+			BonusAmount:    bonusAmount,
+			BonusConverted: converted(bonuses),
+			BalanceBefore:  wallet.Balance,
+			HoldID:         &hold.ID,
+		}
+		wallet.HeldBalance = wallet.HeldBalance.Sub(hold.Amount)
+		wallet.Balance = wallet.Balance.Sub(captureAmount)
+		transaction.BalanceAfter = wallet.Balance
+		settled := hold
+		settled.Status = domain.HoldStatusCaptured
+		settled.CapturedAmount = captureAmount
+		contributions, err = w.contributions(ctx, wallet, transaction)
+		if err != nil {
+			return err
+		}
+		if len(bonuses) == 0 && len(contributions) == 0 {
+			return w.walletRepository.SettleHold(ctx, &wallet, &settled, &transaction)
+		}
+		return w.walletRepository.Transaction(ctx, func(r domain.WalletRepository) error {
+			if err := r.SettleHold(ctx, &wallet, &settled, &transaction); err != nil {
+				return err
+			}
+			if err := saveBonuses(ctx, r, bonuses); err != nil {
+				return err
+			}
+			return contribute(ctx, r, transaction, contributions)
+		})
+	})
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+	w.walletInMemoryDB.Delete(ctx, id)
+	w.forgetPools(ctx, contributions)
+	return transaction, nil
+}
+
+func (w *walletService) VoidHold(ctx context.Context, id, holdID string) (domain.Hold, error) {
+	hold, err := w.getActiveHold(ctx, id, holdID)
+	if err != nil {
+		return domain.Hold{}, err
+	}
+	return w.releaseHold(ctx, hold, domain.HoldStatusVoided)
+}
+
+// releaseHold returns the reserved amount of an active hold to the wallet's
+// available balance and moves the hold to status.
+func (w *walletService) releaseHold(ctx context.Context, hold domain.Hold, status domain.HoldStatus) (domain.Hold, error) {
+	id := strconv.Itoa(hold.WalletID)
+	err := retryOnConflict(func() error {
+		wallet, err := w.walletRepository.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		wallet.HeldBalance = wallet.HeldBalance.Sub(hold.Amount)
+		hold.Status = status
+		return w.walletRepository.SettleHold(ctx, &wallet, &hold, nil)
+	})
+	if err != nil {
+		return domain.Hold{}, err
+	}
+	w.walletInMemoryDB.Delete(ctx, id)
+	return hold, nil
+}
+
+const expiredHoldsBatchSize = 100
+
+// ExpireHolds releases every active hold past its expiry and returns how
+// many it released. It is meant to run periodically.
+func (w *walletService) ExpireHolds(ctx context.Context) (int, error) {
+	expired := 0
+	for {
+		holds, err := w.walletRepository.ListExpiredHolds(ctx, time.Now(), expiredHoldsBatchSize)
+		if err != nil {
+			return expired, err
+		}
+		for _, hold := range holds {
+			_, err := w.releaseHold(ctx, hold, domain.HoldStatusExpired)
+			switch {
+			case err == nil:
+				expired++
+			case errors.Is(err, domain.ErrHoldNotActive):
+				// Captured or voided while the batch was being processed.
+			default:
+				return expired, err
+			}
+		}
+		if len(holds) < expiredHoldsBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// Reverse books a compensating entry against a credit or debit: a debit for a
+// credit and a credit for a debit. Partial reversals are allowed as long as
+// the reversed total never exceeds the original amount. Transfer legs,
+// entries that moved bonus funds and reversals themselves cannot be reversed;
+// debits paid from bonus funds can be refunded instead.
+func (w *walletService) Reverse(ctx context.Context, transactionID, amount string, actorID int) (domain.Transaction, error) {
+	original, err := w.walletRepository.GetTransaction(ctx, transactionID)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+	if original.TransferID != nil || original.ReversalOfID != nil || isBonusTransaction(original) {
+		return domain.Transaction{}, domain.ErrTransactionNotReversible
+	}
+	id := strconv.Itoa(original.WalletID)
+	return w.mutate(ctx, id, func(wallet *domain.Wallet) (domain.Transaction, error) {
+		// Reversing a credit takes funds out of the wallet.
+		check := wallet.CanDebit
+		if original.Type == domain.TransactionTypeDebit {
+			check = wallet.CanCredit
+		}
+		if err := check(); err != nil {
+			return domain.Transaction{}, err
+		}
+		// The wallet is read before the reversed total, so a concurrent
+		// reversal either shows up in the total or fails our version check.
+		reversed, err := w.walletRepository.ReversedAmount(ctx, original.ID)
+		if err != nil {
+			return domain.Transaction{}, err
+		}
+		remaining := original.Amount.Sub(reversed)
+		if !remaining.IsPositive() {
+			return domain.Transaction{}, domain.ErrAlreadyReversed
+		}
+		reverseAmount := remaining
+		if amount != "" {
+			reverseAmount, err = parseAmount(amount, wallet.Currency)
+			if err != nil {
+				return domain.Transaction{}, err
+			}
+			if !reverseAmount.IsPositive() || reverseAmount.GreaterThan(remaining) {
+				return domain.Transaction{}, domain.ErrInvalidAmount
+			}
+		}
+		transaction := domain.Transaction{
+			WalletID:      wallet.ID,
+			PlayerID:      actorID,
+			Amount:        reverseAmount,
+			BalanceBefore: wallet.Balance,
+			ReversalOfID:  &original.ID,
+		}
+		if original.Type == domain.TransactionTypeDebit {
+			transaction.Type = domain.TransactionTypeCredit
+			wallet.Balance = wallet.Balance.Add(reverseAmount)
+			transaction.BalanceAfter = wallet.Balance
+			// The stake no longer counts towards bonus wagering.
+			bonuses, relocked, err := w.unwager(ctx, wallet, original, reverseAmount)
+			if err != nil {
+				return domain.Transaction{}, err
+			}
+			transaction.BonusConverted = relocked.Neg()
+			// So does its contribution to the jackpot pools.
+			withdrawals, err := w.withdrawals(ctx, *wallet, original, reverseAmount, remaining, actorID)
+			if err != nil {
+				return domain.Transaction{}, err
+			}
+			if len(bonuses) == 0 && len(withdrawals) == 0 {
+				err = w.walletRepository.Credit(ctx, wallet, &transaction)
+				return transaction, err
+			}
+			err = w.walletRepository.Transaction(ctx, func(r domain.WalletRepository) error {
+				if err := r.Credit(ctx, wallet, &transaction); err != nil {
+					return err
+				}
+				if err := saveBonuses(ctx, r, bonuses); err != nil {
+					return err
+				}
+				return contribute(ctx, r, original, withdrawals)
+			})
+			if err != nil {
+				return domain.Transaction{}, err
+			}
+			w.forgetPools(ctx, withdrawals)
+			return transaction, nil
+		}
+		transaction.Type = domain.TransactionTypeDebit
+		wallet.Balance = wallet.Balance.Sub(reverseAmount)
+		if wallet.AvailableBalance().IsNegative() || wallet.CashBalance().IsNegative() {
+			return domain.Transaction{}, domain.ErrInsufficientFunds
+		}
+		transaction.BalanceAfter = wallet.Balance
+		err = w.walletRepository.Debit(ctx, wallet, &transaction)
+		return transaction, err
+	})
+}
+
+// Refund credits back whatever of a debit has not been reversed yet. Unlike
+// Reverse it accepts debits paid partly from bonus funds: the bonus part goes
+// back to the bonuses it could have come from, and whatever of it they can no
+// longer take, because they have completed since, is refunded as cash. What
+// the debit paid into jackpot pools is taken back out of them.
+func (w *walletService) Refund(ctx context.Context, transactionID string, actorID int, details domain.TransactionDetails) (domain.Transaction, error) {
+	if err := details.Validate(domain.TransactionTypeCredit); err != nil {
+		return domain.Transaction{}, err
+	}
+	original, err := w.walletRepository.GetTransaction(ctx, transactionID)
+	if err != nil {
+		return domain.Transaction{}, err
+	}
+	if original.Type != domain.TransactionTypeDebit || original.TransferID != nil || original.ReversalOfID != nil {
+		return domain.Transaction{}, domain.ErrTransactionNotReversible
+	}
+	id := strconv.Itoa(original.WalletID)
+	return w.mutate(ctx, id, func(wallet *domain.Wallet) (domain.Transaction, error) {
+		if err := wallet.CanCredit(); err != nil {
+			return domain.Transaction{}, err
+		}
+		reversed, err := w.walletRepository.ReversedAmount(ctx, original.ID)
+		if err != nil {
+			return domain.Transaction{}, err
+		}
+		remaining := original.Amount.Sub(reversed)
+		if !remaining.IsPositive() {
+			return domain.Transaction{}, domain.ErrAlreadyReversed
+		}
+		bonuses, err := w.walletRepository.ListBonuses(ctx, wallet.ID, "")
+		if err != nil {
+			return domain.Transaction{}, err
+		}
+		transaction := domain.Transaction{
+			WalletID:      wallet.ID,
+			PlayerID:      actorID,
+			Type:          domain.TransactionTypeCredit,
+			Amount:        remaining,
+			BalanceBefore: wallet.Balance,
+			ReversalOfID:  &original.ID,
+		}
+		details.Apply(&transaction)
+		wallet.Balance = wallet.Balance.Add(remaining)
+		transaction.BalanceAfter = wallet.Balance
+		// Bonus debits can only be refunded, and in full, so whatever is
+		// left of the debit still includes its whole bonus part.
+		plan, restored := restoreBonuses(bonuses, original, decimal.Min(original.BonusAmount, remaining))
+		wallet.BonusBalance = wallet.BonusBalance.Add(restored)
+		transaction.BonusAmount = restored
+		changed := make(map[int]bool)
+		relocked := unwagerBonuses(wallet, bonuses, original, remaining, changed)
+		transaction.BonusConverted = relocked.Neg()
+		for i, part := range plan {
+			bonuses[i].Remaining = bonuses[i].Remaining.Add(part)
+			bonuses[i].Status = domain.BonusStatusActive
+			changed[i] = true
+		}
+		withdrawals, err := w.withdrawals(ctx, *wallet, original, remaining, remaining, actorID)
+		if err != nil {
+			return domain.Transaction{}, err
+		}
+		if len(changed) == 0 && len(withdrawals) == 0 {
+			err = w.walletRepository.Credit(ctx, wallet, &transaction)
+			return transaction, err
+		}
+		err = w.walletRepository.Transaction(ctx, func(r domain.WalletRepository) error {
+			if err := r.Credit(ctx, wallet, &transaction); err != nil {
+				return err
+			}
+			if err := saveBonuses(ctx, r, pickBonuses(bonuses, changed)); err != nil {
+				return err
+			}
+			return contribute(ctx, r, original, withdrawals)
+		})
+		if err != nil {
+			return domain.Transaction{}, err
+		}
+		w.forgetPools(ctx, withdrawals)
+		return transaction, nil
+	})
 }
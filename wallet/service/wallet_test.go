@@ -6,7 +6,11 @@ import (
 	"quik/domain"
 	"quik/domain/mocks/inmemorydb"
 	"quik/domain/mocks/repository"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -26,11 +30,12 @@ func TestCredit(t *testing.T) {
 			Balance:  balance,
 			PlayerID: 1,
 			ID:       6,
+			Currency: "EUR",
 		}, nil).Once()
-		walletRepo.On("Credit", context.Background(), mock.Anything).Return(nil)
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.Anything).Return(nil)
 		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
 		service := NewWalletService(walletRepo, walletInMemoryDB)
-		err := service.Credit(context.Background(), id, amount)
+		_, err := service.Credit(context.Background(), id, amount, 1, domain.TransactionDetails{})
 		as.NoError(err)
 		walletRepo.AssertExpectations(t)
 		walletInMemoryDB.AssertExpectations(t)
@@ -41,7 +46,7 @@ func TestCredit(t *testing.T) {
 		amount := "-5000"
 		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{}, nil).Once()
 		service := NewWalletService(walletRepo, walletInMemoryDB)
-		err := service.Credit(context.Background(), id, amount)
+		_, err := service.Credit(context.Background(), id, amount, 1, domain.TransactionDetails{})
 		as.Error(err)
 		walletRepo.AssertExpectations(t)
 		walletInMemoryDB.AssertExpectations(t)
@@ -52,7 +57,7 @@ func TestCredit(t *testing.T) {
 		amount := "-5000AERA"
 		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{}, nil).Once()
 		service := NewWalletService(walletRepo, walletInMemoryDB)
-		err := service.Credit(context.Background(), id, amount)
+		_, err := service.Credit(context.Background(), id, amount, 1, domain.TransactionDetails{})
 		as.Error(err)
 		walletRepo.AssertExpectations(t)
 		walletInMemoryDB.AssertExpectations(t)
@@ -63,7 +68,7 @@ func TestCredit(t *testing.T) {
 		amount := "5000"
 		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{}, errors.New("something failed")).Once()
 		service := NewWalletService(walletRepo, walletInMemoryDB)
-		err := service.Credit(context.Background(), id, amount)
+		_, err := service.Credit(context.Background(), id, amount, 1, domain.TransactionDetails{})
 		as.Error(err)
 		walletRepo.AssertExpectations(t)
 		walletInMemoryDB.AssertExpectations(t)
@@ -83,11 +88,13 @@ func TestDebit(t *testing.T) {
 			Balance:  balance,
 			PlayerID: 1,
 			ID:       6,
+			Currency: "EUR",
 		}, nil).Once()
-		walletRepo.On("Debit", context.Background(), mock.Anything).Return(nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
 		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		walletRepo.On("ListLimits", context.Background(), mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil)
 		service := NewWalletService(walletRepo, walletInMemoryDB)
-		err := service.Debit(context.Background(), id, amount)
+		_, err := service.Debit(context.Background(), id, amount, 1, domain.TransactionDetails{})
 		as.NoError(err)
 		walletRepo.AssertExpectations(t)
 		walletInMemoryDB.AssertExpectations(t)
@@ -98,7 +105,7 @@ func TestDebit(t *testing.T) {
 		amount := "-5000"
 		walletRepo.On("Get", context.Background(), id, mock.Anything).Return(domain.Wallet{}, nil).Once()
 		service := NewWalletService(walletRepo, walletInMemoryDB)
-		err := service.Debit(context.Background(), id, amount)
+		_, err := service.Debit(context.Background(), id, amount, 1, domain.TransactionDetails{})
 		as.Error(err)
 		walletRepo.AssertExpectations(t)
 		walletInMemoryDB.AssertExpectations(t)
@@ -109,7 +116,7 @@ func TestDebit(t *testing.T) {
 		amount := "-5000AERA"
 		walletRepo.On("Get", context.Background(), id, mock.Anything).Return(domain.Wallet{}, nil).Once()
 		service := NewWalletService(walletRepo, walletInMemoryDB)
-		err := service.Debit(context.Background(), id, amount)
+		_, err := service.Debit(context.Background(), id, amount, 1, domain.TransactionDetails{})
 		as.Error(err)
 		walletRepo.AssertExpectations(t)
 		walletInMemoryDB.AssertExpectations(t)
@@ -123,9 +130,10 @@ func TestDebit(t *testing.T) {
 			Balance:  balance,
 			PlayerID: 1,
 			ID:       6,
+			Currency: "EUR",
 		}, nil).Once()
 		service := NewWalletService(walletRepo, walletInMemoryDB)
-		err := service.Debit(context.Background(), id, amount)
+		_, err := service.Debit(context.Background(), id, amount, 1, domain.TransactionDetails{})
 		as.Error(err)
 		walletRepo.AssertExpectations(t)
 		walletInMemoryDB.AssertExpectations(t)
@@ -136,9 +144,2210 @@ func TestDebit(t *testing.T) {
 		amount := "5000"
 		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{}, errors.New("something failed")).Once()
 		service := NewWalletService(walletRepo, walletInMemoryDB)
-		err := service.Debit(context.Background(), id, amount)
+		_, err := service.Debit(context.Background(), id, amount, 1, domain.TransactionDetails{})
 		as.Error(err)
 		walletRepo.AssertExpectations(t)
 		walletInMemoryDB.AssertExpectations(t)
 	})
 }
+
+func TestLedgerEntry(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletRepo := &repository.WalletRepositoryMock{}
+
+	t.Run("happy path: Credit appends a ledger entry with balances", func(t *testing.T) {
+		id := "6"
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			Balance:  decimal.NewFromInt(900),
+			PlayerID: 1,
+			ID:       6,
+			Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(1000))
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr.WalletID == 6 && tr.PlayerID == 1 && tr.Type == domain.TransactionTypeCredit &&
+				tr.Amount.Equal(decimal.NewFromInt(100)) &&
+				tr.BalanceBefore.Equal(decimal.NewFromInt(900)) &&
+				tr.BalanceAfter.Equal(decimal.NewFromInt(1000))
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		transaction, err := service.Credit(context.Background(), id, "100", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		as.True(transaction.BalanceAfter.Equal(decimal.NewFromInt(1000)))
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Debit appends a ledger entry with balances", func(t *testing.T) {
+		id := "6"
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			Balance:  decimal.NewFromInt(900),
+			PlayerID: 1,
+			ID:       6,
+			Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.Anything, mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr.Type == domain.TransactionTypeDebit &&
+				tr.BalanceBefore.Equal(decimal.NewFromInt(900)) &&
+				tr.BalanceAfter.Equal(decimal.NewFromInt(800))
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		walletRepo.On("ListLimits", context.Background(), mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil)
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Debit(context.Background(), id, "100", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+}
+
+func TestListTransactions(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletRepo := &repository.WalletRepositoryMock{}
+	id := "6"
+	walletInMemoryDB.On("Get", context.Background(), id).Return(domain.Wallet{ID: 6}, nil)
+
+	t.Run("happy path: Returns a cursor when more transactions exist", func(t *testing.T) {
+		walletRepo.On("ListTransactions", context.Background(), id, domain.TransactionFilter{Limit: 3}).Return([]domain.Transaction{
+			{ID: 9}, {ID: 8}, {ID: 7},
+		}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		transactions, nextCursor, err := service.ListTransactions(context.Background(), id, domain.TransactionFilter{Limit: 2})
+		as.NoError(err)
+		as.Len(transactions, 2)
+		as.Equal(8, nextCursor)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Last page has no cursor", func(t *testing.T) {
+		walletRepo.On("ListTransactions", context.Background(), id, domain.TransactionFilter{
+			Type:   domain.TransactionTypeDebit,
+			Cursor: 8,
+			Limit:  defaultTransactionPageSize + 1,
+		}).Return([]domain.Transaction{{ID: 7}}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		transactions, nextCursor, err := service.ListTransactions(context.Background(), id, domain.TransactionFilter{
+			Type:   domain.TransactionTypeDebit,
+			Cursor: 8,
+		})
+		as.NoError(err)
+		as.Len(transactions, 1)
+		as.Zero(nextCursor)
+		walletRepo.AssertExpectations(t)
+	})
+}
+
+func TestEditConflict(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletRepo := &repository.WalletRepositoryMock{}
+
+	t.Run("happy path: Retries against the fresh balance after a conflict", func(t *testing.T) {
+		id := "6"
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(900), Version: 1, Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Version == 1
+		}), mock.Anything).Return(domain.ErrEditConflict).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(500), Version: 2, Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Version == 2 && w.Balance.Equal(decimal.NewFromInt(400))
+		}), mock.Anything).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		walletRepo.On("ListLimits", context.Background(), mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil)
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		transaction, err := service.Debit(context.Background(), id, "100", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		as.True(transaction.BalanceBefore.Equal(decimal.NewFromInt(500)))
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+}
+
+// concurrentWalletRepository is an in-memory WalletRepository that enforces
+// the same optimistic version check as the MySQL implementation.
+type concurrentWalletRepository struct {
+	mu           sync.Mutex
+	wallet       domain.Wallet
+	transactions []domain.Transaction
+}
+
+func (r *concurrentWalletRepository) Create(ctx context.Context, w *domain.Wallet) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) CreateForPlayer(ctx context.Context, w *domain.Wallet, maxWallets int) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) ListByPlayer(ctx context.Context, playerID int) ([]domain.Wallet, error) {
+	return nil, nil
+}
+
+func (r *concurrentWalletRepository) SetDefault(ctx context.Context, w *domain.Wallet) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) SetLabel(ctx context.Context, w *domain.Wallet) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) Get(ctx context.Context, id string) (domain.Wallet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.wallet, nil
+}
+
+func (r *concurrentWalletRepository) save(w *domain.Wallet, t *domain.Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if w.Version != r.wallet.Version {
+		return domain.ErrEditConflict
+	}
+	w.Version++
+	r.wallet = *w
+	r.transactions = append(r.transactions, *t)
+	return nil
+}
+
+func (r *concurrentWalletRepository) Credit(ctx context.Context, w *domain.Wallet, t *domain.Transaction) error {
+	return r.save(w, t)
+}
+
+func (r *concurrentWalletRepository) Debit(ctx context.Context, w *domain.Wallet, t *domain.Transaction) error {
+	return r.save(w, t)
+}
+
+func (r *concurrentWalletRepository) ListTransactions(ctx context.Context, id string, filter domain.TransactionFilter) ([]domain.Transaction, error) {
+	return r.transactions, nil
+}
+
+func (r *concurrentWalletRepository) CreateTransfer(ctx context.Context, t *domain.Transfer) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) GetTransaction(ctx context.Context, id string) (domain.Transaction, error) {
+	return domain.Transaction{}, domain.ErrRecordNotFound
+}
+
+func (r *concurrentWalletRepository) ReversedAmount(ctx context.Context, transactionID int) (decimal.Decimal, error) {
+	return decimal.Zero, nil
+}
+
+func (r *concurrentWalletRepository) ListBonuses(ctx context.Context, walletID int, status domain.BonusStatus) ([]domain.Bonus, error) {
+	return nil, nil
+}
+
+func (r *concurrentWalletRepository) SaveBonus(ctx context.Context, b *domain.Bonus) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) CreateJackpot(ctx context.Context, p *domain.JackpotPool) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) GetHold(ctx context.Context, id string) (domain.Hold, error) {
+	return domain.Hold{}, domain.ErrRecordNotFound
+}
+
+func (r *concurrentWalletRepository) ListExpiredHolds(ctx context.Context, now time.Time, limit int) ([]domain.Hold, error) {
+	return nil, nil
+}
+
+func (r *concurrentWalletRepository) LockWallets(ctx context.Context, ids []int) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) LockPlayer(ctx context.Context, playerID int) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) CountActiveHolds(ctx context.Context, walletID int) (int, error) {
+	return 0, nil
+}
+
+func (r *concurrentWalletRepository) CreateHold(ctx context.Context, w *domain.Wallet, h *domain.Hold) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) SettleHold(ctx context.Context, w *domain.Wallet, h *domain.Hold, t *domain.Transaction) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) ListLimits(ctx context.Context, playerID int, currency string) ([]domain.Limit, error) {
+	return nil, nil
+}
+
+func (r *concurrentWalletRepository) SaveLimit(ctx context.Context, l *domain.Limit) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) SumActivity(ctx context.Context, playerID int, currency string, since time.Time) (domain.WalletActivity, error) {
+	return domain.WalletActivity{}, nil
+}
+
+func (r *concurrentWalletRepository) Update(ctx context.Context, w *domain.Wallet) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) UpdateStatus(ctx context.Context, w *domain.Wallet, change *domain.WalletStatusChange) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) ListWallets(ctx context.Context, afterID, limit int) ([]domain.Wallet, error) {
+	return nil, nil
+}
+
+func (r *concurrentWalletRepository) LedgerBalances(ctx context.Context, walletIDs []int) (map[int]decimal.Decimal, error) {
+	return nil, nil
+}
+
+func (r *concurrentWalletRepository) LatestSnapshot(ctx context.Context, walletID int, at time.Time) (domain.BalanceSnapshot, error) {
+	return domain.BalanceSnapshot{}, domain.ErrRecordNotFound
+}
+
+func (r *concurrentWalletRepository) CreateSnapshot(ctx context.Context, s *domain.BalanceSnapshot) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) SumTransactions(ctx context.Context, walletID int, from, to time.Time) (decimal.Decimal, int, error) {
+	return decimal.Zero, 0, nil
+}
+
+func (r *concurrentWalletRepository) StreamTransactions(ctx context.Context, walletID int, from, to time.Time, fn func(t domain.Transaction) error) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) Contribute(ctx context.Context, t *domain.Transaction) error {
+	return nil
+}
+
+func (r *concurrentWalletRepository) ListContributions(ctx context.Context, debitID int) ([]domain.Transaction, error) {
+	return nil, nil
+}
+
+func (r *concurrentWalletRepository) BookOpeningBalance(ctx context.Context, walletID int) (*domain.Transaction, error) {
+	return nil, nil
+}
+
+func (r *concurrentWalletRepository) JournalMissing(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+func (r *concurrentWalletRepository) Transaction(ctx context.Context, fn func(r domain.WalletRepository) error) error {
+	return fn(r)
+}
+
+// playerLimitRepository keeps wallets of one player in memory, with a wager
+// limit shared between them, and models the player lock of the MySQL
+// implementation: LockPlayer holds it until the surrounding Transaction ends.
+type playerLimitRepository struct {
+	*concurrentWalletRepository
+	player  sync.Mutex
+	wallets map[string]domain.Wallet
+	limit   domain.Limit
+}
+
+func (r *playerLimitRepository) Get(ctx context.Context, id string) (domain.Wallet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.wallets[id], nil
+}
+
+func (r *playerLimitRepository) Debit(ctx context.Context, w *domain.Wallet, t *domain.Transaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := strconv.Itoa(w.ID)
+	if w.Version != r.wallets[id].Version {
+		return domain.ErrEditConflict
+	}
+	w.Version++
+	r.wallets[id] = *w
+	r.transactions = append(r.transactions, *t)
+	return nil
+}
+
+func (r *playerLimitRepository) ListLimits(ctx context.Context, playerID int, currency string) ([]domain.Limit, error) {
+	return []domain.Limit{r.limit}, nil
+}
+
+func (r *playerLimitRepository) SumActivity(ctx context.Context, playerID int, currency string, since time.Time) (domain.WalletActivity, error) {
+	r.mu.Lock()
+	var activity domain.WalletActivity
+	for _, transaction := range r.transactions {
+		activity.Wagered = activity.Wagered.Add(transaction.Amount)
+	}
+	r.mu.Unlock()
+	// The pause stands in for the round trip to the database, which gives
+	// concurrent debits the time to read the same totals.
+	time.Sleep(time.Millisecond)
+	return activity, nil
+}
+
+func (r *playerLimitRepository) Transaction(ctx context.Context, fn func(r domain.WalletRepository) error) error {
+	tx := &playerLimitTransaction{playerLimitRepository: r}
+	defer func() {
+		if tx.locked {
+			r.player.Unlock()
+		}
+	}()
+	return fn(tx)
+}
+
+type playerLimitTransaction struct {
+	*playerLimitRepository
+	locked bool
+}
+
+func (t *playerLimitTransaction) LockPlayer(ctx context.Context, playerID int) error {
+	if !t.locked {
+		t.player.Lock()
+		t.locked = true
+	}
+	return nil
+}
+
+func (t *playerLimitTransaction) Transaction(ctx context.Context, fn func(r domain.WalletRepository) error) error {
+	return fn(t)
+}
+
+func TestConcurrentLimitedDebits(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletInMemoryDB.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	walletRepo := &playerLimitRepository{
+		concurrentWalletRepository: &concurrentWalletRepository{},
+		wallets: map[string]domain.Wallet{
+			"6": {ID: 6, PlayerID: 3, Balance: decimal.NewFromInt(1000), Version: 1, Currency: "EUR"},
+			"7": {ID: 7, PlayerID: 3, Balance: decimal.NewFromInt(1000), Version: 1, Currency: "EUR"},
+		},
+		limit: domain.Limit{PlayerID: 3, Currency: "EUR", Type: domain.LimitTypeWager, Period: domain.LimitPeriodDaily, Amount: decimal.NewNullDecimal(decimal.NewFromInt(100))},
+	}
+	service := NewWalletService(walletRepo, walletInMemoryDB)
+
+	// Debits alternate between the player's two wallets, whose versions do
+	// not protect each other; only the player lock keeps them under the
+	// shared limit.
+	const debits = 40
+	var wg sync.WaitGroup
+	errs := make(chan error, debits)
+	for i := 0; i < debits; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			_, err := service.Debit(context.Background(), id, "5", 1, domain.TransactionDetails{})
+			errs <- err
+		}([]string{"6", "7"}[i%2])
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded, refused := 0, 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, domain.ErrWagerLimitExceeded):
+			refused++
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	as.Equal(20, succeeded)
+	as.Equal(20, refused)
+	wagered, _ := walletRepo.SumActivity(context.Background(), 3, "EUR", time.Time{})
+	as.True(wagered.Wagered.Equal(decimal.NewFromInt(100)))
+}
+
+func TestConcurrentDebits(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletInMemoryDB.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	walletRepo := &concurrentWalletRepository{
+		wallet: domain.Wallet{ID: 6, Balance: decimal.NewFromInt(250), Version: 1, Currency: "EUR"},
+	}
+	service := NewWalletService(walletRepo, walletInMemoryDB)
+
+	const debits = 300
+	var wg sync.WaitGroup
+	errs := make(chan error, debits)
+	for i := 0; i < debits; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.Debit(context.Background(), "6", "1", 1, domain.TransactionDetails{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded, insufficient := 0, 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, domain.ErrInsufficientFunds):
+			insufficient++
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	as.Equal(250, succeeded)
+	as.Equal(50, insufficient)
+	as.True(walletRepo.wallet.Balance.IsZero())
+	as.Len(walletRepo.transactions, 250)
+	for i, transaction := range walletRepo.transactions {
+		as.True(transaction.BalanceBefore.Equal(decimal.NewFromInt(int64(250 - i))))
+		as.True(transaction.BalanceAfter.Equal(decimal.NewFromInt(int64(249 - i))))
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletRepo := &repository.WalletRepositoryMock{}
+
+	t.Run("happy path: Debits and credits both wallets in one transaction", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), "7").Return(domain.Wallet{
+			ID: 7, PlayerID: 1, Balance: decimal.NewFromInt(900), Version: 1, Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), "3").Return(domain.Wallet{
+			ID: 3, Balance: decimal.NewFromInt(100), Version: 1, Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("CreateTransfer", context.Background(), mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Transfer).ID = 11
+		}).Return(nil).Once()
+		var order []int
+		walletRepo.On("Credit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.ID == 3 && w.Balance.Equal(decimal.NewFromInt(400))
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return *tr.TransferID == 11
+		})).Run(func(args mock.Arguments) {
+			order = append(order, 3)
+		}).Return(nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.ID == 7 && w.Balance.Equal(decimal.NewFromInt(600))
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return *tr.TransferID == 11
+		})).Run(func(args mock.Arguments) {
+			order = append(order, 7)
+		}).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "7").Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "3").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		transfer, err := service.Transfer(context.Background(), "7", "3", "300", 1)
+		as.NoError(err)
+		as.Equal(11, transfer.ID)
+		as.Equal([]int{3, 7}, order)
+		as.True(transfer.Debit.BalanceAfter.Equal(decimal.NewFromInt(600)))
+		as.True(transfer.Credit.BalanceAfter.Equal(decimal.NewFromInt(400)))
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("input error: Insufficient funds writes nothing", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), "7").Return(domain.Wallet{
+			ID: 7, PlayerID: 1, Balance: decimal.NewFromInt(100), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), "3").Return(domain.Wallet{ID: 3, Currency: "EUR"}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Transfer(context.Background(), "7", "3", "300", 1)
+		as.ErrorIs(err, domain.ErrInsufficientFunds)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: Player B cannot debit player A's wallet", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "7").Return(domain.Wallet{
+			ID: 7, PlayerID: 1, Balance: decimal.NewFromInt(900), Currency: "EUR",
+		}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Transfer(context.Background(), "7", "3", "300", 2)
+		as.ErrorIs(err, domain.ErrRecordNotFound)
+		walletRepo.AssertExpectations(t)
+		walletRepo.AssertNotCalled(t, "Transaction", mock.Anything)
+		walletRepo.AssertNotCalled(t, "Debit", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: Same wallet", func(t *testing.T) {
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Transfer(context.Background(), "7", "7", "300", 1)
+		as.ErrorIs(err, domain.ErrSameWallet)
+	})
+
+	t.Run("input error: Non positive amount", func(t *testing.T) {
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Transfer(context.Background(), "7", "3", "0", 1)
+		as.ErrorIs(err, domain.ErrInvalidAmount)
+	})
+}
+
+func TestCurrency(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletRepo := &repository.WalletRepositoryMock{}
+
+	t.Run("happy path: Create defaults to euro and normalises the code", func(t *testing.T) {
+		walletRepo.On("CreateForPlayer", context.Background(), mock.Anything, 0).Return(nil).Twice()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		wallet := domain.Wallet{PlayerID: 1}
+		as.NoError(service.Create(context.Background(), &wallet))
+		as.Equal("EUR", wallet.Currency)
+		wallet = domain.Wallet{PlayerID: 1, Currency: "sek"}
+		as.NoError(service.Create(context.Background(), &wallet))
+		as.Equal("SEK", wallet.Currency)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: Create rejects an unknown currency", func(t *testing.T) {
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		err := service.Create(context.Background(), &domain.Wallet{Currency: "XYZ"})
+		as.ErrorIs(err, domain.ErrUnsupportedCurrency)
+	})
+
+	t.Run("input error: Amount more precise than the currency", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(900), Currency: "GBP",
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), "8").Return(domain.Wallet{
+			ID: 8, Balance: decimal.NewFromInt(900), Currency: "JPY",
+		}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Credit(context.Background(), "6", "10.001", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrAmountPrecision)
+		_, err = service.Debit(context.Background(), "8", "10.5", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrAmountPrecision)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: Transfer between currencies", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{
+			ID: 6, PlayerID: 1, Balance: decimal.NewFromInt(900), Currency: "GBP",
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), "8").Return(domain.Wallet{
+			ID: 8, Balance: decimal.NewFromInt(900), Currency: "SEK",
+		}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Transfer(context.Background(), "6", "8", "10", 1)
+		as.ErrorIs(err, domain.ErrCurrencyMismatch)
+		walletRepo.AssertExpectations(t)
+	})
+}
+
+func TestCrossCurrencyTransfer(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletRepo := &repository.WalletRepositoryMock{}
+	rateProvider := &repository.RateProviderMock{}
+	spread, _ := decimal.NewFromString("0.01")
+
+	t.Run("happy path: Converts at the rate less the spread and records it on both legs", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{
+			ID: 6, PlayerID: 1, Balance: decimal.NewFromInt(900), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), "8").Return(domain.Wallet{
+			ID: 8, Balance: decimal.NewFromInt(0), Currency: "SEK",
+		}, nil).Once()
+		rate, _ := decimal.NewFromString("11.4567")
+		rateProvider.On("Rate", context.Background(), "EUR", "SEK").Return(domain.ExchangeRate{
+			Base: "EUR", Quote: "SEK", Rate: rate,
+		}, nil).Once()
+		// 10.00 EUR * 11.4567 * 0.99 = 113.42133 SEK
+		expected, _ := decimal.NewFromString("113.42")
+		applied, _ := decimal.NewFromString("11.342133")
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("CreateTransfer", context.Background(), mock.Anything).Return(nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.Anything, mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr.Amount.Equal(decimal.NewFromInt(10)) && tr.ExchangeRate.Equal(applied)
+		})).Return(nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(expected)
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr.Amount.Equal(expected) && tr.ExchangeRate.Equal(applied)
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), mock.Anything).Return(nil).Twice()
+		service := NewWalletService(walletRepo, walletInMemoryDB, WithRateProvider(rateProvider, domain.ConversionConfig{
+			Spread: spread,
+		}))
+		transfer, err := service.Transfer(context.Background(), "6", "8", "10", 1)
+		as.NoError(err)
+		as.True(transfer.ToAmount.Equal(expected))
+		walletRepo.AssertExpectations(t)
+		rateProvider.AssertExpectations(t)
+	})
+
+	t.Run("input error: Missing rate", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{
+			ID: 6, PlayerID: 1, Balance: decimal.NewFromInt(900), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), "8").Return(domain.Wallet{ID: 8, Currency: "JPY"}, nil).Once()
+		rateProvider.On("Rate", context.Background(), "EUR", "JPY").Return(domain.ExchangeRate{}, domain.ErrRateNotFound).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB, WithRateProvider(rateProvider, domain.ConversionConfig{}))
+		_, err := service.Transfer(context.Background(), "6", "8", "10", 1)
+		as.ErrorIs(err, domain.ErrRateNotFound)
+		walletRepo.AssertExpectations(t)
+	})
+}
+
+func TestConversionRounding(t *testing.T) {
+	as := assert.New(t)
+	amount, _ := decimal.NewFromString("2.345")
+	as.Equal("2.34", domain.ConversionConfig{}.Round(amount, 2).String())
+	as.Equal("2.35", domain.ConversionConfig{Rounding: domain.RoundingHalfUp}.Round(amount, 2).String())
+	as.Equal("2.34", domain.ConversionConfig{Rounding: domain.RoundingDown}.Round(amount, 2).String())
+}
+
+func TestHolds(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletRepo := &repository.WalletRepositoryMock{}
+	id := "6"
+
+	t.Run("happy path: Hold reserves funds without changing the balance", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(900), HeldBalance: decimal.NewFromInt(100), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), 0, "EUR").Return([]domain.Limit(nil), nil).Once()
+		walletRepo.On("CreateHold", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(900)) && w.HeldBalance.Equal(decimal.NewFromInt(400))
+		}), mock.MatchedBy(func(h *domain.Hold) bool {
+			return h.Status == domain.HoldStatusActive && h.ExpiresAt.After(time.Now().Add(time.Minute))
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB, WithHoldTTL(time.Hour))
+		hold, err := service.Hold(context.Background(), id, "300", 1)
+		as.NoError(err)
+		as.True(hold.Amount.Equal(decimal.NewFromInt(300)))
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("input error: Hold above the available balance", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(900), HeldBalance: decimal.NewFromInt(700), Currency: "EUR",
+		}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Hold(context.Background(), id, "300", 1)
+		as.ErrorIs(err, domain.ErrInsufficientFunds)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: Debit cannot spend held funds", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(900), HeldBalance: decimal.NewFromInt(700), Currency: "EUR",
+		}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Debit(context.Background(), id, "300", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrInsufficientFunds)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Partial capture debits and releases the rest", func(t *testing.T) {
+		walletRepo.On("GetHold", context.Background(), "4").Return(domain.Hold{
+			ID: 4, WalletID: 6, Amount: decimal.NewFromInt(300), Status: domain.HoldStatusActive,
+			ExpiresAt: time.Now().Add(time.Minute),
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(900), HeldBalance: decimal.NewFromInt(300), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("SettleHold", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(700)) && w.HeldBalance.IsZero()
+		}), mock.MatchedBy(func(h *domain.Hold) bool {
+			return h.Status == domain.HoldStatusCaptured && h.CapturedAmount.Equal(decimal.NewFromInt(200))
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr != nil && *tr.HoldID == 4 && tr.Amount.Equal(decimal.NewFromInt(200))
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		transaction, err := service.CaptureHold(context.Background(), id, "4", "200", 1)
+		as.NoError(err)
+		as.True(transaction.BalanceAfter.Equal(decimal.NewFromInt(700)))
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("input error: Capture more than the hold", func(t *testing.T) {
+		walletRepo.On("GetHold", context.Background(), "4").Return(domain.Hold{
+			ID: 4, WalletID: 6, Amount: decimal.NewFromInt(300), Status: domain.HoldStatusActive,
+			ExpiresAt: time.Now().Add(time.Minute),
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(900), HeldBalance: decimal.NewFromInt(300), Currency: "EUR",
+		}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.CaptureHold(context.Background(), id, "4", "301", 1)
+		as.ErrorIs(err, domain.ErrInvalidAmount)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: Expired or settled holds cannot be captured", func(t *testing.T) {
+		walletRepo.On("GetHold", context.Background(), "4").Return(domain.Hold{
+			ID: 4, WalletID: 6, Amount: decimal.NewFromInt(300), Status: domain.HoldStatusActive,
+			ExpiresAt: time.Now().Add(-time.Minute),
+		}, nil).Once()
+		walletRepo.On("GetHold", context.Background(), "5").Return(domain.Hold{
+			ID: 5, WalletID: 6, Status: domain.HoldStatusVoided,
+		}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.CaptureHold(context.Background(), id, "4", "", 1)
+		as.ErrorIs(err, domain.ErrHoldExpired)
+		_, err = service.CaptureHold(context.Background(), id, "5", "", 1)
+		as.ErrorIs(err, domain.ErrHoldNotActive)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Void releases the hold", func(t *testing.T) {
+		walletRepo.On("GetHold", context.Background(), "4").Return(domain.Hold{
+			ID: 4, WalletID: 6, Amount: decimal.NewFromInt(300), Status: domain.HoldStatusActive,
+			ExpiresAt: time.Now().Add(time.Minute),
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(900), HeldBalance: decimal.NewFromInt(300), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("SettleHold", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(900)) && w.HeldBalance.IsZero()
+		}), mock.MatchedBy(func(h *domain.Hold) bool {
+			return h.Status == domain.HoldStatusVoided
+		}), (*domain.Transaction)(nil)).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		hold, err := service.VoidHold(context.Background(), id, "4")
+		as.NoError(err)
+		as.Equal(domain.HoldStatusVoided, hold.Status)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Expired holds are released", func(t *testing.T) {
+		walletRepo.On("ListExpiredHolds", context.Background(), mock.Anything, expiredHoldsBatchSize).Return([]domain.Hold{
+			{ID: 4, WalletID: 6, Amount: decimal.NewFromInt(300), Status: domain.HoldStatusActive},
+			{ID: 5, WalletID: 6, Amount: decimal.NewFromInt(100), Status: domain.HoldStatusActive},
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(900), HeldBalance: decimal.NewFromInt(400), Currency: "EUR",
+		}, nil).Twice()
+		walletRepo.On("SettleHold", context.Background(), mock.Anything, mock.MatchedBy(func(h *domain.Hold) bool {
+			return h.ID == 4 && h.Status == domain.HoldStatusExpired
+		}), (*domain.Transaction)(nil)).Return(nil).Once()
+		walletRepo.On("SettleHold", context.Background(), mock.Anything, mock.MatchedBy(func(h *domain.Hold) bool {
+			return h.ID == 5
+		}), (*domain.Transaction)(nil)).Return(domain.ErrHoldNotActive).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		expired, err := service.ExpireHolds(context.Background())
+		as.NoError(err)
+		as.Equal(1, expired)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+}
+
+func TestReverse(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletRepo := &repository.WalletRepositoryMock{}
+	id := "6"
+
+	t.Run("happy path: Partially refunds a debit", func(t *testing.T) {
+		walletRepo.On("GetTransaction", context.Background(), "20").Return(domain.Transaction{
+			ID: 20, WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(300),
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(600), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ReversedAmount", context.Background(), 20).Return(decimal.NewFromInt(100), nil).Once()
+		walletRepo.On("ListBonuses", context.Background(), 6, domain.BonusStatus("")).Return([]domain.Bonus{}, nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(750))
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return *tr.ReversalOfID == 20 && tr.Type == domain.TransactionTypeCredit
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		transaction, err := service.Reverse(context.Background(), "20", "150", 2)
+		as.NoError(err)
+		as.True(transaction.Amount.Equal(decimal.NewFromInt(150)))
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Refunding a stake takes back its bonus wagering", func(t *testing.T) {
+		walletRepo.On("GetTransaction", context.Background(), "24").Return(domain.Transaction{
+			ID: 24, WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(130),
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(200), BonusBalance: decimal.NewFromInt(50), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ReversedAmount", context.Background(), 24).Return(decimal.Zero, nil).Once()
+		walletRepo.On("ListBonuses", context.Background(), 6, domain.BonusStatus("")).Return([]domain.Bonus{{
+			ID: 1, WalletID: 6, Amount: decimal.NewFromInt(50), WageringRequired: decimal.NewFromInt(500),
+			Wagered: decimal.NewFromInt(60), Remaining: decimal.NewFromInt(50), Status: domain.BonusStatusActive,
+		}, {
+			ID: 2, WalletID: 6, Amount: decimal.NewFromInt(20), WageringRequired: decimal.NewFromInt(100),
+			Wagered: decimal.NewFromInt(100), Converted: decimal.NewFromInt(20), Status: domain.BonusStatusCompleted,
+		}}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(330)) && w.BonusBalance.Equal(decimal.NewFromInt(70))
+		}), mock.Anything).Return(nil).Once()
+		walletRepo.On("SaveBonus", context.Background(), mock.MatchedBy(func(b *domain.Bonus) bool {
+			return b.ID == 2 && b.Wagered.IsZero() && b.Remaining.Equal(decimal.NewFromInt(20)) && b.Status == domain.BonusStatusActive
+		})).Return(nil).Once()
+		walletRepo.On("SaveBonus", context.Background(), mock.MatchedBy(func(b *domain.Bonus) bool {
+			return b.ID == 1 && b.Wagered.Equal(decimal.NewFromInt(30)) && b.Status == domain.BonusStatusActive
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Reverse(context.Background(), "24", "", 2)
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Reverses the remainder of a credit by default", func(t *testing.T) {
+		walletRepo.On("GetTransaction", context.Background(), "21").Return(domain.Transaction{
+			ID: 21, WalletID: 6, Type: domain.TransactionTypeCredit, Amount: decimal.NewFromInt(300),
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(600), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ReversedAmount", context.Background(), 21).Return(decimal.NewFromInt(100), nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(400))
+		}), mock.Anything).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		transaction, err := service.Reverse(context.Background(), "21", "", 2)
+		as.NoError(err)
+		as.Equal(domain.TransactionTypeDebit, transaction.Type)
+		as.True(transaction.Amount.Equal(decimal.NewFromInt(200)))
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: Already fully reversed", func(t *testing.T) {
+		walletRepo.On("GetTransaction", context.Background(), "20").Return(domain.Transaction{
+			ID: 20, WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(300),
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(600), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ReversedAmount", context.Background(), 20).Return(decimal.NewFromInt(300), nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Reverse(context.Background(), "20", "", 2)
+		as.ErrorIs(err, domain.ErrAlreadyReversed)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: Refund above the remaining amount", func(t *testing.T) {
+		walletRepo.On("GetTransaction", context.Background(), "20").Return(domain.Transaction{
+			ID: 20, WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(300),
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(600), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ReversedAmount", context.Background(), 20).Return(decimal.NewFromInt(100), nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Reverse(context.Background(), "20", "201", 2)
+		as.ErrorIs(err, domain.ErrInvalidAmount)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: Reversals and transfer legs are not reversible", func(t *testing.T) {
+		original := 20
+		walletRepo.On("GetTransaction", context.Background(), "22").Return(domain.Transaction{
+			ID: 22, WalletID: 6, ReversalOfID: &original,
+		}, nil).Once()
+		walletRepo.On("GetTransaction", context.Background(), "23").Return(domain.Transaction{
+			ID: 23, WalletID: 6, TransferID: &original,
+		}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Reverse(context.Background(), "22", "", 2)
+		as.ErrorIs(err, domain.ErrTransactionNotReversible)
+		_, err = service.Reverse(context.Background(), "23", "", 2)
+		as.ErrorIs(err, domain.ErrTransactionNotReversible)
+		walletRepo.AssertExpectations(t)
+	})
+}
+
+func TestRefund(t *testing.T) {
+	as := assert.New(t)
+	id := "6"
+	bookedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("happy path: Refunding a bonus-funded debit gives the bonus funds back", func(t *testing.T) {
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("GetTransaction", context.Background(), "30").Return(domain.Transaction{
+			ID: 30, WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(30),
+			BonusAmount: decimal.NewFromInt(20), CreatedAt: bookedAt,
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(50), BonusBalance: decimal.NewFromInt(7), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ReversedAmount", context.Background(), 30).Return(decimal.Zero, nil).Once()
+		walletRepo.On("ListBonuses", context.Background(), 6, domain.BonusStatus("")).Return([]domain.Bonus{{
+			ID: 1, WalletID: 6, Amount: decimal.NewFromInt(15), Remaining: decimal.Zero, WageringRequired: decimal.NewFromInt(150),
+			Wagered: decimal.NewFromInt(40), Status: domain.BonusStatusSpent, CreatedAt: bookedAt.Add(-time.Hour),
+		}, {
+			ID: 2, WalletID: 6, Amount: decimal.NewFromInt(10), Remaining: decimal.NewFromInt(5), WageringRequired: decimal.NewFromInt(100),
+			Wagered: decimal.NewFromInt(10), Status: domain.BonusStatusActive, CreatedAt: bookedAt.Add(-time.Minute),
+		}, {
+			ID: 3, WalletID: 6, Amount: decimal.NewFromInt(10), Remaining: decimal.NewFromInt(2), WageringRequired: decimal.NewFromInt(100),
+			Wagered: decimal.NewFromInt(8), Status: domain.BonusStatusActive, CreatedAt: bookedAt.Add(time.Hour),
+		}}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(80)) && w.BonusBalance.Equal(decimal.NewFromInt(27))
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return *tr.ReversalOfID == 30 && tr.BonusAmount.Equal(decimal.NewFromInt(20)) && tr.Reference == "tx-9"
+		})).Return(nil).Once()
+		walletRepo.On("SaveBonus", context.Background(), mock.MatchedBy(func(b *domain.Bonus) bool {
+			return b.ID == 1 && b.Remaining.Equal(decimal.NewFromInt(15)) && b.Wagered.Equal(decimal.NewFromInt(40)) && b.Status == domain.BonusStatusActive
+		})).Return(nil).Once()
+		walletRepo.On("SaveBonus", context.Background(), mock.MatchedBy(func(b *domain.Bonus) bool {
+			return b.ID == 2 && b.Remaining.Equal(decimal.NewFromInt(10)) && b.Wagered.IsZero()
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		transaction, err := service.Refund(context.Background(), "30", 0, domain.TransactionDetails{Reference: "tx-9"})
+		as.NoError(err)
+		as.True(transaction.Amount.Equal(decimal.NewFromInt(30)))
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Bonus funds a completed bonus cannot take back are refunded as cash", func(t *testing.T) {
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("GetTransaction", context.Background(), "31").Return(domain.Transaction{
+			ID: 31, WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(10),
+			BonusAmount: decimal.NewFromInt(10), CreatedAt: bookedAt,
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(50), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ReversedAmount", context.Background(), 31).Return(decimal.Zero, nil).Once()
+		walletRepo.On("ListBonuses", context.Background(), 6, domain.BonusStatus("")).Return([]domain.Bonus{{
+			ID: 1, WalletID: 6, Amount: decimal.NewFromInt(20), WageringRequired: decimal.NewFromInt(100),
+			Wagered: decimal.NewFromInt(200), Converted: decimal.NewFromInt(10), Status: domain.BonusStatusCompleted, CreatedAt: bookedAt.Add(-time.Hour),
+		}}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(60)) && w.BonusBalance.IsZero()
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr.BonusAmount.IsZero()
+		})).Return(nil).Once()
+		walletRepo.On("SaveBonus", context.Background(), mock.MatchedBy(func(b *domain.Bonus) bool {
+			return b.ID == 1 && b.Wagered.Equal(decimal.NewFromInt(190)) && b.Status == domain.BonusStatusCompleted
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Refund(context.Background(), "31", 0, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: Only debits can be refunded", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("GetTransaction", context.Background(), "32").Return(domain.Transaction{
+			ID: 32, WalletID: 6, Type: domain.TransactionTypeCredit, Amount: decimal.NewFromInt(10),
+		}, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Refund(context.Background(), "32", 0, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrTransactionNotReversible)
+	})
+}
+
+func TestBonus(t *testing.T) {
+	as := assert.New(t)
+	walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+	walletRepo := &repository.WalletRepositoryMock{}
+	id := "6"
+
+	t.Run("happy path: Bonus credit adds locked bonus funds", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(100), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("SaveBonus", context.Background(), mock.MatchedBy(func(b *domain.Bonus) bool {
+			return b.WageringRequired.Equal(decimal.NewFromInt(500)) && b.Remaining.Equal(decimal.NewFromInt(50))
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Bonus).ID = 3
+		}).Return(nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(150)) && w.BonusBalance.Equal(decimal.NewFromInt(50))
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return *tr.BonusID == 3 && tr.BonusAmount.Equal(decimal.NewFromInt(50))
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		bonus, err := service.CreditBonus(context.Background(), id, "50", "10", 1)
+		as.NoError(err)
+		as.Equal(3, bonus.ID)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("input error: Wagering multiplier must be positive", func(t *testing.T) {
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		for _, multiplier := range []string{"0", "-1", "x"} {
+			_, err := service.CreditBonus(context.Background(), id, "50", multiplier, 1)
+			as.ErrorIs(err, domain.ErrInvalidAmount)
+		}
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Cash first debit spends cash before bonus and counts the wager", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(150), BonusBalance: decimal.NewFromInt(50), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ListBonuses", context.Background(), 6, domain.BonusStatusActive).Return([]domain.Bonus{{
+			ID: 3, Amount: decimal.NewFromInt(50), Remaining: decimal.NewFromInt(50),
+			WageringRequired: decimal.NewFromInt(500), Wagered: decimal.Zero, Status: domain.BonusStatusActive,
+		}}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(30)) && w.BonusBalance.Equal(decimal.NewFromInt(30))
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr.BonusAmount.Equal(decimal.NewFromInt(20))
+		})).Return(nil).Once()
+		walletRepo.On("SaveBonus", context.Background(), mock.MatchedBy(func(b *domain.Bonus) bool {
+			return b.Remaining.Equal(decimal.NewFromInt(30)) && b.Wagered.Equal(decimal.NewFromInt(120))
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		walletRepo.On("ListLimits", context.Background(), mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil)
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Debit(context.Background(), id, "120", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Meeting the wagering requirement converts the bonus into cash", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(150), BonusBalance: decimal.NewFromInt(50), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ListBonuses", context.Background(), 6, domain.BonusStatusActive).Return([]domain.Bonus{{
+			ID: 3, Amount: decimal.NewFromInt(50), Remaining: decimal.NewFromInt(50),
+			WageringRequired: decimal.NewFromInt(500), Wagered: decimal.NewFromInt(480), Status: domain.BonusStatusActive,
+		}}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(110)) && w.BonusBalance.IsZero()
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr.BonusAmount.IsZero()
+		})).Return(nil).Once()
+		walletRepo.On("SaveBonus", context.Background(), mock.MatchedBy(func(b *domain.Bonus) bool {
+			return b.Status == domain.BonusStatusCompleted && b.Remaining.IsZero() && b.WageringProgress().Equal(decimal.NewFromInt(1))
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Debit(context.Background(), id, "40", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("happy path: Bonus first debit spends bonus funds until they run out", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, Balance: decimal.NewFromInt(150), BonusBalance: decimal.NewFromInt(50), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("ListBonuses", context.Background(), 6, domain.BonusStatusActive).Return([]domain.Bonus{{
+			ID: 3, Amount: decimal.NewFromInt(50), Remaining: decimal.NewFromInt(50),
+			WageringRequired: decimal.NewFromInt(500), Wagered: decimal.Zero, Status: domain.BonusStatusActive,
+		}}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w.Balance.Equal(decimal.NewFromInt(90)) && w.BonusBalance.IsZero()
+		}), mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr.BonusAmount.Equal(decimal.NewFromInt(50))
+		})).Return(nil).Once()
+		walletRepo.On("SaveBonus", context.Background(), mock.MatchedBy(func(b *domain.Bonus) bool {
+			return b.Status == domain.BonusStatusSpent
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), id).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB, WithBonusSpendOrder(domain.BonusSpendBonusFirst))
+		_, err := service.Debit(context.Background(), id, "60", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("input error: Bonus funds cannot be transferred out", func(t *testing.T) {
+		walletRepo.On("Get", context.Background(), id).Return(domain.Wallet{
+			ID: 6, PlayerID: 1, Balance: decimal.NewFromInt(150), BonusBalance: decimal.NewFromInt(50), Currency: "EUR",
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), "8").Return(domain.Wallet{ID: 8, Currency: "EUR"}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Transfer(context.Background(), id, "8", "120", 1)
+		as.ErrorIs(err, domain.ErrInsufficientFunds)
+		walletRepo.AssertExpectations(t)
+	})
+}
+
+func TestReconcile(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("happy path: reports ledger drift and repairs a stale cache entry", func(t *testing.T) {
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("ListWallets", context.Background(), 0, walletBatchSize).Return([]domain.Wallet{
+			{ID: 1, Balance: decimal.NewFromInt(100), Currency: "EUR"},
+			{ID: 2, Balance: decimal.NewFromInt(50), Currency: "EUR"},
+			{ID: 3, Balance: decimal.NewFromInt(70), Currency: "EUR"},
+			{ID: 4, Balance: decimal.Zero, Currency: "EUR"},
+		}, nil).Once()
+		walletRepo.On("LedgerBalances", context.Background(), []int{1, 2, 3, 4}).Return(map[int]decimal.Decimal{
+			1: decimal.NewFromInt(100),
+			2: decimal.NewFromInt(40),
+			3: decimal.NewFromInt(70),
+		}, nil).Once()
+		walletInMemoryDB.On("Get", context.Background(), "1").Return(domain.Wallet{ID: 1, Balance: decimal.NewFromInt(100)}, nil).Once()
+		walletInMemoryDB.On("Get", context.Background(), "2").Return(domain.Wallet{}, domain.ErrKeyNotFound).Once()
+		walletInMemoryDB.On("Get", context.Background(), "3").Return(domain.Wallet{ID: 3, Balance: decimal.NewFromInt(90)}, nil).Once()
+		walletInMemoryDB.On("Get", context.Background(), "4").Return(domain.Wallet{}, domain.ErrKeyNotFound).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "3").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		report, err := service.Reconcile(context.Background(), true)
+		as.NoError(err)
+		as.Equal(4, report.WalletsChecked)
+		as.Len(report.Mismatches, 2)
+		as.Equal(2, report.Mismatches[0].WalletID)
+		as.True(report.Mismatches[0].LedgerDrift)
+		as.False(report.Mismatches[0].CacheDrift)
+		as.Equal(3, report.Mismatches[1].WalletID)
+		as.False(report.Mismatches[1].LedgerDrift)
+		as.True(report.Mismatches[1].CacheDrift)
+		as.True(report.Mismatches[1].CacheRepaired)
+		as.False(report.Consistent())
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("happy path: stale cache entries are left alone without repair", func(t *testing.T) {
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("ListWallets", context.Background(), 0, walletBatchSize).Return([]domain.Wallet{
+			{ID: 3, Balance: decimal.NewFromInt(70), Currency: "EUR"},
+		}, nil).Once()
+		walletRepo.On("LedgerBalances", context.Background(), []int{3}).Return(map[int]decimal.Decimal{
+			3: decimal.NewFromInt(70),
+		}, nil).Once()
+		walletInMemoryDB.On("Get", context.Background(), "3").Return(domain.Wallet{ID: 3, Balance: decimal.NewFromInt(90)}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		report, err := service.Reconcile(context.Background(), false)
+		as.NoError(err)
+		as.Len(report.Mismatches, 1)
+		as.False(report.Mismatches[0].CacheRepaired)
+		as.False(report.Consistent())
+		walletInMemoryDB.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: repository failure aborts the run", func(t *testing.T) {
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("ListWallets", context.Background(), 0, walletBatchSize).Return([]domain.Wallet(nil), errors.New("connection refused")).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Reconcile(context.Background(), true)
+		as.Error(err)
+	})
+}
+
+func TestOpenBalances(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("happy path: books opening entries and journals old entries", func(t *testing.T) {
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("ListWallets", context.Background(), 0, walletBatchSize).Return([]domain.Wallet{{ID: 1}, {ID: 2}}, nil).Once()
+		walletRepo.On("BookOpeningBalance", context.Background(), 1).Return(&domain.Transaction{
+			WalletID: 1, Amount: decimal.NewFromInt(100), Category: domain.CategoryOpeningBalance,
+		}, nil).Once()
+		walletRepo.On("BookOpeningBalance", context.Background(), 2).Return((*domain.Transaction)(nil), nil).Once()
+		walletRepo.On("JournalMissing", context.Background(), walletBatchSize).Return(walletBatchSize, nil).Once()
+		walletRepo.On("JournalMissing", context.Background(), walletBatchSize).Return(3, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		opened, err := service.OpenBalances(context.Background())
+		as.NoError(err)
+		as.Equal(1, opened)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: repository failure aborts the run", func(t *testing.T) {
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("ListWallets", context.Background(), 0, walletBatchSize).Return([]domain.Wallet{{ID: 1}}, nil).Once()
+		walletRepo.On("BookOpeningBalance", context.Background(), 1).Return((*domain.Transaction)(nil), errors.New("connection refused")).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.OpenBalances(context.Background())
+		as.Error(err)
+		walletRepo.AssertExpectations(t)
+	})
+}
+
+func TestBalanceAt(t *testing.T) {
+	as := assert.New(t)
+	at := time.Now().Add(-time.Hour)
+
+	t.Run("happy path: adds the transactions after the latest snapshot", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		asOf := at.Add(-24 * time.Hour)
+		walletRepo.On("LatestSnapshot", context.Background(), 6, at).Return(domain.BalanceSnapshot{WalletID: 6, Balance: decimal.NewFromInt(500), AsOf: asOf}, nil).Once()
+		walletRepo.On("SumTransactions", context.Background(), 6, asOf, at).Return(decimal.NewFromInt(-120), 3, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		balance, err := service.BalanceAt(context.Background(), 6, at)
+		as.NoError(err)
+		as.True(balance.Equal(decimal.NewFromInt(380)))
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: sums the whole history without a snapshot", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("LatestSnapshot", context.Background(), 6, at).Return(domain.BalanceSnapshot{}, domain.ErrRecordNotFound).Once()
+		walletRepo.On("SumTransactions", context.Background(), 6, time.Time{}, at).Return(decimal.NewFromInt(75), 2, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		balance, err := service.BalanceAt(context.Background(), 6, at)
+		as.NoError(err)
+		as.True(balance.Equal(decimal.NewFromInt(75)))
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: moments in the future are rejected", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.BalanceAt(context.Background(), 6, time.Now().Add(time.Hour))
+		as.ErrorIs(err, domain.ErrBalanceTimeInFuture)
+	})
+}
+
+func TestTakeSnapshots(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("happy path: snapshots only wallets that moved since their last snapshot", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		previous := time.Now().Add(-48 * time.Hour)
+		walletRepo.On("ListWallets", context.Background(), 0, walletBatchSize).Return([]domain.Wallet{{ID: 1}, {ID: 2}}, nil).Once()
+		walletRepo.On("LatestSnapshot", context.Background(), 1, mock.Anything).Return(domain.BalanceSnapshot{WalletID: 1, Balance: decimal.NewFromInt(100), AsOf: previous}, nil).Once()
+		walletRepo.On("SumTransactions", context.Background(), 1, previous, mock.Anything).Return(decimal.NewFromInt(25), 4, nil).Once()
+		walletRepo.On("LatestSnapshot", context.Background(), 2, mock.Anything).Return(domain.BalanceSnapshot{WalletID: 2, Balance: decimal.NewFromInt(10), AsOf: previous}, nil).Once()
+		walletRepo.On("SumTransactions", context.Background(), 2, previous, mock.Anything).Return(decimal.Zero, 0, nil).Once()
+		walletRepo.On("CreateSnapshot", context.Background(), mock.MatchedBy(func(s *domain.BalanceSnapshot) bool {
+			return s != nil && s.WalletID == 1 && s.Balance.Equal(decimal.NewFromInt(125)) && s.AsOf.Before(time.Now().Add(-snapshotSettleDelay/2))
+		})).Return(nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		taken, err := service.TakeSnapshots(context.Background())
+		as.NoError(err)
+		as.Equal(1, taken)
+		walletRepo.AssertExpectations(t)
+	})
+}
+
+func TestWalletStatus(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("happy path: a frozen wallet accepts credits", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, Balance: decimal.NewFromInt(10), Currency: "EUR", Status: domain.WalletStatusFrozen}, nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Credit(context.Background(), "6", "5", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: a frozen wallet refuses debits and holds", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, Balance: decimal.NewFromInt(10), Currency: "EUR", Status: domain.WalletStatusFrozen}, nil).Twice()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Debit(context.Background(), "6", "5", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrWalletFrozen)
+		_, err = service.Hold(context.Background(), "6", "5", 1)
+		as.ErrorIs(err, domain.ErrWalletFrozen)
+		walletRepo.AssertNotCalled(t, "Debit", mock.Anything, mock.Anything, mock.Anything)
+		walletRepo.AssertNotCalled(t, "CreateHold", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: a closed wallet refuses credits", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, Balance: decimal.Zero, Currency: "EUR", Status: domain.WalletStatusClosed}, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Credit(context.Background(), "6", "5", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrWalletClosed)
+		walletRepo.AssertNotCalled(t, "Credit", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("happy path: freezing records the reason and its history", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, Balance: decimal.NewFromInt(10), Currency: "EUR", Status: domain.WalletStatusActive}, nil).Once()
+		walletRepo.On("UpdateStatus", context.Background(),
+			mock.MatchedBy(func(w *domain.Wallet) bool {
+				return w != nil && w.Status == domain.WalletStatusFrozen && w.StatusReason == "suspected account takeover"
+			}),
+			mock.MatchedBy(func(c *domain.WalletStatusChange) bool {
+				return c != nil && c.WalletID == 6 && c.From == domain.WalletStatusActive && c.To == domain.WalletStatusFrozen
+			})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		wallet, err := service.SetStatus(context.Background(), "6", domain.WalletStatusFrozen, " suspected account takeover ")
+		as.NoError(err)
+		as.Equal(domain.WalletStatusFrozen, wallet.Status)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("happy path: closing the default wallet forgets the player's cached wallets", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, PlayerID: 1, IsDefault: true, Balance: decimal.Zero, Currency: "EUR", Status: domain.WalletStatusActive}, nil).Once()
+		walletRepo.On("CountActiveHolds", context.Background(), 6).Return(0, nil).Once()
+		walletRepo.On("UpdateStatus", context.Background(), mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			// The repository moves the default on.
+			args.Get(1).(*domain.Wallet).IsDefault = false
+		}).Return(nil).Once()
+		walletRepo.On("ListByPlayer", context.Background(), 1).Return([]domain.Wallet{{ID: 6, PlayerID: 1}, {ID: 7, PlayerID: 1, IsDefault: true}}, nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Twice()
+		walletInMemoryDB.On("Delete", context.Background(), "7").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		wallet, err := service.SetStatus(context.Background(), "6", domain.WalletStatusClosed, "requested by player")
+		as.NoError(err)
+		as.False(wallet.IsDefault)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("input error: closing requires a zero balance", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, Balance: decimal.NewFromInt(10), Currency: "EUR", Status: domain.WalletStatusFrozen}, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.SetStatus(context.Background(), "6", domain.WalletStatusClosed, "player request")
+		as.ErrorIs(err, domain.ErrWalletNotEmpty)
+	})
+
+	t.Run("input error: closing requires no held funds or used credit", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, Balance: decimal.Zero, HeldBalance: decimal.NewFromInt(5), Currency: "EUR"}, nil).Once()
+		walletRepo.On("Get", context.Background(), "7").Return(domain.Wallet{ID: 7, Balance: decimal.NewFromInt(-20), CreditLimit: decimal.NewFromInt(50), Currency: "EUR"}, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.SetStatus(context.Background(), "6", domain.WalletStatusClosed, "player request")
+		as.ErrorIs(err, domain.ErrWalletNotEmpty)
+		_, err = service.SetStatus(context.Background(), "7", domain.WalletStatusClosed, "player request")
+		as.ErrorIs(err, domain.ErrWalletNotEmpty)
+		walletRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: closing requires no active holds", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, Balance: decimal.Zero, Currency: "EUR"}, nil).Once()
+		walletRepo.On("CountActiveHolds", context.Background(), 6).Return(1, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.SetStatus(context.Background(), "6", domain.WalletStatusClosed, "player request")
+		as.ErrorIs(err, domain.ErrWalletNotEmpty)
+		walletRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: closed wallets cannot be reopened", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, Balance: decimal.Zero, Currency: "EUR", Status: domain.WalletStatusClosed}, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.SetStatus(context.Background(), "6", domain.WalletStatusActive, "mistake")
+		as.ErrorIs(err, domain.ErrInvalidStatusTransition)
+	})
+
+	t.Run("input error: a reason is required", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.SetStatus(context.Background(), "6", domain.WalletStatusFrozen, "  ")
+		as.ErrorIs(err, domain.ErrStatusReasonRequired)
+	})
+}
+
+func TestDepositLimits(t *testing.T) {
+	as := assert.New(t)
+	dailyLimit := domain.Limit{ID: 1, PlayerID: 3, Currency: "EUR", Type: domain.LimitTypeDeposit, Period: domain.LimitPeriodDaily, Amount: decimal.NewNullDecimal(decimal.NewFromInt(100))}
+
+	t.Run("happy path: a deposit within the limit is booked as a deposit", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, PlayerID: 3, Balance: decimal.Zero, Currency: "EUR"}, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), 3, "EUR").Return([]domain.Limit{dailyLimit}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("LockPlayer", context.Background(), 3).Return(nil).Once()
+		walletRepo.On("SumActivity", context.Background(), 3, "EUR", mock.Anything).Return(domain.WalletActivity{Deposited: decimal.NewFromInt(60)}, nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr != nil && tr.Deposit
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Deposit(context.Background(), "6", "40", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: a deposit over the rolling window total is refused", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, PlayerID: 3, Balance: decimal.Zero, Currency: "EUR"}, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), 3, "EUR").Return([]domain.Limit{dailyLimit}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("LockPlayer", context.Background(), 3).Return(nil).Once()
+		walletRepo.On("SumActivity", context.Background(), 3, "EUR", mock.MatchedBy(func(since time.Time) bool {
+			return time.Since(since) > 23*time.Hour && time.Since(since) < 25*time.Hour
+		})).Return(domain.WalletActivity{Deposited: decimal.NewFromInt(60), Wagered: decimal.NewFromInt(1000)}, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Deposit(context.Background(), "6", "40.01", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrDepositLimitExceeded)
+		walletRepo.AssertNotCalled(t, "Credit", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: deposits only take the deposit category", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Deposit(context.Background(), "6", "40", 1, domain.TransactionDetails{Category: domain.CategoryWin})
+		as.ErrorIs(err, domain.ErrInvalidCategory)
+		walletRepo.AssertNotCalled(t, "Credit", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("happy path: plain credits ignore deposit limits", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, PlayerID: 3, Balance: decimal.Zero, Currency: "EUR"}, nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Credit(context.Background(), "6", "1000", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertNotCalled(t, "ListLimits", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("happy path: decreases apply immediately and increases after cooling", func(t *testing.T) {
+		now := time.Now()
+		limit := dailyLimit
+		limit.Change(decimal.NewNullDecimal(decimal.NewFromInt(50)), now)
+		as.True(limit.Amount.Decimal.Equal(decimal.NewFromInt(50)))
+		as.Nil(limit.PendingFrom)
+
+		limit.Change(decimal.NewNullDecimal(decimal.NewFromInt(500)), now)
+		as.True(limit.Amount.Decimal.Equal(decimal.NewFromInt(50)))
+		as.True(limit.PendingAmount.Decimal.Equal(decimal.NewFromInt(500)))
+		as.Equal(now.Add(domain.LimitCoolingPeriod), *limit.PendingFrom)
+
+		limit.Settle(now.Add(domain.LimitCoolingPeriod - time.Second))
+		as.True(limit.Amount.Decimal.Equal(decimal.NewFromInt(50)))
+		limit.Settle(now.Add(domain.LimitCoolingPeriod))
+		as.True(limit.Amount.Decimal.Equal(decimal.NewFromInt(500)))
+		as.Nil(limit.PendingFrom)
+
+		limit.Change(decimal.NullDecimal{}, now)
+		as.True(limit.Amount.Valid)
+		as.False(limit.PendingAmount.Valid)
+		as.NotNil(limit.PendingFrom)
+	})
+
+	t.Run("happy path: setting a looser limit keeps the current one", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletInMemoryDB.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, PlayerID: 3, Currency: "EUR"}, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), 3, "EUR").Return([]domain.Limit{dailyLimit}, nil).Once()
+		walletRepo.On("SaveLimit", context.Background(), mock.MatchedBy(func(l *domain.Limit) bool {
+			return l != nil && l.ID == 1 && l.Amount.Decimal.Equal(decimal.NewFromInt(100)) && l.PendingAmount.Decimal.Equal(decimal.NewFromInt(200))
+		})).Return(nil).Once()
+		walletRepo.On("SumActivity", context.Background(), 3, "EUR", mock.Anything).Return(domain.WalletActivity{Deposited: decimal.NewFromInt(30)}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		limit, err := service.SetLimit(context.Background(), "6", domain.LimitTypeDeposit, domain.LimitPeriodDaily, "200")
+		as.NoError(err)
+		as.True(limit.Remaining.Decimal.Equal(decimal.NewFromInt(70)))
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: a limit created concurrently is updated instead", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletInMemoryDB.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, PlayerID: 3, Currency: "EUR"}, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), 3, "EUR").Return([]domain.Limit{}, nil).Once()
+		walletRepo.On("SaveLimit", context.Background(), mock.MatchedBy(func(l *domain.Limit) bool {
+			return l != nil && l.ID == 0
+		})).Return(domain.ErrDuplicateRecord).Once()
+		walletRepo.On("ListLimits", context.Background(), 3, "EUR").Return([]domain.Limit{dailyLimit}, nil).Once()
+		walletRepo.On("SaveLimit", context.Background(), mock.MatchedBy(func(l *domain.Limit) bool {
+			return l != nil && l.ID == 1 && l.Amount.Decimal.Equal(decimal.NewFromInt(50))
+		})).Return(nil).Once()
+		walletRepo.On("SumActivity", context.Background(), 3, "EUR", mock.Anything).Return(domain.WalletActivity{}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		limit, err := service.SetLimit(context.Background(), "6", domain.LimitTypeDeposit, domain.LimitPeriodDaily, "50")
+		as.NoError(err)
+		as.Equal(1, limit.ID)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: unknown periods are rejected", func(t *testing.T) {
+		service := NewWalletService(&repository.WalletRepositoryMock{}, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.SetLimit(context.Background(), "6", domain.LimitTypeDeposit, "yearly", "200")
+		as.ErrorIs(err, domain.ErrInvalidLimitPeriod)
+	})
+}
+
+func TestLossAndWagerLimits(t *testing.T) {
+	as := assert.New(t)
+	wallet := domain.Wallet{ID: 6, PlayerID: 3, Balance: decimal.NewFromInt(1000), Currency: "EUR"}
+
+	t.Run("input error: a debit over the wager limit is refused", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), 3, "EUR").Return([]domain.Limit{
+			{PlayerID: 3, Currency: "EUR", Type: domain.LimitTypeWager, Period: domain.LimitPeriodWeekly, Amount: decimal.NewNullDecimal(decimal.NewFromInt(200))},
+		}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("LockPlayer", context.Background(), 3).Return(nil).Once()
+		walletRepo.On("SumActivity", context.Background(), 3, "EUR", mock.Anything).Return(domain.WalletActivity{Wagered: decimal.NewFromInt(150), Won: decimal.NewFromInt(300)}, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Debit(context.Background(), "6", "60", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrWagerLimitExceeded)
+		walletRepo.AssertNotCalled(t, "Debit", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("happy path: winnings free up the loss limit", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), 3, "EUR").Return([]domain.Limit{
+			{PlayerID: 3, Currency: "EUR", Type: domain.LimitTypeLoss, Period: domain.LimitPeriodDaily, Amount: decimal.NewNullDecimal(decimal.NewFromInt(100))},
+			{PlayerID: 3, Currency: "EUR", Type: domain.LimitTypeDeposit, Period: domain.LimitPeriodDaily, Amount: decimal.NewNullDecimal(decimal.Zero)},
+		}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("LockPlayer", context.Background(), 3).Return(nil).Once()
+		walletRepo.On("SumActivity", context.Background(), 3, "EUR", mock.Anything).Return(domain.WalletActivity{Wagered: decimal.NewFromInt(250), Won: decimal.NewFromInt(200)}, nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Debit(context.Background(), "6", "50", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: a debit that could lose more than the loss limit allows is refused", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), 3, "EUR").Return([]domain.Limit{
+			{PlayerID: 3, Currency: "EUR", Type: domain.LimitTypeLoss, Period: domain.LimitPeriodDaily, Amount: decimal.NewNullDecimal(decimal.NewFromInt(100))},
+		}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("LockPlayer", context.Background(), 3).Return(nil).Once()
+		walletRepo.On("SumActivity", context.Background(), 3, "EUR", mock.Anything).Return(domain.WalletActivity{Wagered: decimal.NewFromInt(250), Won: decimal.NewFromInt(200)}, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Debit(context.Background(), "6", "50.01", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrLossLimitExceeded)
+	})
+
+	t.Run("happy path: listing reports the remaining allowance", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletInMemoryDB.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), 3, "EUR").Return([]domain.Limit{
+			{PlayerID: 3, Currency: "EUR", Type: domain.LimitTypeLoss, Period: domain.LimitPeriodDaily, Amount: decimal.NewNullDecimal(decimal.NewFromInt(100))},
+			{PlayerID: 3, Currency: "EUR", Type: domain.LimitTypeWager, Period: domain.LimitPeriodDaily, Amount: decimal.NewNullDecimal(decimal.NewFromInt(200))},
+		}, nil).Once()
+		walletRepo.On("SumActivity", context.Background(), 3, "EUR", mock.Anything).Return(domain.WalletActivity{Wagered: decimal.NewFromInt(120), Won: decimal.NewFromInt(300)}, nil).Twice()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		limits, err := service.ListLimits(context.Background(), "6")
+		as.NoError(err)
+		as.True(limits[0].Used.IsZero())
+		as.True(limits[0].Remaining.Decimal.Equal(decimal.NewFromInt(100)))
+		as.True(limits[1].Used.Equal(decimal.NewFromInt(120)))
+		as.True(limits[1].Remaining.Decimal.Equal(decimal.NewFromInt(80)))
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: a hold over the wager limit is refused", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), 3, "EUR").Return([]domain.Limit{
+			{PlayerID: 3, Currency: "EUR", Type: domain.LimitTypeWager, Period: domain.LimitPeriodDaily, Amount: decimal.NewNullDecimal(decimal.NewFromInt(200))},
+		}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("LockPlayer", context.Background(), 3).Return(nil).Once()
+		walletRepo.On("SumActivity", context.Background(), 3, "EUR", mock.Anything).Return(domain.WalletActivity{Wagered: decimal.NewFromInt(150)}, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Hold(context.Background(), "6", "60", 1)
+		as.ErrorIs(err, domain.ErrWagerLimitExceeded)
+		walletRepo.AssertNotCalled(t, "CreateHold", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: unknown limit types are rejected", func(t *testing.T) {
+		service := NewWalletService(&repository.WalletRepositoryMock{}, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.SetLimit(context.Background(), "6", "session", domain.LimitPeriodDaily, "200")
+		as.ErrorIs(err, domain.ErrInvalidLimitType)
+	})
+}
+
+func TestWithdraw(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("happy path: withdrawals skip wager limits and are marked", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{ID: 6, Balance: decimal.NewFromInt(100), Currency: "EUR"}, nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.Anything, mock.MatchedBy(func(tr *domain.Transaction) bool {
+			return tr != nil && tr.Withdrawal && tr.BalanceAfter.Equal(decimal.NewFromInt(40))
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Withdraw(context.Background(), "6", "60", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertNotCalled(t, "ListLimits", mock.Anything, mock.Anything, mock.Anything)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: bonus and held funds cannot be withdrawn", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(domain.Wallet{
+			ID:           6,
+			Balance:      decimal.NewFromInt(100),
+			BonusBalance: decimal.NewFromInt(30),
+			HeldBalance:  decimal.NewFromInt(20),
+			Currency:     "EUR",
+		}, nil).Twice()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Withdraw(context.Background(), "6", "80.01", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrInsufficientFunds)
+		_, err = service.Withdraw(context.Background(), "6", "70.01", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrInsufficientFunds)
+		walletRepo.AssertNotCalled(t, "Debit", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestCreditLimit(t *testing.T) {
+	as := assert.New(t)
+	wallet := domain.Wallet{ID: 6, Balance: decimal.NewFromInt(50), CreditLimit: decimal.NewFromInt(100), Currency: "EUR"}
+
+	t.Run("happy path: debits may use the credit line", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w != nil && w.Balance.Equal(decimal.NewFromInt(-100)) && w.UsedCredit().Equal(decimal.NewFromInt(100))
+		}), mock.Anything).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Debit(context.Background(), "6", "150", 1, domain.TransactionDetails{})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: debits cannot go below the credit limit", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Debit(context.Background(), "6", "150.01", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrInsufficientFunds)
+		walletRepo.AssertNotCalled(t, "Debit", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: withdrawals cannot use the credit line", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Withdraw(context.Background(), "6", "51", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrInsufficientFunds)
+	})
+
+	t.Run("happy path: setting the credit limit writes the wallet", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("Update", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w != nil && w.CreditLimit.Equal(decimal.NewFromInt(250))
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		updated, err := service.SetCreditLimit(context.Background(), "6", "250")
+		as.NoError(err)
+		as.True(updated.CreditLimit.Equal(decimal.NewFromInt(250)))
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: negative credit limits are rejected", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.SetCreditLimit(context.Background(), "6", "-1")
+		as.ErrorIs(err, domain.ErrInvalidAmount)
+	})
+}
+
+func TestBatch(t *testing.T) {
+	as := assert.New(t)
+	wallet := domain.Wallet{ID: 7, Balance: decimal.NewFromInt(100), Currency: "EUR"}
+
+	t.Run("input error: batch must have a valid mode and size", func(t *testing.T) {
+		service := NewWalletService(&repository.WalletRepositoryMock{}, &inmemorydb.WalletInMemoryDBMock{})
+		item := domain.BatchItem{WalletID: "7", Type: domain.TransactionTypeCredit, Amount: "10"}
+		_, err := service.Batch(context.Background(), nil, domain.BatchModeBestEffort, 1)
+		as.ErrorIs(err, domain.ErrEmptyBatch)
+		_, err = service.Batch(context.Background(), make([]domain.BatchItem, domain.MaxBatchItems+1), domain.BatchModeBestEffort, 1)
+		as.ErrorIs(err, domain.ErrBatchTooLarge)
+		_, err = service.Batch(context.Background(), []domain.BatchItem{item}, "sometimes", 1)
+		as.ErrorIs(err, domain.ErrInvalidBatchMode)
+	})
+
+	t.Run("happy path: all-or-nothing applies every item in one transaction", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("LockWallets", context.Background(), []int{7}).Return(nil).Once()
+		walletRepo.On("Get", context.Background(), "7").Return(wallet, nil).Twice()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
+		walletRepo.On("ListLimits", context.Background(), mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "7").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		results, err := service.Batch(context.Background(), []domain.BatchItem{
+			{WalletID: "7", Type: domain.TransactionTypeCredit, Amount: "10"},
+			{WalletID: "7", Type: domain.TransactionTypeDebit, Amount: "20"},
+		}, domain.BatchModeAllOrNothing, 1)
+		as.NoError(err)
+		as.Len(results, 2)
+		as.Equal(domain.TransactionTypeDebit, results[1].Transaction.Type)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("input error: all-or-nothing fails on the first bad item", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("LockWallets", context.Background(), []int{7}).Return(nil).Once()
+		walletRepo.On("Get", context.Background(), "7").Return(wallet, nil).Twice()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		results, err := service.Batch(context.Background(), []domain.BatchItem{
+			{WalletID: "7", Type: domain.TransactionTypeCredit, Amount: "10"},
+			{WalletID: "7", Type: domain.TransactionTypeDebit, Amount: "500"},
+			{WalletID: "7", Type: domain.TransactionTypeCredit, Amount: "10"},
+		}, domain.BatchModeAllOrNothing, 1)
+		as.Nil(results)
+		as.ErrorIs(err, domain.ErrInsufficientFunds)
+		var itemErr *domain.BatchItemError
+		as.ErrorAs(err, &itemErr)
+		as.Equal(1, itemErr.Index)
+		walletInMemoryDB.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+	})
+
+	t.Run("happy path: all-or-nothing locks its wallets in ID order", func(t *testing.T) {
+		as.Equal([]int{3, 7, 12}, batchWalletIDs([]domain.BatchItem{
+			{WalletID: "12"}, {WalletID: "7"}, {WalletID: "12"}, {WalletID: "x"}, {WalletID: "3"},
+		}))
+	})
+
+	t.Run("happy path: best-effort reports every item", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "7").Return(wallet, nil).Twice()
+		walletRepo.On("Get", context.Background(), "8").Return(domain.Wallet{}, domain.ErrRecordNotFound).Once()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "7").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		results, err := service.Batch(context.Background(), []domain.BatchItem{
+			{WalletID: "7", Type: domain.TransactionTypeCredit, Amount: "10"},
+			{WalletID: "8", Type: domain.TransactionTypeCredit, Amount: "10"},
+			{WalletID: "7", Type: domain.TransactionTypeDebit, Amount: "500"},
+			{WalletID: "7", Type: "refund", Amount: "10"},
+		}, domain.BatchModeBestEffort, 1)
+		as.NoError(err)
+		as.Len(results, 4)
+		as.NoError(results[0].Err)
+		as.NotNil(results[0].Transaction)
+		as.ErrorIs(results[1].Err, domain.ErrRecordNotFound)
+		as.ErrorIs(results[2].Err, domain.ErrInsufficientFunds)
+		as.ErrorIs(results[3].Err, domain.ErrInvalidBatchItem)
+		walletRepo.AssertExpectations(t)
+	})
+}
+
+// recordingStatementWriter keeps everything written to a statement.
+type recordingStatementWriter struct {
+	opening      *domain.Statement
+	transactions []domain.Transaction
+	closing      *domain.Statement
+}
+
+func (r *recordingStatementWriter) Opening(s domain.Statement) error {
+	r.opening = &s
+	return nil
+}
+
+func (r *recordingStatementWriter) Transaction(t domain.Transaction) error {
+	r.transactions = append(r.transactions, t)
+	return nil
+}
+
+func (r *recordingStatementWriter) Closing(s domain.Statement) error {
+	r.closing = &s
+	return nil
+}
+
+func TestWriteStatement(t *testing.T) {
+	as := assert.New(t)
+	wallet := domain.Wallet{ID: 9, Balance: decimal.NewFromInt(100), Currency: "EUR"}
+	from := time.Now().Add(-48 * time.Hour)
+	to := time.Now().Add(-time.Hour)
+
+	t.Run("happy path: opening balance, transactions and closing balance", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletInMemoryDB.On("Get", context.Background(), "9").Return(wallet, nil).Once()
+		snapshot := domain.BalanceSnapshot{WalletID: 9, Balance: decimal.NewFromInt(40), AsOf: from.Add(-time.Hour)}
+		walletRepo.On("LatestSnapshot", context.Background(), 9, from).Return(snapshot, nil).Once()
+		walletRepo.On("SumTransactions", context.Background(), 9, snapshot.AsOf, from).Return(decimal.NewFromInt(10), 1, nil).Once()
+		walletRepo.On("StreamTransactions", context.Background(), 9, from, to).Return([]domain.Transaction{
+			{ID: 1, Type: domain.TransactionTypeCredit, Amount: decimal.NewFromInt(25)},
+			{ID: 2, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(5)},
+		}, nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		writer := &recordingStatementWriter{}
+		err := service.WriteStatement(context.Background(), "9", from, to, writer)
+		as.NoError(err)
+		as.True(writer.opening.OpeningBalance.Equal(decimal.NewFromInt(50)))
+		as.Len(writer.transactions, 2)
+		as.True(writer.closing.ClosingBalance.Equal(decimal.NewFromInt(70)))
+		as.Equal(2, writer.closing.Transactions)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: the period must end after it starts", func(t *testing.T) {
+		service := NewWalletService(&repository.WalletRepositoryMock{}, &inmemorydb.WalletInMemoryDBMock{})
+		writer := &recordingStatementWriter{}
+		err := service.WriteStatement(context.Background(), "9", to, from, writer)
+		as.ErrorIs(err, domain.ErrInvalidStatementPeriod)
+		as.Nil(writer.opening)
+	})
+}
+
+func TestTransactionDetails(t *testing.T) {
+	as := assert.New(t)
+	wallet := domain.Wallet{ID: 6, Balance: decimal.NewFromInt(100), Currency: "EUR"}
+
+	t.Run("happy path: details are stored with the transaction", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.Reference == "round-42" && t.Description == "slot win" &&
+				t.Category == domain.CategoryWin && t.Metadata["game"] == "starburst" && !t.Deposit
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Credit(context.Background(), "6", "10", 1, domain.TransactionDetails{
+			Reference:   "round-42",
+			Description: "slot win",
+			Category:    domain.CategoryWin,
+			Metadata:    domain.Metadata{"game": "starburst"},
+		})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: credits in the deposit category are deposits", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.Deposit && t.Category == domain.CategoryDeposit
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Credit(context.Background(), "6", "10", 1, domain.TransactionDetails{Category: domain.CategoryDeposit})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: debits in the withdrawal category are withdrawals", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.Anything, mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.Withdrawal && t.Category == domain.CategoryWithdrawal
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Debit(context.Background(), "6", "10", 1, domain.TransactionDetails{Category: domain.CategoryWithdrawal})
+		as.NoError(err)
+		walletRepo.AssertNotCalled(t, "ListLimits", mock.Anything, mock.Anything, mock.Anything)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: categories and metadata are validated", func(t *testing.T) {
+		service := NewWalletService(&repository.WalletRepositoryMock{}, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.Credit(context.Background(), "6", "10", 1, domain.TransactionDetails{Category: domain.CategoryBet})
+		as.ErrorIs(err, domain.ErrInvalidCategory)
+		_, err = service.Debit(context.Background(), "6", "10", 1, domain.TransactionDetails{Category: "jackpot"})
+		as.ErrorIs(err, domain.ErrInvalidCategory)
+		_, err = service.Credit(context.Background(), "6", "10", 1, domain.TransactionDetails{Metadata: domain.Metadata{"bad key": "x"}})
+		as.ErrorIs(err, domain.ErrInvalidMetadata)
+	})
+
+	t.Run("happy path: metadata round-trips through its database value", func(t *testing.T) {
+		value, err := domain.Metadata{"psp": "adyen"}.Value()
+		as.NoError(err)
+		var metadata domain.Metadata
+		as.NoError(metadata.Scan([]byte(value.(string))))
+		as.Equal(domain.Metadata{"psp": "adyen"}, metadata)
+	})
+}
+
+func TestJackpots(t *testing.T) {
+	as := assert.New(t)
+	wallet := domain.Wallet{ID: 6, PlayerID: 1, Balance: decimal.NewFromInt(100), Currency: "EUR", Status: domain.WalletStatusActive}
+	pool := domain.JackpotPool{ID: 3, WalletID: 50, Currency: "EUR", ContributionRate: decimal.RequireFromString("0.015"), SeedAmount: decimal.NewFromInt(1000)}
+
+	t.Run("happy path: contributions are rounded down to the currency", func(t *testing.T) {
+		eur, _ := domain.LookupCurrency("EUR")
+		bet := domain.Transaction{WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.RequireFromString("3.33"), Category: domain.CategoryBet}
+		as.True(pool.Contribution(bet, eur).Equal(decimal.RequireFromString("0.04")))
+		bet.Category = domain.CategoryAdjustment
+		as.True(pool.Contribution(bet, eur).IsZero())
+		bet.Category, bet.Withdrawal = "", true
+		as.True(pool.Contribution(bet, eur).IsZero())
+	})
+
+	t.Run("happy path: a bet pays into the pool in the same database transaction", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		jackpotRepo := &repository.JackpotRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil)
+		jackpotRepo.On("ListByCurrency", context.Background(), "EUR").Return([]domain.JackpotPool{pool}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
+		walletRepo.On("Contribute", context.Background(), mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.WalletID == 50 && t.Type == domain.TransactionTypeCredit &&
+				t.Amount.Equal(decimal.RequireFromString("0.30")) && t.Category == domain.CategoryJackpot
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "50").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB, WithJackpots(jackpotRepo))
+		_, err := service.Debit(context.Background(), "6", "20", 1, domain.TransactionDetails{Category: domain.CategoryBet})
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("happy path: debits that do not qualify skip the pools", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		jackpotRepo := &repository.JackpotRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("ListLimits", context.Background(), mock.Anything, mock.Anything).Return([]domain.Limit(nil), nil)
+		jackpotRepo.On("ListByCurrency", context.Background(), "EUR").Return([]domain.JackpotPool{pool}, nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.Anything, mock.Anything).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB, WithJackpots(jackpotRepo))
+		_, err := service.Debit(context.Background(), "6", "20", 1, domain.TransactionDetails{Category: domain.CategoryAdjustment})
+		as.NoError(err)
+		walletRepo.AssertNotCalled(t, "Contribute", mock.Anything, mock.Anything)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: a captured hold pays into the pool", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		jackpotRepo := &repository.JackpotRepositoryMock{}
+		held := wallet
+		held.HeldBalance = decimal.NewFromInt(20)
+		walletRepo.On("GetHold", context.Background(), "4").Return(domain.Hold{
+			ID: 4, WalletID: 6, Amount: decimal.NewFromInt(20), Status: domain.HoldStatusActive, ExpiresAt: time.Now().Add(time.Minute),
+		}, nil).Once()
+		walletRepo.On("Get", context.Background(), "6").Return(held, nil).Once()
+		jackpotRepo.On("ListByCurrency", context.Background(), "EUR").Return([]domain.JackpotPool{pool}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("SettleHold", context.Background(), mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+		walletRepo.On("Contribute", context.Background(), mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.WalletID == 50 && t.Type == domain.TransactionTypeCredit && t.Amount.Equal(decimal.RequireFromString("0.30"))
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "50").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB, WithJackpots(jackpotRepo))
+		_, err := service.CaptureHold(context.Background(), "6", "4", "", 1)
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("happy path: reversing a bet takes its share of the contribution back out of the pool", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		jackpotRepo := &repository.JackpotRepositoryMock{}
+		walletRepo.On("GetTransaction", context.Background(), "20").Return(domain.Transaction{
+			ID: 20, WalletID: 6, Type: domain.TransactionTypeDebit, Amount: decimal.NewFromInt(20), Category: domain.CategoryBet,
+		}, nil).Twice()
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Twice()
+		walletRepo.On("ReversedAmount", context.Background(), 20).Return(decimal.Zero, nil).Once()
+		walletRepo.On("ReversedAmount", context.Background(), 20).Return(decimal.NewFromInt(10), nil).Once()
+		walletRepo.On("ListBonuses", context.Background(), 6, domain.BonusStatus("")).Return([]domain.Bonus{}, nil).Twice()
+		contribution := domain.Transaction{ID: 21, WalletID: 50, Type: domain.TransactionTypeCredit, Amount: decimal.RequireFromString("0.30"),
+			Reference: "20", Category: domain.CategoryJackpot, Metadata: domain.Metadata{"jackpot_id": "3"}}
+		walletRepo.On("ListContributions", context.Background(), 20).Return([]domain.Transaction{contribution}, nil).Once()
+		walletRepo.On("Transaction", context.Background()).Return(nil).Twice()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.Anything).Return(nil).Twice()
+		walletRepo.On("Contribute", context.Background(), mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t.WalletID == 50 && t.Type == domain.TransactionTypeDebit && t.Amount.Equal(decimal.RequireFromString("0.15")) &&
+				t.Reference == "20" && t.Metadata["jackpot_id"] == "3"
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Twice()
+		walletInMemoryDB.On("Delete", context.Background(), "50").Return(nil).Twice()
+		service := NewWalletService(walletRepo, walletInMemoryDB, WithJackpots(jackpotRepo))
+		_, err := service.Reverse(context.Background(), "20", "10", 0)
+		as.NoError(err)
+
+		// Reversing the rest takes whatever of the contribution is left.
+		withdrawn := domain.Transaction{ID: 22, WalletID: 50, Type: domain.TransactionTypeDebit, Amount: decimal.RequireFromString("0.15"),
+			Reference: "20", Category: domain.CategoryJackpot}
+		walletRepo.On("ListContributions", context.Background(), 20).Return([]domain.Transaction{contribution, withdrawn}, nil).Once()
+		walletRepo.On("Contribute", context.Background(), mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t.WalletID == 50 && t.Type == domain.TransactionTypeDebit && t.Amount.Equal(decimal.RequireFromString("0.15"))
+		})).Return(nil).Once()
+		_, err = service.Reverse(context.Background(), "20", "", 0)
+		as.NoError(err)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("happy path: a payout empties the pool into the winner's wallet and reseeds it", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		poolWallet := domain.Wallet{ID: 50, Balance: decimal.RequireFromString("1234.56"), Currency: "EUR", Status: domain.WalletStatusActive}
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		var locked []int
+		lock := func(args mock.Arguments) {
+			locked = append(locked, args.Get(1).([]int)...)
+		}
+		walletRepo.On("LockWallets", context.Background(), []int{6}).Run(lock).Return(nil).Once()
+		walletRepo.On("LockWallets", context.Background(), []int{50}).Run(lock).Return(nil).Once()
+		walletRepo.On("Get", context.Background(), "50").Return(poolWallet, nil).Once()
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		walletRepo.On("Debit", context.Background(), mock.Anything, mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.WalletID == 50 && t.Amount.Equal(poolWallet.Balance) && t.BalanceAfter.IsZero()
+		})).Return(nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.WalletID == 6 && t.Amount.Equal(poolWallet.Balance) && t.Category == domain.CategoryWin
+		})).Return(nil).Once()
+		walletRepo.On("Credit", context.Background(), mock.Anything, mock.MatchedBy(func(t *domain.Transaction) bool {
+			return t != nil && t.WalletID == 50 && t.Amount.Equal(decimal.NewFromInt(1000)) && t.Category == domain.CategoryJackpot
+		})).Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "50").Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		payout, err := service.PayoutJackpot(context.Background(), pool, "6", 0)
+		as.NoError(err)
+		as.True(payout.Pool.Balance.Equal(decimal.NewFromInt(1000)))
+		as.NotNil(payout.Reseed)
+		// The winner's wallet is locked before the pool's.
+		as.Equal([]int{6, 50}, locked)
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("rule violation: funds leave a pool's wallet by payout only", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		poolWallet := domain.Wallet{ID: 50, Pool: true, Balance: decimal.NewFromInt(500), Currency: "EUR", Status: domain.WalletStatusActive}
+		walletRepo.On("Get", context.Background(), "50").Return(poolWallet, nil)
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil)
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		_, err := service.Debit(context.Background(), "50", "20", 1, domain.TransactionDetails{})
+		as.ErrorIs(err, domain.ErrPoolWallet)
+		_, err = service.Transfer(context.Background(), "50", "6", "20", poolWallet.PlayerID)
+		as.ErrorIs(err, domain.ErrPoolWallet)
+		_, err = service.Hold(context.Background(), "50", "20", 1)
+		as.ErrorIs(err, domain.ErrPoolWallet)
+		walletRepo.AssertNotCalled(t, "Debit", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("input error: an empty pool cannot be paid out", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Transaction", context.Background()).Return(nil).Once()
+		walletRepo.On("LockWallets", context.Background(), mock.Anything).Return(nil).Twice()
+		walletRepo.On("Get", context.Background(), "50").Return(domain.Wallet{ID: 50, Balance: decimal.Zero, Currency: "EUR"}, nil).Once()
+		walletRepo.On("Get", context.Background(), "6").Return(wallet, nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.PayoutJackpot(context.Background(), pool, "6", 0)
+		as.ErrorIs(err, domain.ErrJackpotEmpty)
+		_, err = service.PayoutJackpot(context.Background(), pool, "50", 0)
+		as.ErrorIs(err, domain.ErrSameWallet)
+	})
+}
+
+func TestPlayerWallets(t *testing.T) {
+	as := assert.New(t)
+
+	t.Run("happy path: player wallets are created under the configured cap", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("CreateForPlayer", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w != nil && w.PlayerID == 1 && w.Label == "savings" && w.Status == domain.WalletStatusActive
+		}), 3).Return(nil).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{}, WithMaxWalletsPerPlayer(3))
+		as.NoError(service.Create(context.Background(), &domain.Wallet{PlayerID: 1, Label: "savings"}))
+		walletRepo.AssertExpectations(t)
+	})
+
+	t.Run("input error: wallets beyond the cap are refused", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("CreateForPlayer", context.Background(), mock.Anything, 3).Return(domain.ErrWalletLimitReached).Once()
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{}, WithMaxWalletsPerPlayer(3))
+		err := service.Create(context.Background(), &domain.Wallet{PlayerID: 1})
+		as.ErrorIs(err, domain.ErrWalletLimitReached)
+	})
+
+	t.Run("input error: labels are at most 64 characters", func(t *testing.T) {
+		service := NewWalletService(&repository.WalletRepositoryMock{}, &inmemorydb.WalletInMemoryDBMock{})
+		err := service.Create(context.Background(), &domain.Wallet{PlayerID: 1, Label: strings.Repeat("x", 65)})
+		as.ErrorIs(err, domain.ErrInvalidWalletLabel)
+		_, err = service.SetLabel(context.Background(), 1, "6", strings.Repeat("x", 65))
+		as.ErrorIs(err, domain.ErrInvalidWalletLabel)
+	})
+
+	t.Run("happy path: a new default replaces the previous one", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletInMemoryDB := &inmemorydb.WalletInMemoryDBMock{}
+		walletRepo.On("Get", context.Background(), "7").Return(domain.Wallet{ID: 7, PlayerID: 1, Status: domain.WalletStatusActive}, nil).Once()
+		walletRepo.On("SetDefault", context.Background(), mock.MatchedBy(func(w *domain.Wallet) bool {
+			return w != nil && w.ID == 7
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.Wallet).IsDefault = true
+		}).Return(nil).Once()
+		walletRepo.On("ListByPlayer", context.Background(), 1).Return([]domain.Wallet{{ID: 6, PlayerID: 1}, {ID: 7, PlayerID: 1, IsDefault: true}}, nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "6").Return(nil).Once()
+		walletInMemoryDB.On("Delete", context.Background(), "7").Return(nil).Once()
+		service := NewWalletService(walletRepo, walletInMemoryDB)
+		wallet, err := service.SetDefault(context.Background(), 1, "7")
+		as.NoError(err)
+		as.True(wallet.IsDefault)
+		walletRepo.AssertExpectations(t)
+		walletInMemoryDB.AssertExpectations(t)
+	})
+
+	t.Run("input error: wallets of other players are not found", func(t *testing.T) {
+		walletRepo := &repository.WalletRepositoryMock{}
+		walletRepo.On("Get", context.Background(), "7").Return(domain.Wallet{ID: 7, PlayerID: 2}, nil).Times(3)
+		service := NewWalletService(walletRepo, &inmemorydb.WalletInMemoryDBMock{})
+		_, err := service.GetForPlayer(context.Background(), 1, "7")
+		as.ErrorIs(err, domain.ErrRecordNotFound)
+		_, err = service.SetDefault(context.Background(), 1, "7")
+		as.ErrorIs(err, domain.ErrRecordNotFound)
+		_, err = service.SetLabel(context.Background(), 1, "7", "main")
+		as.ErrorIs(err, domain.ErrRecordNotFound)
+		walletRepo.AssertNotCalled(t, "SetDefault", mock.Anything, mock.Anything)
+	})
+}